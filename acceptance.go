@@ -0,0 +1,104 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"fmt"
+)
+
+// AcceptancePolicy validates the currency and amount of accepted payments
+// against the rules of the merchant, before the OnPaymentAccepted hooks
+// fire.
+type AcceptancePolicy interface {
+	// CheckAcceptance is called with every accepted payment. A non-nil
+	// rejection is set in Payment.Rejection and the OnPaymentRejected
+	// hooks fire instead of the OnPaymentAccepted ones.
+	CheckAcceptance(p *Payment) (*Rejection, error)
+}
+
+// Rejection is an accepted payment rejected by the acceptance policy,
+// along with the suggested refund.
+type Rejection struct {
+	Reason string
+	Refund int64 // Suggested refund, in cents
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("payment rejected: %s (refund %d cents)", r.Reason, r.Refund)
+}
+
+// SetAcceptancePolicy sets the policy validating accepted payments.
+func (s *Sogen) SetAcceptancePolicy(a AcceptancePolicy) {
+	s.acceptance = a
+}
+
+// OnPaymentRejected registers a function called with every accepted
+// payment rejected by the acceptance policy (see Payment.Rejection) or
+// denied by the fraud policy (see Payment.Fraud), i.e. to refund it with
+// Refund(). It is called once per payment.
+func (s *Sogen) OnPaymentRejected(fn func(p *Payment)) {
+	s.onRejected = append(s.onRejected, fn)
+}
+
+// checkAcceptance applies the acceptance policy, if any, to an accepted
+// payment.
+func (s *Sogen) checkAcceptance(p *Payment) error {
+	if s.acceptance == nil || p.ResponseCode != "00" {
+		return nil
+	}
+	r, err := s.acceptance.CheckAcceptance(p)
+	if err != nil {
+		return err
+	}
+	p.Rejection = r
+	return nil
+}
+
+// CurrencyPolicy is a basic AcceptancePolicy checking the currency of
+// payments and, when the expected amount of the order is known, the
+// amount paid.
+type CurrencyPolicy struct {
+	// Accepted currency codes (i.e. 978), any if empty.
+	Currencies []string
+	// Expected returns the amount in cents and the currency code
+	// expected for the order of a payment, i.e. looked up from its return
+	// context. ok is false if unknown.
+	Expected func(p *Payment) (cents int64, currencyCode string, ok bool)
+	// Tolerance is the difference with the expected amount accepted for
+	// FX rounding, in cents.
+	Tolerance int64
+}
+
+func (c *CurrencyPolicy) CheckAcceptance(p *Payment) (*Rejection, error) {
+	paid := toCents(p.Amount)
+	if len(c.Currencies) > 0 {
+		ok := false
+		for _, code := range c.Currencies {
+			ok = ok || code == p.CurrencyCode
+		}
+		if !ok {
+			return &Rejection{"currency " + p.CurrencyCode + " not accepted", paid}, nil
+		}
+	}
+	if c.Expected == nil {
+		return nil, nil
+	}
+	expected, code, ok := c.Expected(p)
+	if !ok {
+		return nil, nil
+	}
+	if code != "" && code != p.CurrencyCode {
+		return &Rejection{fmt.Sprintf("paid in currency %s, expected %s", p.CurrencyCode, code), paid}, nil
+	}
+	switch diff := paid - expected; {
+	case diff > c.Tolerance:
+		// Overpaid: refund the excess
+		return &Rejection{fmt.Sprintf("paid %d cents, expected %d", paid, expected), diff}, nil
+	case -diff > c.Tolerance:
+		// Partial payment: refund everything
+		return &Rejection{fmt.Sprintf("partial payment of %d cents, expected %d", paid, expected), paid}, nil
+	}
+	return nil, nil
+}
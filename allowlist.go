@@ -0,0 +1,146 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPAllowlist restricts a handler to requests coming from a set of
+// addresses or CIDR ranges, such as the notification servers of the
+// payment platform.
+type IPAllowlist struct {
+	nets    []*net.IPNet
+	proxies []*net.IPNet // Trusted reverse proxies
+	config  *Config      // Logs the rejected requests, if set
+}
+
+// parseRanges parses addresses and CIDR ranges. A single address is
+// handled as a /32 (or /128) range.
+func parseRanges(ranges []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0)
+	for _, r := range ranges {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		if !strings.Contains(r, "/") {
+			ip := net.ParseIP(r)
+			if ip == nil {
+				return nil, errors.New(fmt.Sprintf("bad IP address %s", r))
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewIPAllowlist returns an allowlist of addresses or CIDR ranges
+// (i.e. 193.56.46.0/24).
+func NewIPAllowlist(ranges ...string) (*IPAllowlist, error) {
+	nets, err := parseRanges(ranges)
+	if err != nil {
+		return nil, err
+	}
+	if len(nets) == 0 {
+		return nil, errors.New("empty IP allowlist")
+	}
+	return &IPAllowlist{nets: nets}, nil
+}
+
+// TrustProxies sets the addresses or CIDR ranges of the reverse proxies
+// in front of the server. The X-Forwarded-For header is only used when
+// a request comes from one of them.
+func (a *IPAllowlist) TrustProxies(ranges ...string) error {
+	nets, err := parseRanges(ranges)
+	if err != nil {
+		return err
+	}
+	a.proxies = nets
+	return nil
+}
+
+// Allowed reports whether ip is in the allowlist.
+func (a *IPAllowlist) Allowed(ip net.IP) bool {
+	return ip != nil && contains(a.nets, ip)
+}
+
+// ClientIP returns the address of the client of a request. Behind trusted
+// proxies, it is the rightmost X-Forwarded-For address which is not a
+// trusted proxy.
+func (a *IPAllowlist) ClientIP(r *http.Request) net.IP {
+	return clientIP(r, a.proxies)
+}
+
+func clientIP(r *http.Request, proxies []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !contains(proxies, ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// Can't go any further
+			return nil
+		}
+		ip = hop
+		if !contains(proxies, ip) {
+			break
+		}
+	}
+	return ip
+}
+
+// logf logs a message with the logger of the config, if any, else with
+// the standard logger.
+func (a *IPAllowlist) logf(format string, v ...interface{}) {
+	c := a.config
+	if c == nil {
+		c = new(Config)
+	}
+	c.logf(LogWarning, format, v...)
+}
+
+// Handler returns a handler rejecting requests from addresses outside
+// the allowlist with a 403 Forbidden status.
+func (a *IPAllowlist) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := a.ClientIP(r)
+		if !a.Allowed(ip) {
+			a.logf("Rejected request from %s (%s)", ip, r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"strings"
+)
+
+// Card schemes, as returned by Payment.Scheme().
+const (
+	SchemeCB         = "CB"
+	SchemeVisa       = "VISA"
+	SchemeMastercard = "MASTERCARD"
+	SchemeAmex       = "AMEX"
+	SchemeJCB        = "JCB"
+)
+
+// Results of the check of the card security code (CVV, or 4-digit CID on
+// American Express cards), by cvv_response_code.
+var cvvResponses = map[string]string{
+	"4D": "correct security code",
+	"4E": "incorrect security code",
+	"50": "security code not processed",
+	"53": "security code missing from the authorization request",
+	"55": "bank of the buyer not certified, security code not checked",
+	"57": "security code check unavailable",
+}
+
+// CVVResult describes the result of the check of the card security code,
+// if any.
+func (p *Payment) CVVResult() string {
+	if p.CVVResponseCode == "" {
+		return ""
+	}
+	if r, ok := cvvResponses[p.CVVResponseCode]; ok {
+		return r
+	}
+	return "unknown security code result " + p.CVVResponseCode
+}
+
+// Scheme returns the card scheme of the payment: the payment mean for
+// international schemes, else the one of the card number prefix (i.e. an
+// American Express card used as CB). It is empty for other means.
+func (p *Payment) Scheme() string {
+	switch p.PaymentMeans {
+	case SchemeVisa, SchemeMastercard, SchemeAmex, SchemeJCB:
+		return p.PaymentMeans
+	case SchemeCB:
+		switch n := p.CardNumber; {
+		case strings.HasPrefix(n, "34"), strings.HasPrefix(n, "37"):
+			return SchemeAmex
+		case strings.HasPrefix(n, "35"):
+			return SchemeJCB
+		}
+		return SchemeCB
+	}
+	return ""
+}
+
+// Amex reports whether the payment was made with an American Express
+// card, whose contract and settlement are separate.
+func (p *Payment) Amex() bool {
+	return p.Scheme() == SchemeAmex
+}
+
+// MaskedCard returns the card number with hidden digits, grouped as
+// printed on the card: 4-6-5 for American Express, 4-4-4-4 otherwise. The
+// payment server only sends the first 4 and last 2 digits (i.e. 4974.97).
+func (p *Payment) MaskedCard() string {
+	parts := strings.SplitN(p.CardNumber, ".", 2)
+	if len(parts) != 2 {
+		return p.CardNumber
+	}
+	if p.Amex() {
+		return parts[0] + " XXXXXX XXX" + parts[1]
+	}
+	return parts[0] + " XXXX XXXX XX" + parts[1]
+}
@@ -0,0 +1,55 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// maxCents bounds amounts so that their conversion to cents does not
+// overflow.
+const maxCents = 1 << 53
+
+// validateAmount checks the amount of a transaction, which must be at least
+// a cent once rounded as sent to the bank (see toCents()).
+func validateAmount(amount float64) error {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return errors.New(fmt.Sprintf("invalid amount %v", amount))
+	}
+	if amount*100 >= maxCents {
+		return errors.New(fmt.Sprintf("amount too large, got %.2f", amount))
+	}
+	if toCents(amount) <= 0 {
+		return errors.New(fmt.Sprintf("amount must be at least 0.01, got %v", amount))
+	}
+	return nil
+}
+
+// checkAmount checks the amount of a transaction against the limits of
+// the merchant. A limit of 0 means no limit.
+func (s *Sogen) checkAmount(amount float64) error {
+	if err := validateAmount(amount); err != nil {
+		return err
+	}
+	if s.config.MinAmount > 0 && amount < s.config.MinAmount {
+		return errors.New(fmt.Sprintf("amount %.2f below the minimum of %.2f", amount, s.config.MinAmount))
+	}
+	if s.config.MaxAmount > 0 && amount > s.config.MaxAmount {
+		return errors.New(fmt.Sprintf("amount %.2f above the maximum of %.2f", amount, s.config.MaxAmount))
+	}
+	return nil
+}
+
+// NewTransaction creates a new transaction like the NewTransaction()
+// function, also checking the amount against the min_amount and
+// max_amount limits of the merchant.
+func (s *Sogen) NewTransaction(c *Customer, amount float64) (*Transaction, error) {
+	if err := s.checkAmount(amount); err != nil {
+		return nil, err
+	}
+	return NewTransaction(c, amount)
+}
@@ -0,0 +1,98 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Currency symbols, by alphabetic code. Other currencies are shown with
+// their alphabetic code.
+var currencySymbols = map[string]string{
+	"EUR": "€",
+	"USD": "$",
+	"GBP": "£",
+	"JPY": "¥",
+	"KRW": "₩",
+	"TRY": "₺",
+	"BRL": "R$",
+}
+
+// amountStyle is the way amounts are written in a language.
+type amountStyle struct {
+	decimal   string
+	thousands string
+	after     bool // Symbol after the amount
+}
+
+// Amount styles, by language. English is the default style.
+var amountStyles = map[string]amountStyle{
+	"en": {".", ",", false},
+	"fr": {",", " ", true},
+	"de": {",", ".", true},
+	"es": {",", ".", true},
+	"it": {",", ".", true},
+	"nl": {",", ".", true},
+}
+
+// FormatAmount formats an amount given in the smallest unit of the
+// currency (cents for euros) the way it is written in lang, i.e. "12,34 €"
+// in French and "€12.34" in English.
+func FormatAmount(cents int64, currency Currency, lang string) string {
+	style, ok := amountStyles[lang]
+	if !ok {
+		style = amountStyles[DefaultLanguage]
+	}
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	digits := strconv.FormatInt(cents, 10)
+	if len(digits) <= currency.Decimals {
+		digits = strings.Repeat("0", currency.Decimals-len(digits)+1) + digits
+	}
+	units, decimals := digits[:len(digits)-currency.Decimals], digits[len(digits)-currency.Decimals:]
+	for k := len(units) - 3; k > 0; k -= 3 {
+		units = units[:k] + style.thousands + units[k:]
+	}
+	amount := units
+	if decimals != "" {
+		amount += style.decimal + decimals
+	}
+	symbol, ok := currencySymbols[currency.Alpha]
+	if !ok {
+		symbol = currency.Alpha
+	}
+	switch {
+	case symbol == "":
+		return sign + amount
+	case style.after:
+		return sign + amount + " " + symbol
+	case !ok:
+		return sign + symbol + " " + amount
+	}
+	return sign + symbol + amount
+}
+
+// formatAmount formats an amount given in the smallest unit of a currency
+// code. Unknown currencies are assumed to have 2 decimals.
+func formatAmount(cents int64, code, lang string) string {
+	c, ok := LookupCurrency(code)
+	if !ok {
+		c = Currency{Code: code, Decimals: 2}
+	}
+	return FormatAmount(cents, c, lang)
+}
+
+// FormatAmount returns the amount of the payment formatted in lang, or in
+// the language of the buyer if empty (see FormatAmount()).
+func (p *Payment) FormatAmount(lang string) string {
+	if lang == "" {
+		lang = p.Language
+	}
+	return formatAmount(toCents(p.Amount), p.CurrencyCode, lang)
+}
@@ -0,0 +1,50 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"fmt"
+)
+
+// Errors of the SDK binaries, matching an *APIError with errors.Is().
+var (
+	ErrPathfile    = &APIError{Code: "-1"}
+	ErrCertificate = &APIError{Code: "-5"}
+)
+
+// apiErrorCodes are the meanings of the error codes of the SDK binaries.
+var apiErrorCodes = map[string]string{
+	"-1": "pathfile unreadable",
+	"-5": "certificate error",
+}
+
+// APIError is an error returned by the request or response binary.
+type APIError struct {
+	Binary  string // request or response
+	Code    string // Error code, i.e. -1
+	Message string // Error message of the binary, if any
+}
+
+// Meaning returns the documented meaning of the error code.
+func (e *APIError) Meaning() string {
+	if m, ok := apiErrorCodes[e.Code]; ok {
+		return m
+	}
+	return "unknown error"
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("error using API: %s binary: %s (error code %s)", e.Binary, e.Meaning(), e.Code)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Is reports whether target is an API error of the same code.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Code == e.Code
+}
@@ -0,0 +1,84 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"strconv"
+)
+
+// Audited actions.
+const (
+	AuditFiles        = "files.generated"     // Files written by NewSogen()
+	AuditCheckout     = "checkout"            // Request binary run for a transaction
+	AuditNotification = "notification"        // Raw DATA received from the payment server, encrypted
+	AuditParsed       = "notification.parsed" // Payment parsed from DATA
+	AuditParseError   = "notification.error"  // DATA which could not be parsed
+	AuditRefund       = "admin.refund"        // Refund made
+	AuditCapture      = "admin.capture"       // Capture made
+	AuditDeleteAlias  = "admin.alias.deleted" // Wallet alias deleted
+	AuditErasure      = "admin.erasure"       // Customer data erased
+)
+
+// Auditor records an audit trail of the interactions with the payment
+// platform (see the audit package).
+type Auditor interface {
+	Record(action string, details map[string]string) error
+}
+
+// SetAuditor sets the auditor recording all interactions with the
+// payment platform. The files generated by NewSogen() are recorded first,
+// along with their SHA-256 hashes.
+func (s *Sogen) SetAuditor(a Auditor) {
+	s.auditor = a
+	details := make(map[string]string)
+	for _, f := range s.generated {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			details[f] = "error: " + err.Error()
+			continue
+		}
+		sum := sha256.Sum256(data)
+		details[f] = hex.EncodeToString(sum[:])
+	}
+	s.Audit(AuditFiles, details)
+}
+
+// Audit records an action, i.e. an administrative action of the
+// application, with the auditor, if any. Errors are logged: auditing never
+// blocks a payment.
+func (s *Sogen) Audit(action string, details map[string]string) {
+	if s.auditor == nil {
+		return
+	}
+	if err := s.auditor.Record(action, details); err != nil {
+		s.config.logf(LogError, "audit: %s: %s", action, err.Error())
+	}
+}
+
+// auditTransaction returns the audit details of a transaction. Like those
+// of payments, they hold no personal data, which could not be erased from
+// the trail (see ErasureRequest()).
+func auditTransaction(t *Transaction) map[string]string {
+	return map[string]string{
+		"amount":   strconv.FormatInt(toCents(t.amount), 10),
+		"order_id": t.orderId,
+	}
+}
+
+// auditPayment returns the audit details of a payment.
+func auditPayment(p *Payment) map[string]string {
+	return map[string]string{
+		"transaction_id":      p.TransactionId,
+		"payment_date":        p.PaymentDate.Format("20060102150405"),
+		"amount":              strconv.FormatInt(toCents(p.Amount), 10),
+		"response_code":       p.ResponseCode,
+		"bank_response_code":  p.BankResponseCode,
+		"authorisation_id":    p.AuthorizationId,
+		"payment_certificate": p.PaymentCertificate,
+	}
+}
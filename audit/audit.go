@@ -0,0 +1,118 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package audit keeps an append-only, hash-chained audit trail of the
+// interactions with the payment platform, to answer bank disputes months
+// later. Each record holds the hash of the previous one, so that any
+// change to the trail is detected by Verify():
+//
+//	sink, err := audit.NewFileSink("/var/log/sogen/audit.log")
+//	l, err := audit.NewLog(sink)
+//	sogen.SetAuditor(l)
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Record is an entry of the audit trail.
+type Record struct {
+	Seq      int64             `json:"seq"`
+	Time     time.Time         `json:"time"`
+	Action   string            `json:"action"`
+	Details  map[string]string `json:"details"`
+	PrevHash string            `json:"prev_hash"`
+	Hash     string            `json:"hash"`
+}
+
+// Sink stores audit records.
+type Sink interface {
+	// Append stores a record. Records are never updated.
+	Append(r *Record) error
+	// Last returns the last stored record, or nil if none.
+	Last() (*Record, error)
+}
+
+// computeHash returns the hash of a record, which covers all its fields
+// but Hash.
+func computeHash(r *Record) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s\n%s\n%s\n", r.Seq, r.Time.UTC().Format(time.RFC3339Nano), r.Action, r.PrevHash)
+	keys := make([]string, 0, len(r.Details))
+	for k := range r.Details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// JSON-quoted, so that values can't forge other entries
+		b, _ := json.Marshal(r.Details[k])
+		fmt.Fprintf(h, "%s=%s\n", k, b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Log is a hash-chained audit trail. It implements sogenactif.Auditor.
+type Log struct {
+	// Clock is the source of the times of the records, i.e. the Sogen
+	// audited. Defaults to the system time.
+	Clock sogenactif.Clock
+	sink  Sink
+	mu    sync.Mutex
+	last  *Record
+}
+
+// NewLog returns a log appending to sink, chained to its last record.
+func NewLog(sink Sink) (*Log, error) {
+	last, err := sink.Last()
+	if err != nil {
+		return nil, err
+	}
+	return &Log{sink: sink, last: last}, nil
+}
+
+// Record appends a record to the trail. Record times are truncated to the
+// second, the precision of most SQL timestamps.
+func (l *Log) Record(action string, details map[string]string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.Clock != nil {
+		now = l.Clock.Now()
+	}
+	r := &Record{Seq: 1, Time: now.UTC().Truncate(time.Second), Action: action, Details: details}
+	if l.last != nil {
+		r.Seq = l.last.Seq + 1
+		r.PrevHash = l.last.Hash
+	}
+	r.Hash = computeHash(r)
+	if err := l.sink.Append(r); err != nil {
+		return err
+	}
+	l.last = r
+	return nil
+}
+
+// Verify checks the chain of records, in sequence order, and returns an
+// error at the first broken link.
+func Verify(records []*Record) error {
+	var prev *Record
+	for _, r := range records {
+		if r.Hash != computeHash(r) {
+			return errors.New(fmt.Sprintf("record %d: bad hash", r.Seq))
+		}
+		if prev != nil && (r.Seq != prev.Seq+1 || r.PrevHash != prev.Hash) {
+			return errors.New(fmt.Sprintf("record %d: not chained to record %d", r.Seq, prev.Seq))
+		}
+		prev = r
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileSink stores records as JSON lines in an append-only file.
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+}
+
+// NewFileSink opens (or creates) the audit file at path.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{path: path, f: f}, nil
+}
+
+func (s *FileSink) Append(r *Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+func (s *FileSink) Last() (*Record, error) {
+	records, err := s.Records()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return records[len(records)-1], nil
+}
+
+// Records reads all records of the file, i.e. to Verify() them.
+func (s *FileSink) Records() ([]*Record, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadRecords(f)
+}
+
+// ReadRecords reads JSON lines records.
+func ReadRecords(r io.Reader) ([]*Record, error) {
+	records := make([]*Record, 0)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		rec := new(Record)
+		if err := json.Unmarshal(sc.Bytes(), rec); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, sc.Err()
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,87 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SQLSink stores records in a SQL table, created with:
+//
+//	CREATE TABLE audit (
+//		seq       BIGINT PRIMARY KEY,
+//		time      TIMESTAMP NOT NULL,
+//		action    VARCHAR(64) NOT NULL,
+//		details   TEXT NOT NULL,
+//		prev_hash CHAR(64) NOT NULL,
+//		hash      CHAR(64) NOT NULL
+//	)
+//
+// The database user should only be granted INSERT and SELECT on the table.
+type SQLSink struct {
+	db    *sql.DB
+	table string
+	// Placeholder returns the n-th (from 1) query placeholder of the
+	// driver. Defaults to ?; use i.e. func(n int) string { return
+	// fmt.Sprintf("$%d", n) } for PostgreSQL.
+	Placeholder func(n int) string
+}
+
+// NewSQLSink returns a sink storing records in table.
+func NewSQLSink(db *sql.DB, table string) *SQLSink {
+	return &SQLSink{db: db, table: table, Placeholder: func(int) string { return "?" }}
+}
+
+func (s *SQLSink) Append(r *Record) error {
+	details, err := json.Marshal(r.Details)
+	if err != nil {
+		return err
+	}
+	p := s.Placeholder
+	q := fmt.Sprintf("INSERT INTO %s (seq, time, action, details, prev_hash, hash) VALUES (%s, %s, %s, %s, %s, %s)",
+		s.table, p(1), p(2), p(3), p(4), p(5), p(6))
+	_, err = s.db.Exec(q, r.Seq, r.Time, r.Action, string(details), r.PrevHash, r.Hash)
+	return err
+}
+
+func (s *SQLSink) Last() (*Record, error) {
+	rows, err := s.query("ORDER BY seq DESC LIMIT 1")
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+// Records returns all records, in sequence order.
+func (s *SQLSink) Records() ([]*Record, error) {
+	return s.query("ORDER BY seq")
+}
+
+func (s *SQLSink) query(clause string) ([]*Record, error) {
+	rows, err := s.db.Query(fmt.Sprintf("SELECT seq, time, action, details, prev_hash, hash FROM %s %s",
+		s.table, clause))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records := make([]*Record, 0)
+	for rows.Next() {
+		r := new(Record)
+		var details string
+		var t time.Time
+		if err := rows.Scan(&r.Seq, &t, &r.Action, &details, &r.PrevHash, &r.Hash); err != nil {
+			return nil, err
+		}
+		r.Time = t.UTC()
+		if err := json.Unmarshal([]byte(details), &r.Details); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
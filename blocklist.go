@@ -0,0 +1,319 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kinds of Blocklist entries.
+const (
+	ListCustomer    = "customer"     // Customer IDs
+	ListEmailDomain = "email_domain" // Domains of customer emails
+	ListIP          = "ip"           // IP addresses or CIDR ranges
+)
+
+// Maximum size of the body of a request to the Handler() of a Blocklist.
+const maxBlocklistBody = 1 << 16
+
+// Blocklist is a FraudPolicy denying listed customers, email domains and
+// IP addresses. Entries can be blocked or allowed: allowed transactions
+// and payments skip the Next policy, others are checked by the Next
+// policy, if any. Lists can be changed at runtime, and saved to a file
+// (see OpenBlocklist()).
+type Blocklist struct {
+	Next    FraudPolicy
+	mu      sync.RWMutex
+	blocked *entryList
+	allowed *entryList
+	path    string // JSON file of the lists, if any
+}
+
+// entryList holds the entries of a list by kind, and the parsed IP ranges.
+type entryList struct {
+	entries map[string]map[string]bool
+	nets    map[string]*net.IPNet // ListIP entries
+}
+
+func newEntryList() *entryList {
+	l := &entryList{entries: make(map[string]map[string]bool), nets: make(map[string]*net.IPNet)}
+	for _, k := range []string{ListCustomer, ListEmailDomain, ListIP} {
+		l.entries[k] = make(map[string]bool)
+	}
+	return l
+}
+
+// NewBlocklist returns empty lists, kept in memory.
+func NewBlocklist(next FraudPolicy) *Blocklist {
+	return &Blocklist{Next: next, blocked: newEntryList(), allowed: newEntryList()}
+}
+
+// OpenBlocklist returns the lists of a JSON file, as served by Handler().
+// Every change is saved to the file, which is created on the first change
+// if it does not exist.
+func OpenBlocklist(path string, next FraudPolicy) (*Blocklist, error) {
+	b := NewBlocklist(next)
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		var e blocklistEntries
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, errors.New("blocklist " + path + ": " + err.Error())
+		}
+		for _, l := range []struct {
+			list    *entryList
+			entries map[string][]string
+		}{{b.blocked, e.Blocked}, {b.allowed, e.Allowed}} {
+			for kind, values := range l.entries {
+				for _, v := range values {
+					if _, err := l.list.set(kind, v, true); err != nil {
+						return nil, errors.New("blocklist " + path + ": " + err.Error())
+					}
+				}
+			}
+		}
+	}
+	b.path = path
+	return b, nil
+}
+
+// normalizeEntry checks and normalizes an entry. The IP range of a ListIP
+// entry is returned too.
+func normalizeEntry(kind, value string) (string, *net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil, errors.New("empty entry")
+	}
+	switch kind {
+	case ListCustomer:
+		return value, nil, nil
+	case ListEmailDomain:
+		return strings.ToLower(strings.TrimPrefix(value, "@")), nil, nil
+	case ListIP:
+		nets, err := parseRanges([]string{value})
+		if err != nil {
+			return "", nil, err
+		}
+		return nets[0].String(), nets[0], nil
+	}
+	return "", nil, errors.New("unknown list " + kind)
+}
+
+// set adds or removes an entry. It reports whether the list changed.
+func (l *entryList) set(kind, value string, on bool) (bool, error) {
+	v, n, err := normalizeEntry(kind, value)
+	if err != nil {
+		return false, err
+	}
+	if l.entries[kind][v] == on {
+		return false, nil
+	}
+	if on {
+		l.entries[kind][v] = true
+		if n != nil {
+			l.nets[v] = n
+		}
+	} else {
+		delete(l.entries[kind], v)
+		delete(l.nets, v)
+	}
+	return true, nil
+}
+
+func (b *Blocklist) set(l *entryList, kind, value string, on bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	changed, err := l.set(kind, value, on)
+	if err != nil || !changed || b.path == "" {
+		return err
+	}
+	if err := b.save(); err != nil {
+		// Keep the lists as saved
+		l.set(kind, value, !on)
+		return err
+	}
+	return nil
+}
+
+// save writes the lists to their file. b.mu must be held.
+func (b *Blocklist) save() error {
+	data, err := json.MarshalIndent(b.entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := b.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+// Block adds an entry to the blocked list of a kind (see ListCustomer
+// etc.).
+func (b *Blocklist) Block(kind, value string) error {
+	return b.set(b.blocked, kind, value, true)
+}
+
+// Unblock removes an entry from the blocked list of a kind.
+func (b *Blocklist) Unblock(kind, value string) error {
+	return b.set(b.blocked, kind, value, false)
+}
+
+// Allow adds an entry to the allowed list of a kind.
+func (b *Blocklist) Allow(kind, value string) error {
+	return b.set(b.allowed, kind, value, true)
+}
+
+// Disallow removes an entry from the allowed list of a kind.
+func (b *Blocklist) Disallow(kind, value string) error {
+	return b.set(b.allowed, kind, value, false)
+}
+
+// match returns the first entry of the list matching the customer ID,
+// email or IP address, if any.
+func (l *entryList) match(id, email, ip string) (string, bool) {
+	if id != "" && l.entries[ListCustomer][id] {
+		return "customer " + id, true
+	}
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		domain := strings.ToLower(email[i+1:])
+		if l.entries[ListEmailDomain][domain] {
+			return "email domain " + domain, true
+		}
+	}
+	if addr := net.ParseIP(ip); addr != nil {
+		for _, n := range l.nets {
+			if n.Contains(addr) {
+				return "IP " + ip, true
+			}
+		}
+	}
+	return "", false
+}
+
+// check returns a verdict for the lists, or nil to defer to the Next
+// policy.
+func (b *Blocklist) check(id, email, ip string) *Verdict {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if what, ok := b.blocked.match(id, email, ip); ok {
+		return &Verdict{Deny, "blocked " + what}
+	}
+	if what, ok := b.allowed.match(id, email, ip); ok {
+		return &Verdict{Allow, "allowed " + what}
+	}
+	return nil
+}
+
+func (b *Blocklist) CheckTransaction(t *Transaction) (*Verdict, error) {
+	if v := b.check(t.customer.Id, t.customer.Email, t.customer.IpAddress); v != nil {
+		return v, nil
+	}
+	if b.Next != nil {
+		return b.Next.CheckTransaction(t)
+	}
+	return &Verdict{Decision: Allow}, nil
+}
+
+func (b *Blocklist) CheckPayment(p *Payment) (*Verdict, error) {
+	if v := b.check(p.CustomerId, p.CustomerEmail, p.CustomerIpAddress); v != nil {
+		return v, nil
+	}
+	if b.Next != nil {
+		return b.Next.CheckPayment(p)
+	}
+	return &Verdict{Decision: Allow}, nil
+}
+
+// blocklistEntries is the JSON representation of the lists.
+type blocklistEntries struct {
+	Blocked map[string][]string `json:"blocked"`
+	Allowed map[string][]string `json:"allowed"`
+}
+
+// entries returns the JSON representation of the lists. b.mu must be held.
+func (b *Blocklist) entries() *blocklistEntries {
+	return &blocklistEntries{Blocked: b.blocked.sorted(), Allowed: b.allowed.sorted()}
+}
+
+// sorted returns the entries of the list by kind, sorted.
+func (l *entryList) sorted() map[string][]string {
+	m := make(map[string][]string)
+	for kind, entries := range l.entries {
+		m[kind] = make([]string, 0)
+		for v := range entries {
+			m[kind] = append(m[kind], v)
+		}
+		sort.Strings(m[kind])
+	}
+	return m
+}
+
+// Handler returns a handler to manage the lists at runtime:
+//
+//	GET                                     returns all entries as JSON
+//	POST   list=blocked|allowed&kind=&value=  adds an entry
+//	DELETE list=blocked|allowed&kind=&value=  removes an entry
+//
+// The parameters of POST and DELETE are form-encoded in the body of the
+// request. It should be protected by the application (i.e. admin access
+// only).
+func (b *Blocklist) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			b.mu.RLock()
+			e := b.entries()
+			b.mu.RUnlock()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(e)
+			return
+		}
+		if r.Method != "POST" && r.Method != "DELETE" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// Request.ParseForm() ignores the body of DELETE requests
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBlocklistBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var l *entryList
+		switch form.Get("list") {
+		case "blocked":
+			l = b.blocked
+		case "allowed":
+			l = b.allowed
+		default:
+			http.Error(w, "list must be blocked or allowed", http.StatusBadRequest)
+			return
+		}
+		if _, _, err := normalizeEntry(form.Get("kind"), form.Get("value")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := b.set(l, form.Get("kind"), form.Get("value"), r.Method == "POST"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
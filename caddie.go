@@ -0,0 +1,64 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxCaddieLength is the maximum length of the caddie field.
+const MaxCaddieLength = 2048
+
+// ErrCaddieTooLong is returned for a caddie longer than MaxCaddieLength.
+var ErrCaddieTooLong = errors.New(fmt.Sprintf("caddie longer than %d chars", MaxCaddieLength))
+
+// Caddie is the content of the caddie field of a transaction, sent back
+// unmodified with the payment. Values encoded with FromJSON are safe to
+// send: they hold no separator of the payment server.
+type Caddie string
+
+// FromJSON sets the caddie to the JSON encoding of v, as unpadded
+// base64url.
+func (c *Caddie) FromJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	enc := Caddie(base64.RawURLEncoding.EncodeToString(data))
+	if len(enc) > MaxCaddieLength {
+		return ErrCaddieTooLong
+	}
+	*c = enc
+	return nil
+}
+
+// ToJSON decodes a caddie set with FromJSON into v. Use it on the Caddie
+// field of a payment:
+//
+//	var cart Cart
+//	err := sogenactif.Caddie(p.Caddie).ToJSON(&cart)
+func (c Caddie) ToJSON(v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(string(c))
+	if err != nil {
+		return errors.New("bad caddie encoding: " + err.Error())
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ValidateCaddie checks that a caddie can be sent to the payment server
+// and comes back unmodified.
+func ValidateCaddie(s string) error {
+	if len(s) > MaxCaddieLength {
+		return ErrCaddieTooLong
+	}
+	if strings.ContainsAny(s, "!\n\r") {
+		return errors.New("caddie contains a ! separator or a line break, encode it with Caddie.FromJSON")
+	}
+	return nil
+}
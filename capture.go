@@ -0,0 +1,151 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// CaptureMode defines how a transaction is sent to the bank.
+type CaptureMode string
+
+const (
+	// The transaction is sent to the bank automatically after capture
+	// day(s). This is the default.
+	CaptureAuthor CaptureMode = "AUTHOR_CAPTURE"
+	// The transaction is sent to the bank only once validated by the
+	// merchant (see Capture()). It expires if not validated within
+	// capture day(s).
+	CaptureValidation CaptureMode = "VALIDATION"
+	// Payment in N times (see SetInstallments()).
+	CapturePaymentN CaptureMode = captureModePaymentN
+)
+
+// Maximum number of days before sending a transaction to the bank.
+const maxCaptureDay = 99
+
+// CaptureResult is the record of a capture made on a payment.
+type CaptureResult struct {
+	TransactionId string
+	PaymentDate   time.Time // Date of the captured payment
+	Amount        int64     // Captured amount, in cents
+	Remaining     int64     // Authorized amount that can still be captured, in cents
+	Status        string    // Transaction status after the capture
+	Date          time.Time
+}
+
+// SetCapture sets the capture mode and the number of days before the
+// transaction is sent to the bank (or expires, in validation mode).
+func (t *Transaction) SetCapture(mode CaptureMode, days int) error {
+	if mode != CaptureAuthor && mode != CaptureValidation {
+		return errors.New(fmt.Sprintf("capture: unsupported capture mode %s", mode))
+	}
+	if days < 0 || days > maxCaptureDay {
+		return errors.New(fmt.Sprintf("capture: capture day must be between 0 and %d", maxCaptureDay))
+	}
+	if t.installments != nil {
+		return errors.New("capture: capture mode can't be changed for a payment in N times")
+	}
+	t.captureMode = mode
+	t.captureDay = days
+	return nil
+}
+
+// Capture sends amountCents of an authorized payment to the bank. It applies
+// to payments made in validation mode (see SetCapture()). Several partial
+// captures can be made (i.e for split shipments) up to the authorized
+// amount. A zero amount captures everything that has not been captured yet.
+// The payment is looked up in the payment store.
+func (s *Sogen) Capture(ctx context.Context, transactionId string, amountCents int64) (*CaptureResult, error) {
+	if s.operator == nil {
+		return nil, errors.New("capture: no operator")
+	}
+	if s.payments == nil {
+		return nil, errors.New("capture: no payment store")
+	}
+	if amountCents < 0 {
+		return nil, errors.New("capture: negative amount")
+	}
+	p, err := s.payments.Payment(transactionId)
+	if err != nil {
+		return nil, err
+	}
+	if p.ResponseCode != "00" {
+		return nil, errors.New(fmt.Sprintf("capture: payment %s was not accepted", transactionId))
+	}
+	if p.CaptureMode != CaptureValidation {
+		return nil, errors.New(fmt.Sprintf("capture: payment %s is not in validation mode", transactionId))
+	}
+
+	s.opsMu.Lock()
+	defer s.opsMu.Unlock()
+
+	previous, err := s.payments.Captures(transactionId)
+	if err != nil {
+		return nil, err
+	}
+	remaining := toCents(p.Amount)
+	for _, c := range previous {
+		if c.PaymentDate.Equal(p.PaymentDate) {
+			remaining -= c.Amount
+		}
+	}
+	if remaining <= 0 {
+		return nil, errors.New(fmt.Sprintf("capture: payment %s already fully captured", transactionId))
+	}
+	if amountCents == 0 {
+		amountCents = remaining
+	}
+	if amountCents > remaining {
+		return nil, errors.New(fmt.Sprintf("capture: amount %d exceeds authorized amount left %d", amountCents, remaining))
+	}
+
+	status, err := s.operator.Capture(ctx, transactionId, p.PaymentDate, amountCents, p.CurrencyCode)
+	if err != nil {
+		return nil, err
+	}
+	c := &CaptureResult{
+		TransactionId: transactionId,
+		PaymentDate:   p.PaymentDate,
+		Amount:        amountCents,
+		Remaining:     remaining - amountCents,
+		Status:        status,
+		Date:          s.now(),
+	}
+	if err := s.payments.SaveCapture(c); err != nil {
+		return c, errors.New("capture done but not recorded: " + err.Error())
+	}
+	s.Audit(AuditCapture, map[string]string{
+		"transaction_id": c.TransactionId,
+		"payment_date":   c.PaymentDate.Format("20060102150405"),
+		"amount":         strconv.FormatInt(c.Amount, 10),
+		"status":         c.Status,
+	})
+	return c, nil
+}
+
+// CapturesOn returns the day the payment is sent to the bank, capture
+// day(s) after the payment date, in the timezone of the payment server.
+// In validation mode, it is the day the payment expires if not captured.
+func (p *Payment) CapturesOn() time.Time {
+	y, m, d := p.PaymentDate.Date()
+	return time.Date(y, m, d+p.CaptureDay, 0, 0, 0, 0, p.PaymentDate.Location())
+}
+
+// SettlesOn returns the day the funds of the payment settle, given the
+// settlement delay of the bank in days after capture.
+func (p *Payment) SettlesOn(captureDelay int) time.Time {
+	return p.CapturesOn().AddDate(0, 0, captureDelay)
+}
+
+// Age returns the time elapsed since the payment at now, i.e.
+// Sogen.Now().
+func (p *Payment) Age(now time.Time) time.Duration {
+	return now.Sub(p.PaymentDate)
+}
@@ -0,0 +1,209 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CertificateSource provides the merchant certificate (the content of the
+// certif.<country>.<merchant_id>.php file) from a secrets backend. See the
+// sogenvault and sogenaws packages.
+type CertificateSource interface {
+	Certificate(ctx context.Context) ([]byte, error)
+}
+
+// EnvCertificate is a CertificateSource reading the base64-encoded
+// certificate from an environment variable.
+type EnvCertificate string
+
+func (e EnvCertificate) Certificate(ctx context.Context) ([]byte, error) {
+	v := os.Getenv(string(e))
+	if v == "" {
+		return nil, errors.New("env var " + string(e) + " not defined")
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+}
+
+// writeCertificate materializes the certificate provided by src into the
+// merchant directory, which is created if needed.
+func writeCertificate(src CertificateSource, certFile string) error {
+	data, err := src.Certificate(context.Background())
+	if err != nil {
+		return errors.New("certificate source: " + err.Error())
+	}
+	return writeCertificateData(data, certFile)
+}
+
+func writeCertificateData(data []byte, certFile string) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return errors.New("empty certificate")
+	}
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(certFile, data, 0600); err != nil {
+		return err
+	}
+	return nil
+}
+
+// certificateFile returns the path of the certificate file of the merchant.
+func certificateFile(c *Config) string {
+	return filepath.Join(c.MerchantsRootDir, c.MerchantId, fmt.Sprintf("certif.%s.%s.php", c.MerchantCountry, c.MerchantId))
+}
+
+// resolveCertificate returns the certificate file of the merchant. The
+// expected certif.<country>.<merchant_id>.php is used if it exists. Else,
+// the certificates issued with another country tag (i.e. for extension
+// files) are looked for: a single one is used as is, and among several,
+// the one whose header is for the country of the merchant.
+func resolveCertificate(c *Config) (string, error) {
+	certFile := certificateFile(c)
+	fi, err := os.Lstat(certFile)
+	if err == nil && fi.Mode()&os.ModeSymlink == 0 {
+		return certFile, nil
+	}
+	// A link is one of linkCertificate(), resolved again
+	if err == nil {
+		err = os.ErrNotExist
+	}
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(certFile), "certif.*."+c.MerchantId+"*"))
+	candidates := make([]string, 0)
+	for _, name := range matches {
+		if name != certFile {
+			candidates = append(candidates, name)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return "", &ErrMissingCertificate{Path: certFile, Err: err}
+	case 1:
+		return candidates[0], nil
+	}
+	match := make([]string, 0)
+	for _, name := range candidates {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		fields := certificateFields(data)
+		if fields["merchant_id"] == c.MerchantId && fields["merchant_country"] == c.MerchantCountry {
+			match = append(match, name)
+		}
+	}
+	if len(match) != 1 {
+		return "", &ErrMissingCertificate{Path: certFile, Err: err, Candidates: candidates}
+	}
+	return match[0], nil
+}
+
+// linkCertificate makes a certificate found under another name available
+// at the expected name, the only one the SDK binaries look for.
+func linkCertificate(certFile, expected string) error {
+	if fi, err := os.Lstat(expected); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		os.Remove(expected)
+	}
+	if err := os.Symlink(filepath.Base(certFile), expected); err != nil {
+		return err
+	}
+	return nil
+}
+
+// BootstrapMerchantDir builds the merchant directory expected by NewSogen()
+// under c.MerchantsRootDir (i.e. an emptyDir volume) from the certificate,
+// as found in a single mounted secret. The certificate is the content of
+// the certif.<country>.<merchant_id>.php file, in its PHP or PEM form; it
+// is written as is. NewSogen() writes the other files.
+func BootstrapMerchantDir(c *Config, cert []byte) error {
+	if c == nil {
+		return errors.New("nil config")
+	}
+	if strings.TrimSpace(c.MerchantsRootDir) == "" {
+		return errors.New("missing merchant root directory")
+	}
+	if strings.TrimSpace(c.MerchantId) == "" || strings.TrimSpace(c.MerchantCountry) == "" {
+		return errors.New("missing merchant ID or country")
+	}
+	c.MerchantsRootDir = strings.TrimSpace(c.MerchantsRootDir)
+	c.MerchantId = strings.TrimSpace(c.MerchantId)
+	return writeCertificateData(cert, certificateFile(c))
+}
+
+// certificateFields returns the header fields of a certificate file
+// (merchant_id, merchant_country, certificate_expired etc.).
+func certificateFields(data []byte) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.Split(strings.TrimSpace(line), "!")
+		if len(parts) == 3 && parts[2] == "" {
+			fields[parts[0]] = parts[1]
+		}
+	}
+	return fields
+}
+
+// verifyCertificate checks that the certificate is the one of the merchant
+// of the config. An expired certificate is only logged: the payment server
+// has the last word.
+func verifyCertificate(c *Config, data []byte) error {
+	fields := certificateFields(data)
+	if id, ok := fields["merchant_id"]; ok && id != c.MerchantId {
+		return errors.New(fmt.Sprintf("certificate of merchant %s, not %s", id, c.MerchantId))
+	}
+	if country, ok := fields["merchant_country"]; ok && country != c.MerchantCountry {
+		return errors.New(fmt.Sprintf("certificate for country %s, not %s", country, c.MerchantCountry))
+	}
+	if exp, err := time.Parse("20060102", fields["certificate_expired"]); err == nil && exp.Before(c.now()) {
+		c.logf(LogWarning, "Warning: certificate of merchant %s expired on %s", redact(c.MerchantId), exp.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// checkCertificate validates the certificate of the merchant without
+// writing it: it is read from the certificate source if any, from the
+// merchant directory otherwise.
+func checkCertificate(c *Config) error {
+	certFile := certificateFile(c)
+	var data []byte
+	var err error
+	switch {
+	case c.CertificateSource != nil:
+		data, err = c.CertificateSource.Certificate(context.Background())
+		if err != nil {
+			return errors.New("certificate source: " + err.Error())
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			return errors.New("certificate source: empty certificate")
+		}
+	case c.MerchantsStore != nil:
+		data, err = c.MerchantsStore.Get(context.Background(), path.Join(c.MerchantId, filepath.Base(certFile)))
+		if err != nil {
+			return errors.New("object store: " + err.Error())
+		}
+	default:
+		if certFile, err = resolveCertificate(c); err != nil {
+			return err
+		}
+		data, err = ioutil.ReadFile(certFile)
+		if err != nil {
+			return &ErrMissingCertificate{Path: certFile, Err: err}
+		}
+	}
+	if err := verifyCertificate(c, data); err != nil {
+		return errors.New(certFile + ": " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+)
+
+// OrderChannel is the channel used by the buyer to place an order.
+type OrderChannel string
+
+const (
+	ChannelInternet  OrderChannel = "INTERNET" // Default
+	ChannelMOTO      OrderChannel = "MOTO"     // Mail or telephone order
+	ChannelTelephone OrderChannel = "TELEPHONE_ORDER"
+	ChannelMail      OrderChannel = "MAIL_ORDER"
+	ChannelIVR       OrderChannel = "IVR" // Interactive voice response
+)
+
+// SetOrderChannel sets the channel used to place the order. Use ChannelMOTO
+// (or a more specific channel) for orders keyed in by an operator.
+func (t *Transaction) SetOrderChannel(c OrderChannel) error {
+	switch c {
+	case ChannelInternet, ChannelMOTO, ChannelTelephone, ChannelMail, ChannelIVR:
+		t.orderChannel = c
+		return nil
+	}
+	return errors.New("unknown order channel " + string(c))
+}
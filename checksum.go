@@ -0,0 +1,57 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// BinaryChecksums holds the SHA-256 checksums of the request and response
+// binaries of the SDK, per platform (as in lib/).
+var BinaryChecksums = map[string]string{
+	"linux_386/request":    "91d7b4acc279eec7b09d854deb7db332fcf9469c2d05974b4166f26f289d662d",
+	"linux_386/response":   "f15c3011c87991af2d543f0755e0185b3a71f1caacb68110d0bdf730b87b79d4",
+	"linux_amd64/request":  "7a18dc10835fd165df21a3d0f4c53a20085b013371d5a10db8784de5885330bd",
+	"linux_amd64/response": "84d3beb4739ca306d94c8aadb2234e6b63825c615b95e47780841576318c6999",
+}
+
+// fileChecksum returns the hex SHA-256 checksum of a file.
+func fileChecksum(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyBinary checks the checksum of a binary of the platform, if known.
+// The checksums of the config take precedence over BinaryChecksums.
+func verifyBinary(c *Config, platform, name, file string) error {
+	key := platform + "/" + name
+	want, ok := c.BinaryChecksums[key]
+	if !ok {
+		want, ok = BinaryChecksums[key]
+	}
+	if !ok {
+		c.logf(LogWarning, "No known checksum for the %s binary, not verified", key)
+		return nil
+	}
+	got, err := fileChecksum(file)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return &ErrBadBinary{file, got, want}
+	}
+	return nil
+}
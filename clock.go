@@ -0,0 +1,40 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"time"
+)
+
+// Clock is the source of the current time, which can be frozen in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// FixedClock is a clock frozen at a given time.
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// now returns the current time of the clock of the config, if any.
+func (c *Config) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock.Now()
+	}
+	return time.Now()
+}
+
+// now returns the current time of the clock of the merchant.
+func (s *Sogen) now() time.Time {
+	return s.config.now()
+}
+
+// Now returns the current time of the clock of the merchant: a Sogen is
+// the Clock of the handlers and stores built around it.
+func (s *Sogen) Now() time.Time {
+	return s.now()
+}
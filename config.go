@@ -7,11 +7,11 @@ package sogenactif
 import (
 	"errors"
 	"fmt"
-	"github.com/outofpluto/goconfig/config"
 	"net/url"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // replaceEnvVars replaces all ${VARNAME} with their value
@@ -32,12 +32,13 @@ func replaceEnvVars(src string) (string, error) {
 	return src, nil
 }
 
+// escapedEnvVars matches the ${VARNAME} escaped in the path of a URL.
+var escapedEnvVars = regexp.MustCompile(`\$%7B([A-Z_]+)%7D`)
+
+// handleQuery replaces the env vars of a URL. The rest of the URL is kept
+// as is: escaped characters of the query string are not decoded.
 func handleQuery(uri *url.URL) (*url.URL, error) {
-	qs, err := url.QueryUnescape(uri.String())
-	if err != nil {
-		return nil, err
-	}
-	r, err := replaceEnvVars(qs)
+	r, err := replaceEnvVars(escapedEnvVars.ReplaceAllString(uri.String(), "$${$1}"))
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +114,36 @@ func handleEnvVars(c *Config) error {
 		}
 		c.AutoResponseUrl = curi
 	}
+
+	// base_url
+	if c.BaseUrl != nil {
+		curi, err := handleQuery(c.BaseUrl)
+		if err != nil {
+			return err
+		}
+		if !curi.IsAbs() {
+			return errors.New("base URL must be absolute")
+		}
+		c.BaseUrl = curi
+	}
+
+	// payment_link_url
+	if c.PaymentLinkUrl != nil {
+		curi, err := handleQuery(c.PaymentLinkUrl)
+		if err != nil {
+			return err
+		}
+		c.PaymentLinkUrl = curi
+	}
+
+	// webhook_url
+	if c.WebhookUrl != nil {
+		curi, err := handleQuery(c.WebhookUrl)
+		if err != nil {
+			return err
+		}
+		c.WebhookUrl = curi
+	}
 	return nil
 }
 
@@ -123,10 +154,15 @@ func handleEnvVars(c *Config) error {
 func LoadConfig(path string) (*Config, error) {
 	settings := &Config{}
 
-	c, err := config.ReadDefault(path)
+	raw, err := ReadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newEnvConfig(raw)
 	if err != nil {
 		return nil, err
 	}
+	settings.Environment = c.env
 
 	// debug
 	var b bool
@@ -135,12 +171,11 @@ func LoadConfig(path string) (*Config, error) {
 	}
 	settings.Debug = b
 
-	// logo_path
-	var logPath string
-	if logPath, err = c.String("sogenactif", "logo_path"); err != nil {
-		return nil, err
+	// logo_path (optional)
+	settings.LogoPath = DefaultLogoPath
+	if logPath, err := c.String("sogenactif", "logo_path"); err == nil && logPath != "" {
+		settings.LogoPath = logPath
 	}
-	settings.LogoPath = logPath
 
 	// merchants_rootdir
 	var mRootDir string
@@ -149,17 +184,21 @@ func LoadConfig(path string) (*Config, error) {
 	}
 	settings.MerchantsRootDir = mRootDir
 
-	// media_path
-	var mediaPath string
-	if mediaPath, err = c.String("sogenactif", "media_path"); err != nil {
-		return nil, err
+	// media_path (optional)
+	if mediaPath, err := c.String("sogenactif", "media_path"); err == nil {
+		settings.MediaPath = mediaPath
 	}
-	settings.MediaPath = mediaPath
 
 	// merchant_id
 	var merchantId string
 	if merchantId, err = c.String("sogenactif", "merchant_id"); err != nil {
-		return nil, err
+		// Optional with merchant sections
+		switch {
+		case c.env == EnvTest:
+			merchantId = TestMerchantId
+		case !hasMerchants(c):
+			return nil, err
+		}
 	}
 	settings.MerchantId = merchantId
 
@@ -170,6 +209,11 @@ func LoadConfig(path string) (*Config, error) {
 	}
 	settings.LibraryPath = libPath
 
+	// library_platform (optional)
+	if p, err := c.String("sogenactif", "library_platform"); err == nil {
+		settings.LibraryPlatform = strings.TrimSpace(p)
+	}
+
 	// merchant_country
 	var merchantCountry string
 	if merchantCountry, err = c.String("sogenactif", "merchant_country"); err != nil {
@@ -214,12 +258,136 @@ func LoadConfig(path string) (*Config, error) {
 		settings.AutoResponseUrl = cUrl
 	}
 
+	// payment_link_url (optional)
+	uri, err = c.String("sogenactif", "payment_link_url")
+	if err == nil {
+		if cUrl, err = url.Parse(uri); err != nil {
+			return nil, errors.New(fmt.Sprint("payment link URL: ", err.Error()))
+		}
+		settings.PaymentLinkUrl = cUrl
+	}
+
+	// base_url (optional)
+	uri, err = c.String("sogenactif", "base_url")
+	if err == nil {
+		if cUrl, err = url.Parse(uri); err != nil {
+			return nil, errors.New(fmt.Sprint("base URL: ", err.Error()))
+		}
+		settings.BaseUrl = cUrl
+	}
+
+	// webhook_url (optional)
+	uri, err = c.String("sogenactif", "webhook_url")
+	if err == nil {
+		if cUrl, err = url.Parse(uri); err != nil {
+			return nil, errors.New(fmt.Sprint("webhook URL: ", err.Error()))
+		}
+		settings.WebhookUrl = cUrl
+	}
+
+	// [merchant "<id>"] sections (optional)
+	if settings.Merchants, err = loadMerchants(c); err != nil {
+		return nil, err
+	}
+
+	// [fx_rates] section (optional)
+	if settings.FXRates, err = loadRates(c); err != nil {
+		return nil, err
+	}
+
+	// [payment_mean "<code>"] sections (optional)
+	if settings.CustomMeans, err = loadPaymentMeans(c); err != nil {
+		return nil, err
+	}
+
+	// retention_days (optional)
+	if c.HasOption("sogenactif", "retention_days") {
+		days, err := c.Int("sogenactif", "retention_days")
+		if err != nil {
+			return nil, errors.New("retention_days: " + err.Error())
+		}
+		if days < 0 {
+			return nil, errors.New("retention_days: negative value")
+		}
+		settings.RetentionDays = days
+	}
+
+	// min_amount (optional)
+	if c.HasOption("sogenactif", "min_amount") {
+		a, err := c.Float("sogenactif", "min_amount")
+		if err != nil || validateAmount(a) != nil {
+			return nil, errors.New("min_amount: expected a positive amount")
+		}
+		settings.MinAmount = a
+	}
+
+	// max_amount (optional)
+	if c.HasOption("sogenactif", "max_amount") {
+		a, err := c.Float("sogenactif", "max_amount")
+		if err != nil || validateAmount(a) != nil {
+			return nil, errors.New("max_amount: expected a positive amount")
+		}
+		settings.MaxAmount = a
+	}
+	if settings.MinAmount > 0 && settings.MaxAmount > 0 && settings.MinAmount > settings.MaxAmount {
+		return nil, errors.New("min_amount above max_amount")
+	}
+
+	// timezone (optional)
+	if tz, err := c.String("sogenactif", "timezone"); err == nil && strings.TrimSpace(tz) != "" {
+		loc, err := time.LoadLocation(strings.TrimSpace(tz))
+		if err != nil {
+			return nil, errors.New("timezone: " + err.Error())
+		}
+		settings.Location = loc
+	}
+
+	// check_urls (optional)
+	if c.HasOption("sogenactif", "check_urls") {
+		if b, err = c.Bool("sogenactif", "check_urls"); err != nil {
+			return nil, errors.New("check_urls: " + err.Error())
+		}
+		settings.CheckUrls = b
+	}
+
+	// log_level (optional)
+	if name, err := c.String("sogenactif", "log_level"); err == nil && strings.TrimSpace(name) != "" {
+		if settings.LogLevel, err = ParseLogLevel(name); err != nil {
+			return nil, errors.New("log_level: " + err.Error())
+		}
+	}
+
+	// certificate_env (optional)
+	if name, err := c.String("sogenactif", "certificate_env"); err == nil && name != "" {
+		settings.CertificateSource = EnvCertificate(name)
+	}
+
+	// auto_response_ips (optional)
+	if ips, err := c.String("sogenactif", "auto_response_ips"); err == nil && strings.TrimSpace(ips) != "" {
+		settings.AutoResponseIPs = strings.Split(ips, ",")
+	}
+
+	// trusted_proxies (optional)
+	if proxies, err := c.String("sogenactif", "trusted_proxies"); err == nil && strings.TrimSpace(proxies) != "" {
+		settings.TrustedProxies = strings.Split(proxies, ",")
+	}
+
 	// Looks for env variables, perform substitutions if needed
 	if err := handleEnvVars(settings); err != nil {
 		return nil, err
 	}
 
-	// Set default values for parmcom.sogenactif.
+	setDefaults(settings)
+
+	// payment_means (optional, after the defaults)
+	if means, err := c.String("sogenactif", "payment_means"); err == nil && strings.TrimSpace(means) != "" {
+		settings.PaymentMeans = strings.Replace(means, " ", "", -1)
+	}
+	return settings, nil
+}
+
+// setDefaults sets default values for parmcom.sogenactif.
+func setDefaults(settings *Config) {
 	settings.Advert = "sg.gif"
 	settings.BgColor = "ffffff"
 	settings.BlockAlign = "center"
@@ -231,6 +399,4 @@ func LoadConfig(path string) (*Config, error) {
 	settings.PaymentMeans = "CB,2,VISA,2,MASTERCARD,2,PAYLIB,2"
 	settings.Target = "_top"
 	settings.TextColor = "000000"
-
-	return settings, nil
 }
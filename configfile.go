@@ -0,0 +1,199 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultSection holds the options inherited by all sections of a config
+// file.
+const DefaultSection = "DEFAULT"
+
+// ConfigFile is a parsed INI file. Sections start with a [name] header,
+// options are set as name=value or name: value. Lines starting with # or ;
+// are comments; indented lines continue the value of the previous option.
+// An include path line reads another file in place, its path being
+// relative to the including file. The zero value is an empty file.
+type ConfigFile struct {
+	sections []string                     // In order of appearance
+	options  map[string][]string          // Option names of each section, in order
+	values   map[string]map[string]string // Values by section and option
+}
+
+// ReadConfigFile parses the config file at path.
+func ReadConfigFile(path string) (*ConfigFile, error) {
+	c := &ConfigFile{
+		options: make(map[string][]string),
+		values:  make(map[string]map[string]string),
+	}
+	if err := c.read(path, DefaultSection, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// read parses a file into c, starting in section. seen holds the files
+// being read, to detect include cycles.
+func (c *ConfigFile) read(path, section string, seen map[string]bool) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if seen[abs] {
+		return errors.New("include cycle on " + path)
+	}
+	seen[abs] = true
+	defer delete(seen, abs)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	lineno := 0
+	last := "" // Last option set, for continuation lines
+	for sc.Scan() {
+		lineno++
+		raw := sc.Text()
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case raw[0] == ' ' || raw[0] == '\t':
+			if last == "" {
+				return errors.New(fmt.Sprintf("%s:%d: continuation line without option", path, lineno))
+			}
+			c.values[section][last] += "\n" + line
+		case line[0] == '[':
+			if line[len(line)-1] != ']' {
+				return errors.New(fmt.Sprintf("%s:%d: bad section header", path, lineno))
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			c.addSection(section)
+			last = ""
+		case strings.HasPrefix(line, "include ") && !strings.ContainsAny(line, "=:"):
+			inc := strings.TrimSpace(strings.TrimPrefix(line, "include "))
+			if !filepath.IsAbs(inc) {
+				inc = filepath.Join(filepath.Dir(path), inc)
+			}
+			if err := c.read(inc, section, seen); err != nil {
+				return errors.New(fmt.Sprintf("%s:%d: %s", path, lineno, err.Error()))
+			}
+			last = ""
+		default:
+			i := strings.IndexAny(line, "=:")
+			if i <= 0 {
+				return errors.New(fmt.Sprintf("%s:%d: expected name=value", path, lineno))
+			}
+			last = strings.TrimSpace(line[:i])
+			c.set(section, last, strings.TrimSpace(line[i+1:]))
+		}
+	}
+	return sc.Err()
+}
+
+func (c *ConfigFile) addSection(section string) {
+	if _, ok := c.values[section]; !ok {
+		c.sections = append(c.sections, section)
+		c.values[section] = make(map[string]string)
+	}
+}
+
+func (c *ConfigFile) set(section, option, value string) {
+	c.addSection(section)
+	if _, ok := c.values[section][option]; !ok {
+		c.options[section] = append(c.options[section], option)
+	}
+	c.values[section][option] = value
+}
+
+// Sections returns the names of the sections, except the default one.
+func (c *ConfigFile) Sections() []string {
+	list := make([]string, 0, len(c.sections))
+	for _, s := range c.sections {
+		if s != DefaultSection {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// HasSection reports whether the section exists.
+func (c *ConfigFile) HasSection(section string) bool {
+	_, ok := c.values[section]
+	return ok
+}
+
+// Options returns the names of the options of a section, in order.
+func (c *ConfigFile) Options(section string) ([]string, error) {
+	if !c.HasSection(section) {
+		return nil, errors.New("section not found: " + section)
+	}
+	return c.options[section], nil
+}
+
+// HasOption reports whether the option is set in the section or in the
+// default section.
+func (c *ConfigFile) HasOption(section, option string) bool {
+	_, err := c.String(section, option)
+	return err == nil
+}
+
+// String returns the value of an option, looked up in the default section
+// if not in the section.
+func (c *ConfigFile) String(section, option string) (string, error) {
+	if v, ok := c.values[section][option]; ok {
+		return v, nil
+	}
+	if !c.HasSection(section) {
+		return "", errors.New("section not found: " + section)
+	}
+	if v, ok := c.values[DefaultSection][option]; ok {
+		return v, nil
+	}
+	return "", errors.New(fmt.Sprintf("option not found: %s (section %s)", option, section))
+}
+
+// Bool returns the value of a boolean option: 1, t, true, y, yes or on
+// for true; 0, f, false, n, no or off for false.
+func (c *ConfigFile) Bool(section, option string) (bool, error) {
+	v, err := c.String(section, option)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(v) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true, nil
+	case "0", "f", "false", "n", "no", "off":
+		return false, nil
+	}
+	return false, errors.New(fmt.Sprintf("%s: could not parse bool value %q", option, v))
+}
+
+// Int returns the value of an integer option.
+func (c *ConfigFile) Int(section, option string) (int, error) {
+	v, err := c.String(section, option)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(v)
+}
+
+// Float returns the value of a float option.
+func (c *ConfigFile) Float(section, option string) (float64, error) {
+	v, err := c.String(section, option)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(v, 64)
+}
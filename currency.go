@@ -0,0 +1,59 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+)
+
+// Currency is a currency accepted by the platform.
+type Currency struct {
+	Code     string // ISO 4217 numeric code, used as currency_code
+	Alpha    string // ISO 4217 alphabetic code
+	Decimals int    // Number of decimals of the currency
+}
+
+// Currencies accepted by the platform, by currency code (see Annexe B of
+// doc/Dictionnaire_des_donnees.pdf).
+var currencies = map[string]Currency{
+	"978": {"978", "EUR", 2},
+	"840": {"840", "USD", 2},
+	"756": {"756", "CHF", 2},
+	"826": {"826", "GBP", 2},
+	"124": {"124", "CAD", 2},
+	"392": {"392", "JPY", 0},
+	"484": {"484", "MXN", 2},
+	"949": {"949", "TRY", 2},
+	"036": {"036", "AUD", 2},
+	"554": {"554", "NZD", 2},
+	"578": {"578", "NOK", 2},
+	"986": {"986", "BRL", 2},
+	"032": {"032", "ARS", 2},
+	"116": {"116", "KHR", 2},
+	"901": {"901", "TWD", 2},
+	"752": {"752", "SEK", 2},
+	"208": {"208", "DKK", 2},
+	"410": {"410", "KRW", 0},
+	"702": {"702", "SGD", 2},
+	"953": {"953", "XPF", 0},
+	"952": {"952", "XOF", 0},
+}
+
+// LookupCurrency returns the currency matching a currency code.
+func LookupCurrency(code string) (Currency, bool) {
+	c, ok := currencies[code]
+	return c, ok
+}
+
+// SetCurrencyCode sets the currency of the transaction, as an ISO 4217
+// numeric code (i.e. 840 for USD), instead of the merchant currency code
+// of the config. The currency must be enabled on the merchant's contract.
+func (t *Transaction) SetCurrencyCode(code string) error {
+	if _, ok := LookupCurrency(code); !ok {
+		return errors.New("unknown currency code " + code)
+	}
+	t.currencyCode = code
+	return nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"strings"
+)
+
+// dataDirective looks for a KEY=VALUE directive in the ';'-separated DATA
+// field and returns its value.
+func dataDirective(data, key string) (string, bool) {
+	for _, d := range strings.Split(data, ";") {
+		d = strings.TrimSpace(d)
+		if strings.HasPrefix(d, key+"=") {
+			return d[len(key)+1:], true
+		}
+	}
+	return "", false
+}
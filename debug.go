@@ -0,0 +1,27 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"fmt"
+	"io"
+)
+
+// SetDebugWriter sets the writer receiving the debug output of the
+// binaries, when the debug setting is on, instead of the pages of the
+// buyer (i.e. log.Writer()).
+func (s *Sogen) SetDebugWriter(w io.Writer) {
+	s.debugWriter = w
+}
+
+// pageDebug sends the debug output of a binary to the debug writer, if
+// any, and returns what is left to write to the page.
+func (s *Sogen) pageDebug(text string) string {
+	if s.debugWriter == nil || text == "" {
+		return text
+	}
+	fmt.Fprintln(s.debugWriter, text)
+	return ""
+}
@@ -0,0 +1,55 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"context"
+	_ "embed"
+	"net/url"
+)
+
+// demoCertificate is the public certificate of the test merchant.
+//
+//go:embed demo/certif.fr.014213245611111.php
+var demoCertificate []byte
+
+// Paths of the cancel and return URLs of the demo config, relative to the
+// server (see ResolveURLs()).
+const (
+	DemoCancelPath = "/sogen/cancel"
+	DemoReturnPath = "/sogen/return"
+)
+
+type demoCertificateSource struct{}
+
+func (demoCertificateSource) Certificate(ctx context.Context) ([]byte, error) {
+	return demoCertificate, nil
+}
+
+// NewDemoConfig returns the config of the test merchant TestMerchantId of
+// the test environment, with its certificate, to try a full payment round
+// trip without a contract. The merchant files are written to rootDir. The
+// test cards of the payment server are accepted, no money is charged.
+func NewDemoConfig(libraryPath, rootDir string) *Config {
+	c := &Config{
+		Environment:          EnvTest,
+		LogoPath:             DefaultLogoPath,
+		LibraryPath:          libraryPath,
+		MerchantsRootDir:     rootDir,
+		MerchantId:           TestMerchantId,
+		MerchantCountry:      "fr",
+		MerchantCurrencyCode: "978",
+		CancelUrl:            &url.URL{Path: DemoCancelPath},
+		ReturnUrl:            &url.URL{Path: DemoReturnPath},
+		CertificateSource:    demoCertificateSource{},
+	}
+	setDefaults(c)
+	return c
+}
+
+// NewDemoSogen sets up the test merchant (see NewDemoConfig()).
+func NewDemoSogen(libraryPath, rootDir string) (*Sogen, error) {
+	return NewSogen(NewDemoConfig(libraryPath, rootDir))
+}
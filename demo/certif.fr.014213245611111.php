@@ -0,0 +1,54 @@
+<?php
+/*__DEBUT__
+merchant_id!014213245611111!
+merchant_country!fr!
+certificate_expired!20130607!
+certificate_version!1.0!
+certificate_type!php!
+certificate_data!
+RMqyhPQKk4a97wq48nQ3wgRbfmnlm2xRIcSXSklqu9tF2AphmXXLgwxDj1ZKNvAbyH
+rzNnmlYl2AJ02xQQxE2ylEHiUKw6HPxXm4K5tPLmfcTUIV5wTza2lPYRospVeLTbt8
+PJJr5pVE5iVOvk2R88M0Tkt6YmfBq6ZpNBzeJDQODpEotiMyTDRjg4PNU7B76br1MU
+6Ofl1koxTS5aBrnLGO0OaPQ3bUFhihfYlYrMcKSZi5IpOtSXwv9irD72MO40fnoVP9
+erc91dNegkTnXRto8nb2dMNPkH7W2nZRHzQPPBu4tXUR65yBPgvVnupXj7Xx9E63K4
+UTAi9CFfM3mljqbKzr6YsVDw4gkAqTNYTjvQm90pTzKWmi4nLuNaeDpijC9C7LTphp
+DN8VSAhg4eCu7CkfZnd1NTamKQyYuNhw6Yu08XqRVvGjLCaM5adsdddTd4Tq2QOvPy
+iDMtGriewzJJOvYiBNCnn3CpDDdaFtKxqgwLRRMpaFgtyO6HhtLUGZIWMnaJfXadjA
+CK6fD6pD1JlRq5nx7Hd0IuAetuZ2zrPOdLLMM9IqrWtwdI0RbKca5JBnMcvDpT9bKc
+bILkjfjvAdjSwnmTlw7OOP41dhGWH52gIJcnaMakXvk65cBVX0PejFVqYm4CBUHrfi
+kIf1HCgq6rPZeeWEBo4P8gfXi8z8EDO19r2eLH7kWvIMeqJvRe2oca0j7I6LW9fE87
+Yiiy6kRmVc3iKXtLKBaozUekQBwxym2EPrpKty1MpMeeIcWt4zC499jC9OIDGJZMPr
+IEIxTyciNUimLIbmoCdR0VyVoZ9KOOubtZRVPCeON1GiSgM2iDjGwv3V6mdAZlGUpF
+sRhPnsES5TqiqSPetMcgrih6BwncLvUJjRj4vVdOhrYuLCRkziOMKNlKjxIGCDjUyw
+GlUQEUYGc2AGWRvW0lrqWVbL9eW2c5cQB8jqPFL9Z5Bo0hR9YlKEspbLz1wkizYcNt
+xjFsHf0NGprdtqQwmconqIeawZElKYbpfctjxmMAigYdlSE5KGlI64QfnyCTRqh4hx
+QAfY6ua9zLG4jsrlDZOwQDs5TsD23du{NBsL0jxRhZ73IP8yj4X82TTsK5KZP4Bwh2
+VS1eeZpTEMUKO9JkG51yjTVHdsV5GyOUVhvKpZwiM9Zwdhl1hLKnnLGOFTGiCzx4Cu
+htM4Nf5fcMFlYQqOeITpss5Cej0HfHm7Bt2bWUEmVIBEEMDFSy8bnKP8aWdhSe7gXj
+GF0tJI9WxTQhCZRwGGZ5oZFkOWkEdBHq1dCApUqDlPubZpJrxLCQ4HUhXJ4doXwR8P
+aI5QWao94NJ1UdTykrxBWS9J01mzXDzb3Bv6n23YS877UmHx2ywOeIAnjWyLFEUb5j
+NUmU8m21S9VcRZGIKw34urskLcUc6t8WrBiupsXamZ60VllaWK6c6ERNgaLpugWhlm
+xxdQv8g5Vqo0l2B0Mjfy6kRLf7Ndj4uWjyjiymqAKitSkjbBCWuPUZN5R1IVQHR9aI
+PrLXzlGrSPrutj1UTsntL6dBfP5Y9vwmxHqoPVAoruMMcHBZ1dov00c5k0A5xPcPA8
+01ZO44ewVreL5k2rAd9a0AQTghQOFKulwX1GlyyUFvDEJliefARTFKfWAVTZ3nxkdp
+144A9B7F681E6821832306540AD453F4BE29FB4FC1B24794937C99E1304E42B1BE
+94BE762F2BB9C89ECFB7A2FE30B2355CEF90CE647625040E1C0F56DFD0818A2A32
+5F88AFC0EC395E8qQr1zR6dLlJOiMDbG6OGmKSYrbRLjNxgYF6O0LOphKqjcTtEjC4
+qRYApJkYmWXLLANZn46w0I65L63PlBVrpYPSvFAu25aUMaSwcELNUKcpgFq5tsI1wG
+112DCF632F3D33545CB2D61FB37B5868A35A516C2B266B98F0D84D9BEC0149C03E
+B5895B5B332A1342349B86C7571609D50167FF03C266E167F1eFcB1ZxNnOK4rRiC
+YhZYW3QiL6OW9eXqQr1zR6dLlJOiMDbG6OGmKSYrbRLjNxgYF6O0LOphKqjcTtEjC4
+qRYApJkYmWXLLANZn46w0I65L63PlBVrpYPSvFAu25aUMaSwcELNUKcpgFq5tsI1wG
+144A9B7F681E6821832306540AD453F4BE29FB4FC1B24794937C99E1304E42B1BE
+94BE762F2BB9C89ECFB7A2FE30B2355CEF90CE647625040E1C0F56DFD0818A2A32
+5F88AFC0EC395E8qQr1zR6dLlJOiMDbG6OGmKSYrbRLjNxgYF6O0LOphKqjcTtEjC4
+qRYApJkYmWXLLANZn46w0I65L63PlBVrpYPSvFAu25aUMaSwcELNUKcpgFq5tsI1wG
+1129F7478E8F36AE4162AEF95042BC2EFEB47103437F135623017A0224E637F9AA
+5F0B5F98E02BB2456984EA6020DDDA45FC58FCB16A36A55CF1eFcB1ZxNnOK4rRiC
+YhZYW3QiL6OW9eXqQr1zR6dLlJOiMDbG6OGmKSYrbRLjNxgYF6O0LOphKqjcTtEjC4
+qRYApJkYmWXLLANZn46w0I65L63PlBVrpYPSvFAu25aUMaSwcELNUKcpgFq5tsI1wG
+++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+sogenactifdemo,11/08/1999,V4,SOGENACTIF,DEMO++++++++++++++++++++++
++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++END
+__FIN__*/
+php>
@@ -0,0 +1,126 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MerchantInfo describes a merchant directory found by DiscoverMerchants().
+type MerchantInfo struct {
+	Id          string
+	Country     string    // Country code of the certificate
+	Certificate string    // Path of the certificate file, if any
+	Expires     time.Time // Expiration date of the certificate, if known
+	Ready       bool      // Whether the merchant can be set up with NewSogen()
+	Problem     string    // Why the merchant is not ready
+}
+
+// DiscoverMerchants scans the merchants root directory of the config for
+// merchant directories, i.e. <rootdir>/<merchant_id>/, and reports the
+// certificate of each merchant. A merchant is ready if its directory holds
+// a single certif.<country>.<merchant_id>.php file, issued for that
+// merchant and not expired by the clock of the config. Merchants are sorted
+// by ID.
+func DiscoverMerchants(c *Config) ([]MerchantInfo, error) {
+	if c == nil {
+		return nil, errors.New("nil config")
+	}
+	rootdir := c.MerchantsRootDir
+	if strings.TrimSpace(rootdir) == "" {
+		return nil, ErrMissingMerchantsRootDir
+	}
+	dirs, err := ioutil.ReadDir(rootdir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]MerchantInfo, 0)
+	for _, d := range dirs {
+		if !d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		infos = append(infos, discoverMerchant(filepath.Join(rootdir, d.Name()), d.Name(), c.now()))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Id < infos[j].Id })
+	return infos, nil
+}
+
+// discoverMerchant inspects the directory of a merchant at now.
+func discoverMerchant(dir, id string, now time.Time) MerchantInfo {
+	info := MerchantInfo{Id: id}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		info.Problem = err.Error()
+		return info
+	}
+	certs := make([]string, 0)
+	for _, f := range files {
+		// Links are made by NewSogen() to certificates of another name
+		if country, ok := certificateCountry(f.Name(), id); ok && f.Mode().IsRegular() {
+			certs = append(certs, f.Name())
+			info.Country = country
+		}
+	}
+	switch len(certs) {
+	case 0:
+		info.Problem = "no certificate"
+		return info
+	case 1:
+	default:
+		info.Country = ""
+		info.Problem = "several certificates: " + strings.Join(certs, ", ")
+		return info
+	}
+	info.Certificate = filepath.Join(dir, certs[0])
+	data, err := ioutil.ReadFile(info.Certificate)
+	if err != nil {
+		info.Problem = err.Error()
+		return info
+	}
+	if err := checkDiscovered(&info, certificateFields(data), now); err != nil {
+		info.Problem = err.Error()
+		return info
+	}
+	info.Ready = true
+	return info
+}
+
+// checkDiscovered checks the header fields of the certificate of a
+// discovered merchant at now.
+func checkDiscovered(info *MerchantInfo, fields map[string]string, now time.Time) error {
+	if id, ok := fields["merchant_id"]; ok && id != info.Id {
+		return errors.New(fmt.Sprintf("certificate of merchant %s", id))
+	}
+	if country, ok := fields["merchant_country"]; ok && country != info.Country {
+		return errors.New(fmt.Sprintf("certificate for country %s, not %s", country, info.Country))
+	}
+	if exp, err := time.Parse("20060102", fields["certificate_expired"]); err == nil {
+		info.Expires = exp
+		if exp.Before(now) {
+			return errors.New("certificate expired on " + exp.Format("2006-01-02"))
+		}
+	}
+	return nil
+}
+
+// certificateCountry returns the country code of a certificate file name
+// of a merchant, i.e. fr for certif.fr.<merchant_id>.php.
+func certificateCountry(name, id string) (string, bool) {
+	suffix := "." + id + ".php"
+	if !strings.HasPrefix(name, "certif.") || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	country := strings.TrimSuffix(strings.TrimPrefix(name, "certif."), suffix)
+	if country == "" || strings.Contains(country, ".") {
+		return "", false
+	}
+	return country, true
+}
@@ -0,0 +1,166 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Dispute is a chargeback of a payment, i.e. imported from the reports of
+// the bank (see the reports package). A payment is identified by its
+// transaction ID and payment date.
+type Dispute struct {
+	TransactionId string
+	PaymentDate   time.Time // Date of the disputed payment
+	Amount        int64     // Disputed amount, in cents
+	CurrencyCode  string
+	Reason        string // Reason code of the chargeback, if any
+	Reference     string // Reference of the chargeback at the bank, if any
+	Date          time.Time
+}
+
+// DisputeStore persists disputes.
+type DisputeStore interface {
+	// SaveDispute records a dispute. A dispute with the same reference
+	// and transaction ID is updated.
+	SaveDispute(d *Dispute) error
+	// PaymentDisputes returns all disputes of a transaction.
+	PaymentDisputes(transactionId string) ([]*Dispute, error)
+	// Disputes returns all disputes recorded in [from, to), sorted by
+	// date.
+	Disputes(from, to time.Time) ([]*Dispute, error)
+}
+
+// MemoryDisputeStore is an in-memory DisputeStore.
+type MemoryDisputeStore struct {
+	mu       sync.Mutex
+	disputes []*Dispute
+}
+
+// NewMemoryDisputeStore returns an empty in-memory dispute store.
+func NewMemoryDisputeStore() *MemoryDisputeStore {
+	return &MemoryDisputeStore{disputes: make([]*Dispute, 0)}
+}
+
+func (m *MemoryDisputeStore) SaveDispute(d *Dispute) error {
+	if d == nil {
+		return errors.New("nil dispute")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, cur := range m.disputes {
+		if d.Reference != "" && cur.Reference == d.Reference && cur.TransactionId == d.TransactionId {
+			m.disputes[k] = d
+			return nil
+		}
+	}
+	m.disputes = append(m.disputes, d)
+	return nil
+}
+
+func (m *MemoryDisputeStore) PaymentDisputes(transactionId string) ([]*Dispute, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*Dispute, 0)
+	for _, d := range m.disputes {
+		if d.TransactionId == transactionId {
+			list = append(list, d)
+		}
+	}
+	return list, nil
+}
+
+func (m *MemoryDisputeStore) Disputes(from, to time.Time) ([]*Dispute, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*Dispute, 0)
+	for _, d := range m.disputes {
+		if !d.Date.Before(from) && d.Date.Before(to) {
+			list = append(list, d)
+		}
+	}
+	sort.Sort(byDisputeDate(list))
+	return list, nil
+}
+
+// byDisputeDate sorts disputes by date.
+type byDisputeDate []*Dispute
+
+func (b byDisputeDate) Len() int           { return len(b) }
+func (b byDisputeDate) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byDisputeDate) Less(i, j int) bool { return b[i].Date.Before(b[j].Date) }
+
+// SetDisputeStore sets the store used to persist disputes.
+func (s *Sogen) SetDisputeStore(st DisputeStore) {
+	s.disputes = st
+}
+
+type disputeHook func(p *Payment, d *Dispute)
+
+// OnPaymentDisputed registers a function called when an accepted payment
+// is disputed (see RecordDispute()).
+func (s *Sogen) OnPaymentDisputed(fn func(p *Payment, d *Dispute)) {
+	s.onDisputed = append(s.onDisputed, fn)
+}
+
+// RecordDispute records a chargeback in the dispute store. If the
+// disputed payment is found in the payment store, if any, and was
+// accepted, the OnPaymentDisputed hooks are called.
+func (s *Sogen) RecordDispute(d *Dispute) error {
+	if s.disputes == nil {
+		return errors.New("record dispute: no dispute store")
+	}
+	if d == nil {
+		return errors.New("record dispute: nil dispute")
+	}
+	if d.TransactionId == "" {
+		return errors.New("record dispute: missing transaction ID")
+	}
+	if d.Date.IsZero() {
+		d.Date = s.now()
+	}
+	if err := s.disputes.SaveDispute(d); err != nil {
+		return errors.New("record dispute: " + err.Error())
+	}
+	p := s.disputedPayment(d)
+	if p == nil || p.ResponseCode != "00" {
+		return nil
+	}
+	for _, fn := range s.onDisputed {
+		fn(p, d)
+	}
+	return nil
+}
+
+// disputedPayment returns the stored payment of a dispute, if any. A
+// transaction ID being unique over a day only, the payment date of the
+// dispute is used when set.
+func (s *Sogen) disputedPayment(d *Dispute) *Payment {
+	if s.payments == nil {
+		return nil
+	}
+	if d.PaymentDate.IsZero() {
+		p, err := s.payments.Payment(d.TransactionId)
+		if err != nil {
+			return nil
+		}
+		return p
+	}
+	y, m, day := d.PaymentDate.Date()
+	from := time.Date(y, m, day, 0, 0, 0, 0, d.PaymentDate.Location())
+	list, err := s.payments.Payments(from, from.AddDate(0, 0, 1))
+	if err != nil {
+		return nil
+	}
+	for _, p := range list {
+		if p.TransactionId == d.TransactionId {
+			return p
+		}
+	}
+	return nil
+}
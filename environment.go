@@ -0,0 +1,89 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// Environments of the payment server. The options of the [test] and
+// [production] sections of a config file override the ones of the
+// [sogenactif] section in their environment.
+const (
+	EnvTest       = "test"
+	EnvProduction = "production"
+)
+
+// TestMerchantId is the merchant ID of the test environment, used if the
+// config has none.
+const TestMerchantId = "014213245611111"
+
+// EnvironmentVar overrides the environment of the config files.
+const EnvironmentVar = "SOGEN_ENVIRONMENT"
+
+// envConfig reads the options of the [sogenactif] section of a config
+// file, looking them up in the section of the environment first.
+type envConfig struct {
+	*ConfigFile
+	env string
+}
+
+// newEnvConfig returns the config of the environment set in the config
+// file or with the SOGEN_ENVIRONMENT env var.
+func newEnvConfig(c *ConfigFile) (*envConfig, error) {
+	env, err := c.String("sogenactif", "environment")
+	if err != nil {
+		env = ""
+	}
+	if v := os.Getenv(EnvironmentVar); v != "" {
+		env = v
+	}
+	env = strings.TrimSpace(env)
+	switch env {
+	case "", EnvTest, EnvProduction:
+	default:
+		return nil, errors.New("environment: expected " + EnvTest + " or " + EnvProduction + ", got " + env)
+	}
+	return &envConfig{c, env}, nil
+}
+
+func (c *envConfig) section(section, option string) string {
+	if _, ok := c.values[c.env][option]; section == "sogenactif" && c.env != "" && ok {
+		return c.env
+	}
+	return section
+}
+
+// String returns the value of an option, decrypted if it is encrypted
+// (see EncryptValue()).
+func (c *envConfig) String(section, option string) (string, error) {
+	v, err := c.ConfigFile.String(c.section(section, option), option)
+	if err != nil {
+		return v, err
+	}
+	v, err = decryptValue(v)
+	if err != nil {
+		return "", errors.New(option + ": " + err.Error())
+	}
+	return v, nil
+}
+
+func (c *envConfig) Bool(section, option string) (bool, error) {
+	return c.ConfigFile.Bool(c.section(section, option), option)
+}
+
+func (c *envConfig) Int(section, option string) (int, error) {
+	return c.ConfigFile.Int(c.section(section, option), option)
+}
+
+func (c *envConfig) Float(section, option string) (float64, error) {
+	return c.ConfigFile.Float(c.section(section, option), option)
+}
+
+func (c *envConfig) HasOption(section, option string) bool {
+	return c.ConfigFile.HasOption(c.section(section, option), option)
+}
@@ -0,0 +1,127 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventType is the type of a payment event.
+type EventType string
+
+const (
+	EventAccepted  EventType = "payment.accepted"
+	EventDeclined  EventType = "payment.declined"
+	EventCancelled EventType = "payment.cancelled"
+	EventRefunded  EventType = "payment.refunded"
+	EventDisputed  EventType = "payment.disputed"
+)
+
+// PaymentEvent is published to a PaymentPublisher on payment outcomes.
+type PaymentEvent struct {
+	Type    EventType     `json:"type"`
+	Time    time.Time     `json:"time"`
+	Payment *Payment      `json:"payment"`
+	Refund  *RefundResult `json:"refund,omitempty"`
+	Dispute *Dispute      `json:"dispute,omitempty"`
+}
+
+// Marshal serializes the event as JSON.
+func (e *PaymentEvent) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// PaymentPublisher publishes payment events, i.e. to a message bus.
+type PaymentPublisher interface {
+	Publish(e *PaymentEvent) error
+}
+
+// PublishEvents registers hooks publishing all payment events to p.
+// Publishing errors are logged.
+func (s *Sogen) PublishEvents(p PaymentPublisher) {
+	publish := func(e *PaymentEvent) {
+		if err := p.Publish(e); err != nil {
+			s.config.logf(LogError, "publish %s event: %s", e.Type, err.Error())
+		}
+	}
+	s.OnPaymentAccepted(func(pay *Payment) {
+		publish(&PaymentEvent{Type: EventAccepted, Time: s.now(), Payment: pay})
+	})
+	s.OnPaymentDeclined(func(pay *Payment) {
+		publish(&PaymentEvent{Type: EventDeclined, Time: s.now(), Payment: pay})
+	})
+	s.OnPaymentCancelled(func(pay *Payment) {
+		publish(&PaymentEvent{Type: EventCancelled, Time: s.now(), Payment: pay})
+	})
+	s.OnPaymentRefunded(func(pay *Payment, r *RefundResult) {
+		publish(&PaymentEvent{Type: EventRefunded, Time: s.now(), Payment: pay, Refund: r})
+	})
+	s.OnPaymentDisputed(func(pay *Payment, d *Dispute) {
+		publish(&PaymentEvent{Type: EventDisputed, Time: s.now(), Payment: pay, Dispute: d})
+	})
+}
+
+// OnPaymentAccepted registers a function called with every accepted
+// payment (response code 00), unless it was rejected or denied (see
+// OnPaymentRejected()). It is called once per payment even if both the
+// return and auto responses are handled.
+func (s *Sogen) OnPaymentAccepted(fn func(p *Payment)) {
+	s.onAccepted = append(s.onAccepted, fn)
+}
+
+// OnPaymentDeclined registers a function called with every payment which
+// was not accepted, except cancellations by the customer. It is called
+// once per payment even if both the return and auto responses are handled.
+func (s *Sogen) OnPaymentDeclined(fn func(p *Payment)) {
+	s.onDeclined = append(s.onDeclined, fn)
+}
+
+// OnPaymentCancelled registers a function called with every payment
+// cancelled by the customer (response code 17). It is called once per
+// payment.
+func (s *Sogen) OnPaymentCancelled(fn func(p *Payment)) {
+	s.onCancelled = append(s.onCancelled, fn)
+}
+
+type refundHook func(p *Payment, r *RefundResult)
+
+// OnPaymentRefunded registers a function called after every successful
+// refund (see Refund()).
+func (s *Sogen) OnPaymentRefunded(fn func(p *Payment, r *RefundResult)) {
+	s.onRefunded = append(s.onRefunded, fn)
+}
+
+// paymentEvents fires the OnPaymentAccepted, OnPaymentRejected,
+// OnPaymentDeclined (and OnSoftDecline) or OnPaymentCancelled hooks.
+func (s *Sogen) paymentEvents(p *Payment) error {
+	var hooks []func(*Payment)
+	switch {
+	case p.ResponseCode == "00" && (p.Rejection != nil || p.denied()):
+		hooks = s.onRejected
+	case p.ResponseCode == "00":
+		hooks = s.onAccepted
+	case p.ResponseCode == "17":
+		hooks = s.onCancelled
+	default:
+		hooks = s.onDeclined
+	}
+	soft := len(s.onSoftDecline) > 0 && p.Rejection == nil && p.SoftDeclined()
+	if len(hooks) == 0 && !soft {
+		return nil
+	}
+	if err := s.checkReplay(replayEvent, p); err == ErrReplayedNotification {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, fn := range hooks {
+		fn(p)
+	}
+	if soft {
+		s.softDeclineEvents(p)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Executor runs a binary of the SDK, given the path of the file and its
+// arguments, writing its output to stdout. Implementations other than
+// LocalExecutor can stub the output in tests or run the binaries
+// elsewhere (remote agent, container etc.).
+type Executor interface {
+	Exec(ctx context.Context, file string, args []string, stdout io.Writer) error
+}
+
+// LocalExecutor runs the binaries as local processes.
+type LocalExecutor struct{}
+
+func (LocalExecutor) Exec(ctx context.Context, file string, args []string, stdout io.Writer) error {
+	cmd := exec.CommandContext(ctx, file, args...)
+	cmd.Stdout = stdout
+	return cmd.Run()
+}
+
+// executor returns the executor of the config, if any.
+func (c *Config) executor() Executor {
+	if c.Executor != nil {
+		return c.Executor
+	}
+	return LocalExecutor{}
+}
+
+// localExec reports whether the binaries run as local processes, and
+// must be found in LibraryPath.
+func (c *Config) localExec() bool {
+	_, ok := c.executor().(LocalExecutor)
+	return ok
+}
+
+// outputPool recycles the buffers holding the output of the binaries.
+var outputPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// runBinary runs a binary of the SDK with e and parses its output (see
+// parseExecOutput()).
+func runBinary(e Executor, binary, file string, maxFields int, args ...string) (*execResult, error) {
+	out := outputPool.Get().(*bytes.Buffer)
+	out.Reset()
+	defer outputPool.Put(out)
+	if err := e.Exec(context.Background(), file, args, out); err != nil {
+		return nil, err
+	}
+	// The fields must not refer to the recycled buffer
+	return parseExecOutput(binary, out.String(), maxFields)
+}
+
+// execResult is the output of a binary: !code!error!field!field!...
+type execResult struct {
+	Code   string
+	Error  string   // Error message, or debug info if DEBUG is set to YES
+	Fields []string // Body of the request binary, payment of the response one
+}
+
+// fieldScanner reads the !-separated fields of the output of a binary one
+// at a time. The binaries do not escape separators, which fields can't
+// hold (see ValidateCaddie()): fields are substrings of the output.
+type fieldScanner struct {
+	s   string
+	pos int // Start of the next field, -1 at the end
+}
+
+func newFieldScanner(s string) *fieldScanner {
+	return &fieldScanner{s: s}
+}
+
+// next returns the next field. ok is false past the last one.
+func (f *fieldScanner) next() (field string, ok bool) {
+	if f.pos < 0 {
+		return "", false
+	}
+	if i := strings.IndexByte(f.s[f.pos:], '!'); i >= 0 {
+		field = f.s[f.pos : f.pos+i]
+		f.pos += i + 1
+		return field, true
+	}
+	field = f.s[f.pos:]
+	f.pos = -1
+	return field, true
+}
+
+// rest returns the remaining output, as is.
+func (f *fieldScanner) rest() string {
+	if f.pos < 0 {
+		return ""
+	}
+	s := f.s[f.pos:]
+	f.pos = -1
+	return s
+}
+
+// parseExecOutput parses the output of a binary. If maxFields > 0, at most
+// maxFields fields follow the error, the last one holding the rest of the
+// output as is (i.e. an HTML form which may contain separators).
+func parseExecOutput(binary, out string, maxFields int) (*execResult, error) {
+	sc := newFieldScanner(out)
+	head := make([]string, 0, 3)
+	for len(head) < 3 {
+		field, ok := sc.next()
+		if !ok {
+			break
+		}
+		head = append(head, field)
+	}
+	unexpected := errors.New(fmt.Sprintf("error: unexpected output of the %s executable", binary))
+	if len(head) < 3 || (head[1] == "" && head[2] == "") {
+		return nil, unexpected
+	}
+	r := &execResult{Code: head[1], Error: head[2]}
+	if r.Code != "0" {
+		return nil, &APIError{Binary: binary, Code: r.Code, Message: strings.TrimSpace(r.Error)}
+	}
+	if sc.pos < 0 {
+		return nil, unexpected
+	}
+	n := strings.Count(out[sc.pos:], "!") + 1
+	if maxFields > 0 && n > maxFields {
+		n = maxFields
+	}
+	r.Fields = make([]string, 0, n)
+	for len(r.Fields) < n-1 || maxFields <= 0 {
+		field, ok := sc.next()
+		if !ok {
+			break
+		}
+		r.Fields = append(r.Fields, field)
+	}
+	if maxFields > 0 {
+		// The output ends with a separator
+		r.Fields = append(r.Fields, strings.TrimSuffix(sc.rest(), "!"))
+	}
+	return r, nil
+}
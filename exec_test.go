@@ -0,0 +1,123 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// outputExecutor is an Executor writing a canned output.
+type outputExecutor string
+
+func (e outputExecutor) Exec(ctx context.Context, file string, args []string, stdout io.Writer) error {
+	_, err := io.WriteString(stdout, string(e))
+	return err
+}
+
+// Output of the request binary: the HTML form, which contains separators.
+var requestOutput = "!0!!" + `<form method="post" action="https://payment-webinit.sogenactif.com/cgis-payment/prod/callpayment">` +
+	`<input type="hidden" name="DATA" value="` + strings.Repeat("2020333732603028502c2360532d5c2360", 40) + `">` +
+	`<input type="image" name="CB" src="/logo/CB.gif"><!-- CB! VISA! MASTERCARD -->` +
+	`<input type="image" name="VISA" src="/logo/VISA.gif"></form>!`
+
+// Output of the response binary: the payment fields.
+var responseOutput = "!0!!" + strings.Join([]string{
+	"014213245611111", "fr", "2500", "123456", "CB", "20130331143000", "143105", "20130331",
+	"c5d2b3f8a4e1", "00", "123456", "978", "4974##01", "1", "4D", "00", "", "",
+	"return context", "eyJvcmRlciI6IjQyIn0", "", "fr", "fr", "johndoe", "john@example.com",
+	"192.0.2.1", "", "AUTHOR_CAPTURE", "", "", "", "", "", "", "",
+}, "!") + "!"
+
+func BenchmarkParseRequestOutput(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseExecOutput("request", requestOutput, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseResponseOutput(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		res, err := parseExecOutput("response", responseOutput, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(res.Fields) < paymentFields {
+			b.Fatalf("%d fields, expected %d", len(res.Fields), paymentFields)
+		}
+	}
+}
+
+func BenchmarkFieldScanner(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sc := newFieldScanner(responseOutput)
+		for {
+			if _, ok := sc.next(); !ok {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkRunBinary(b *testing.B) {
+	e := outputExecutor(responseOutput)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := runBinary(e, "response", "response", 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func FuzzFieldScanner(f *testing.F) {
+	f.Add("", "", "")
+	f.Add("014213245611111", "eyJvcmRlciI6IjQyIn0", "back\\slash")
+	f.Add("!", "\\", "\\!")
+	f.Fuzz(func(t *testing.T, a, b, c string) {
+		// The binaries do not escape separators
+		out := strings.Join([]string{a, b, c}, "!")
+		sc := newFieldScanner(out)
+		for i, want := range strings.Split(out, "!") {
+			got, ok := sc.next()
+			if !ok {
+				t.Fatalf("field %d: missing", i)
+			}
+			if got != want {
+				t.Fatalf("field %d: got %q, want %q", i, got, want)
+			}
+		}
+		if got, ok := sc.next(); ok {
+			t.Fatalf("unexpected field %q", got)
+		}
+	})
+}
+
+func FuzzParseExecOutput(f *testing.F) {
+	f.Add(requestOutput, 1)
+	f.Add(responseOutput, 0)
+	f.Fuzz(func(t *testing.T, out string, maxFields int) {
+		if maxFields < 0 || maxFields > paymentFields {
+			return
+		}
+		res, err := parseExecOutput("response", out, maxFields)
+		if err != nil {
+			return
+		}
+		if res.Code != "0" {
+			t.Fatalf("code %q accepted", res.Code)
+		}
+		if maxFields > 0 && len(res.Fields) > maxFields {
+			t.Fatalf("%d fields, expected at most %d", len(res.Fields), maxFields)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package export renders processed payments for bookkeeping: a full CSV
+// export and a simple accounting format.
+package export
+
+import (
+	"encoding/csv"
+	"github.com/gotsunami/sogenactif"
+	"io"
+	"math"
+	"strconv"
+)
+
+// CSV writes all payments as CSV, one line per payment, with a header line.
+func CSV(w io.Writer, payments []*sogenactif.Payment) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"payment_date", "transaction_id", "merchant_id", "customer_id", "amount",
+		"currency_code", "response_code", "bank_response_code", "payment_means", "card_number",
+		"authorisation_id", "payment_certificate", "caddie"})
+	for _, p := range payments {
+		cw.Write([]string{
+			p.PaymentDate.Format("2006-01-02 15:04:05"),
+			p.TransactionId,
+			p.MerchantId,
+			p.CustomerId,
+			amount(p),
+			p.CurrencyCode,
+			p.ResponseCode,
+			p.BankResponseCode,
+			p.PaymentMeans,
+			p.CardNumber,
+			p.AuthorizationId,
+			p.PaymentCertificate,
+			p.Caddie,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Accounting writes accepted payments in a simple accounting format:
+// semicolon-separated date, reference, amount, fees and currency. The fees
+// column is left empty, to be filled from the bank statements.
+func Accounting(w io.Writer, payments []*sogenactif.Payment) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	cw.Write([]string{"date", "reference", "amount", "fees", "currency"})
+	for _, p := range payments {
+		if p.ResponseCode != "00" {
+			continue
+		}
+		currency := p.CurrencyCode
+		if c, ok := sogenactif.LookupCurrency(p.CurrencyCode); ok {
+			currency = c.Alpha
+		}
+		cw.Write([]string{
+			p.PaymentDate.Format("2006-01-02"),
+			Reference(p),
+			amount(p),
+			"",
+			currency,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// amount formats the amount of a payment with the decimals of its
+// currency, i.e. 1500 for JPY.
+func amount(p *sogenactif.Payment) string {
+	// Smallest unit of the currency, as sent by the payment server
+	units := int64(math.Floor(p.Amount*100 + 0.5))
+	decimals := 2
+	if c, ok := sogenactif.LookupCurrency(p.CurrencyCode); ok {
+		decimals = c.Decimals
+	}
+	return strconv.FormatFloat(float64(units)/math.Pow10(decimals), 'f', decimals, 64)
+}
+
+// Reference returns a unique reference for a payment, made of its payment
+// date and transaction ID.
+func Reference(p *sogenactif.Payment) string {
+	return p.PaymentDate.Format("20060102") + "-" + p.TransactionId
+}
@@ -0,0 +1,172 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// merchantFile is a file written by NewSogen() in the merchant directory.
+type merchantFile struct {
+	path string
+	data []byte
+}
+
+// merchantFiles returns the pathfile and parcom files of the config.
+func (s *Sogen) merchantFiles() []*merchantFile {
+	c := s.config
+	debug := "NO"
+	if c.Debug {
+		debug = "YES"
+	}
+	pathfile := fmt.Sprintf(`DEBUG!%s!
+D_LOGO!%s!
+F_CERTIFICATE!%s!
+F_CTYPE!php!
+F_PARAM!%s!
+F_DEFAULT!%s!
+`, debug, c.LogoPath, s.certificatePrefix, s.parametersPrefix, s.parametersSogenActif)
+
+	parmcom := fmt.Sprintf(`LOGO!/bf/chrome/common/logo.png!
+CANCEL_URL!%s!
+RETURN_URL!%s!
+`, c.CancelUrl, c.ReturnUrl)
+	// auto_response_url config parameter is optional
+	if c.AutoResponseUrl != nil {
+		parmcom += fmt.Sprintf("AUTO_RESPONSE_URL!%s!\n", c.AutoResponseUrl)
+	}
+
+	mpars := map[string]string{
+		"ADVERT":            c.Advert,
+		"BGCOLOR":           c.BgColor,
+		"BLOCK_ALIGN":       c.BlockAlign,
+		"BLOCK_ORDER":       c.BlockOrder,
+		"CONDITION":         c.Condition,
+		"CURRENCY":          strconv.FormatInt(int64(c.Currency), 10),
+		"LOGO2":             c.Logo2,
+		"PAYMENT_MEANS":     c.PaymentMeans,
+		"TARGET":            c.Target,
+		"TEXTCOLOR":         c.TextColor,
+		"LANGUAGE":          c.MerchantCountry,
+		"MERCHANT_COUNTRY":  c.MerchantCountry,
+		"MERCHANT_LANGUAGE": c.MerchantCountry,
+	}
+	if c.HeaderFlag {
+		mpars["HEADER_FLAG"] = "yes"
+	} else {
+		mpars["HEADER_FLAG"] = "no"
+	}
+	keys := make([]string, 0, len(mpars))
+	for k := range mpars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sogenactif bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&sogenactif, "%s!%s!\n", k, mpars[k])
+	}
+
+	return []*merchantFile{
+		{s.pathFile, []byte(pathfile)},
+		{fmt.Sprintf("%s.%s", s.parametersPrefix, c.MerchantId), []byte(parmcom)},
+		{s.parametersSogenActif, sogenactif.Bytes()},
+	}
+}
+
+// GeneratedFiles returns the content of the pathfile and parcom files in
+// effect, keyed by path, as written by NewSogen() (or as it would write
+// them in dry run mode), i.e. to display them for debugging.
+func (s *Sogen) GeneratedFiles() map[string][]byte {
+	files := make(map[string][]byte)
+	for _, f := range s.merchantFiles() {
+		files[f.path] = f.data
+	}
+	return files
+}
+
+// Diff is a difference between a merchant file and the content NewSogen()
+// would write.
+type Diff struct {
+	File     string
+	Param    string // Parameter (i.e. CANCEL_URL), empty if the file is missing
+	Expected string // Expected value, empty for an unexpected parameter
+	Actual   string // Actual value, empty for a missing parameter
+}
+
+func (d *Diff) String() string {
+	switch {
+	case d.Param == "":
+		return d.File + ": missing file"
+	case d.Actual == "" && d.Expected != "":
+		return fmt.Sprintf("%s: missing %s (expected %q)", d.File, d.Param, d.Expected)
+	case d.Expected == "" && d.Actual != "":
+		return fmt.Sprintf("%s: unexpected %s (%q)", d.File, d.Param, d.Actual)
+	}
+	return fmt.Sprintf("%s: %s is %q, expected %q", d.File, d.Param, d.Actual, d.Expected)
+}
+
+// parseParams parses the KEY!VALUE! lines of a merchant file.
+func parseParams(data []byte) map[string]string {
+	params := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, "!", 3)
+		if len(fields) < 2 {
+			params[line] = ""
+			continue
+		}
+		params[fields[0]] = fields[1]
+	}
+	return params
+}
+
+// VerifyFiles compares the pathfile and parcom files of the merchant
+// directory to the content NewSogen() would write, without writing
+// anything, i.e. when they are provisioned by a configuration management
+// tool. Use a Sogen created in dry run mode (see Config.DryRun), which
+// leaves the files as is. Parameters are compared regardless of their
+// order.
+func (s *Sogen) VerifyFiles() ([]Diff, error) {
+	diffs := make([]Diff, 0)
+	for _, f := range s.merchantFiles() {
+		data, err := ioutil.ReadFile(f.path)
+		if os.IsNotExist(err) {
+			diffs = append(diffs, Diff{File: f.path})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		expected, actual := parseParams(f.data), parseParams(data)
+		keys := make([]string, 0, len(expected)+len(actual))
+		for k := range expected {
+			keys = append(keys, k)
+		}
+		for k := range actual {
+			if _, ok := expected[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			want, ok1 := expected[k]
+			got, ok2 := actual[k]
+			if ok1 && ok2 && want == got {
+				continue
+			}
+			diffs = append(diffs, Diff{File: f.path, Param: k, Expected: want, Actual: got})
+		}
+	}
+	return diffs, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	formRe   = regexp.MustCompile(`(?is)<form[^>]*\saction\s*=\s*["']?([^"'\s>]+)`)
+	inputRe  = regexp.MustCompile(`(?is)<input([^>]*)>`)
+	attrRe   = regexp.MustCompile(`(?is)(\w+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	hiddenRe = regexp.MustCompile(`(?i)^hidden$`)
+	imageRe  = regexp.MustCompile(`(?i)^image$`)
+)
+
+// Form describes the form generated by the request binary, for integrators
+// rendering the checkout themselves (i.e non-HTML frontends). The buyer is
+// redirected to the payment server by posting Fields to Action, along with
+// the name of the chosen card (as NAME.x and NAME.y coordinates).
+type Form struct {
+	Action string            // URL of the payment server
+	Fields map[string]string // Hidden fields, i.e DATA
+	Cards  []Card            // Card buttons
+}
+
+// CheckoutForm runs the request binary for a transaction and returns the
+// parsed form.
+func (s *Sogen) CheckoutForm(t *Transaction) (*Form, error) {
+	if t == nil {
+		return nil, errors.New("nil transaction")
+	}
+	body, _, err := s.request(t)
+	if err != nil {
+		return nil, err
+	}
+	return parseForm(body)
+}
+
+// parseForm extracts the form action, hidden fields and card buttons from
+// the HTML generated by the request binary.
+func parseForm(body string) (*Form, error) {
+	m := formRe.FindStringSubmatch(body)
+	if m == nil {
+		return nil, errors.New("no form found in request output")
+	}
+	f := &Form{Action: html.UnescapeString(m[1]), Fields: make(map[string]string), Cards: make([]Card, 0)}
+	for _, in := range inputRe.FindAllStringSubmatch(body, -1) {
+		attrs := make(map[string]string)
+		for _, a := range attrRe.FindAllStringSubmatch(in[1], -1) {
+			attrs[strings.ToLower(a[1])] = html.UnescapeString(a[2] + a[3] + a[4])
+		}
+		switch {
+		case hiddenRe.MatchString(attrs["type"]):
+			f.Fields[attrs["name"]] = attrs["value"]
+		case imageRe.MatchString(attrs["type"]):
+			f.Cards = append(f.Cards, Card{Name: attrs["name"], Title: paymentMean(attrs["name"]).Name, Logo: attrs["src"]})
+		}
+	}
+	if _, ok := f.Fields["DATA"]; !ok {
+		return nil, errors.New("no DATA field found in request output")
+	}
+	return f, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Decision is the outcome of a fraud check.
+type Decision int
+
+const (
+	Allow  Decision = iota // Go ahead
+	Review                 // Go ahead, but the order should be reviewed before delivery
+	Deny                   // Reject the transaction
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Allow:
+		return "allow"
+	case Review:
+		return "review"
+	case Deny:
+		return "deny"
+	}
+	return "unknown"
+}
+
+// Verdict is a fraud check decision along with its reason.
+type Verdict struct {
+	Decision Decision
+	Reason   string
+}
+
+// FraudPolicy screens transactions before checkout and payments once
+// processed by the payment server.
+type FraudPolicy interface {
+	// CheckTransaction is called before a checkout. A Deny decision
+	// aborts the checkout with a *FraudError.
+	CheckTransaction(t *Transaction) (*Verdict, error)
+	// CheckPayment is called with every payment received. Its verdict is
+	// available in Payment.Fraud.
+	CheckPayment(p *Payment) (*Verdict, error)
+}
+
+// FraudError is returned when a checkout is denied by the fraud policy.
+type FraudError struct {
+	Verdict *Verdict
+}
+
+func (e *FraudError) Error() string {
+	return fmt.Sprintf("transaction denied: %s", e.Verdict.Reason)
+}
+
+// SetFraudPolicy sets the fraud policy used to screen transactions and
+// payments.
+func (s *Sogen) SetFraudPolicy(f FraudPolicy) {
+	s.fraud = f
+}
+
+// Amount returns the amount of the transaction.
+func (t *Transaction) Amount() float64 {
+	return t.amount
+}
+
+// Customer returns the customer of the transaction.
+func (t *Transaction) Customer() *Customer {
+	return t.customer
+}
+
+// Rules is a basic FraudPolicy made of amount caps, country blocks,
+// velocity limits and score thresholds. Zero values disable a rule.
+type Rules struct {
+	MaxAmount float64 // Deny transactions above this amount
+	// Country returns the 2-letter country code of an IP address, i.e.
+	// using a GeoIP database. Required by BlockedCountries.
+	Country          func(ip net.IP) string
+	BlockedCountries []string  // Deny transactions from these countries
+	Velocity         *Velocity // Velocity limits, if any
+	// Score colors of payments to review or deny.
+	ReviewColors []ScoreColor
+	DenyColors   []ScoreColor
+	// Score value thresholds of payments to review or deny.
+	ReviewScore, DenyScore float64
+}
+
+func (r *Rules) CheckTransaction(t *Transaction) (*Verdict, error) {
+	if r.MaxAmount > 0 && t.amount > r.MaxAmount {
+		return &Verdict{Deny, fmt.Sprintf("amount %.2f above %.2f", t.amount, r.MaxAmount)}, nil
+	}
+	ip := net.ParseIP(t.customer.IpAddress)
+	if ip != nil && r.Country != nil && len(r.BlockedCountries) > 0 {
+		country := r.Country(ip)
+		for _, c := range r.BlockedCountries {
+			if strings.EqualFold(c, country) {
+				return &Verdict{Deny, "blocked country " + country}, nil
+			}
+		}
+	}
+	if r.Velocity != nil {
+		return r.Velocity.Check(t)
+	}
+	return &Verdict{Decision: Allow}, nil
+}
+
+func (r *Rules) CheckPayment(p *Payment) (*Verdict, error) {
+	for _, c := range r.DenyColors {
+		if c == p.ScoreColor {
+			return &Verdict{Deny, "score color " + string(p.ScoreColor)}, nil
+		}
+	}
+	if r.DenyScore > 0 && p.ScoreValue >= r.DenyScore {
+		return &Verdict{Deny, fmt.Sprintf("score %g", p.ScoreValue)}, nil
+	}
+	for _, c := range r.ReviewColors {
+		if c == p.ScoreColor {
+			return &Verdict{Review, "score color " + string(p.ScoreColor)}, nil
+		}
+	}
+	if r.ReviewScore > 0 && p.ScoreValue >= r.ReviewScore {
+		return &Verdict{Review, fmt.Sprintf("score %g", p.ScoreValue)}, nil
+	}
+	return &Verdict{Decision: Allow}, nil
+}
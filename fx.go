@@ -0,0 +1,170 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// RateProvider returns the exchange rates used to show buyers an
+// indicative price in their currency. The payment is still charged in the
+// currency of the transaction.
+type RateProvider interface {
+	// Rate returns the units of the to currency worth one unit of the from
+	// currency. Currencies are ISO 4217 numeric codes.
+	Rate(from, to string) (float64, error)
+}
+
+// StaticRates is a RateProvider of fixed rates, keyed by pair of currency
+// codes, i.e. "978/840" for EUR to USD. The inverse of the reverse pair is
+// used if a pair is missing.
+type StaticRates map[string]float64
+
+func (r StaticRates) Rate(from, to string) (float64, error) {
+	if rate, ok := r[from+"/"+to]; ok {
+		return rate, nil
+	}
+	if rate, ok := r[to+"/"+from]; ok && rate != 0 {
+		return 1 / rate, nil
+	}
+	return 0, errors.New(fmt.Sprintf("no exchange rate from %s to %s", from, to))
+}
+
+// loadRates parses the [fx_rates] section of a config file: the rates
+// from the merchant currency, by alphabetic currency code (i.e.
+// USD=1.0852).
+func loadRates(c *envConfig) (map[string]float64, error) {
+	if !c.HasSection("fx_rates") {
+		return nil, nil
+	}
+	names, err := c.Options("fx_rates")
+	if err != nil {
+		return nil, err
+	}
+	rates := make(map[string]float64)
+	for _, name := range names {
+		code := ""
+		for _, cur := range currencies {
+			if cur.Alpha == strings.ToUpper(name) {
+				code = cur.Code
+			}
+		}
+		if code == "" {
+			return nil, errors.New("fx_rates: unknown currency " + name)
+		}
+		rate, err := c.Float("fx_rates", name)
+		if err != nil || rate <= 0 {
+			return nil, errors.New("fx_rates: " + name + ": expected a positive rate")
+		}
+		rates[code] = rate
+	}
+	return rates, nil
+}
+
+// SetRateProvider sets the provider of the exchange rates of the
+// indicative prices (see Transaction.SetDisplayCurrency()). Rates of the
+// [fx_rates] section of the config file are used by default.
+func (s *Sogen) SetRateProvider(p RateProvider) {
+	s.rates = p
+}
+
+// FXQuote is an indicative price shown to the buyer in a foreign currency.
+type FXQuote struct {
+	CurrencyCode string  `json:"c"`
+	Rate         float64 `json:"r"` // Units of CurrencyCode for one unit of the charged currency
+	Amount       int64   `json:"a"` // In the smallest unit of CurrencyCode
+}
+
+// Format formats the indicative price in lang (see FormatAmount()).
+func (q *FXQuote) Format(lang string) string {
+	return formatAmount(q.Amount, q.CurrencyCode, lang)
+}
+
+// SetDisplayCurrency shows the buyer an indicative price in a foreign
+// currency, as an ISO 4217 numeric code, next to the charged amount in
+// the checkout block. The displayed rate is recorded in the
+// return_context (see Payment.DisplayedQuote()). It needs a rate
+// provider; an empty code cancels any previous setting.
+func (t *Transaction) SetDisplayCurrency(code string) error {
+	if code != "" {
+		if _, ok := LookupCurrency(code); !ok {
+			return errors.New("unknown currency code " + code)
+		}
+	}
+	t.display = code
+	t.quote = nil
+	return nil
+}
+
+// DisplayCurrency returns the currency set with SetDisplayCurrency(), if
+// any.
+func (t *Transaction) DisplayCurrency() string {
+	return t.display
+}
+
+// chargedCurrency returns the currency code of the transaction.
+func (s *Sogen) chargedCurrency(t *Transaction) string {
+	if t.currencyCode != "" {
+		return t.currencyCode
+	}
+	return s.config.MerchantCurrencyCode
+}
+
+// quote computes the indicative price of a transaction, if a display
+// currency is set. A missing rate is only logged, the checkout goes on
+// without it.
+func (s *Sogen) quote(t *Transaction) {
+	t.quote = nil
+	if t.display == "" || s.rates == nil {
+		return
+	}
+	from := s.chargedCurrency(t)
+	if from == t.display {
+		return
+	}
+	rate, err := s.rates.Rate(from, t.display)
+	if err != nil {
+		s.config.logf(LogWarning, "Warning: indicative price of transaction %s: %s", t.transId, err.Error())
+		return
+	}
+	decimals := 2
+	if c, ok := LookupCurrency(t.display); ok {
+		decimals = c.Decimals
+	}
+	t.quote = &FXQuote{
+		CurrencyCode: t.display,
+		Rate:         rate,
+		Amount:       int64(math.Floor(t.amount*rate*math.Pow10(decimals) + 0.5)),
+	}
+}
+
+// encodeQuote returns the return_context encoding of a quote.
+func encodeQuote(q *FXQuote) string {
+	data, _ := json.Marshal(q)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DisplayedQuote returns the indicative price shown to the buyer before
+// the payment, if any (see Transaction.SetDisplayCurrency()).
+func (p *Payment) DisplayedQuote() (*FXQuote, error) {
+	_, _, fx := splitReturnContext(p.ReturnContext)
+	if fx == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(fx)
+	if err != nil {
+		return nil, errors.New("bad indicative price encoding: " + err.Error())
+	}
+	q := new(FXQuote)
+	if err := json.Unmarshal(data, q); err != nil {
+		return nil, errors.New("bad indicative price: " + err.Error())
+	}
+	return q, nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// TransactionFunc returns the transaction to check out for a request.
+type TransactionFunc func(r *http.Request) (*Transaction, error)
+
+// PaymentFunc is called with a payment parsed from a response of the
+// payment server. Returning an error aborts the handler with an internal
+// server error.
+type PaymentFunc func(p *Payment) error
+
+// CheckoutHandler returns a handler writing the checkout block of the
+// transaction returned by fn. URLs of the config relative to the server
+// are resolved with the request (see ResolveURLs). With session binding,
+// the transaction is bound to a session cookie.
+func (s *Sogen) CheckoutHandler(fn TransactionFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowClient(w, r) {
+			return
+		}
+		t, err := fn(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if t == nil {
+			http.Error(w, "no transaction", http.StatusBadRequest)
+			return
+		}
+		if t.customer.Id != "" && !s.allow(w, r, "customer:"+t.customer.Id) {
+			return
+		}
+		if t.customer.IpAddress == "" {
+			if ip := s.ClientIP(r); ip != nil {
+				t.customer.IpAddress = ip.String()
+			}
+		}
+		if err := s.ResolveURLs(t, r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		t.requestId = RequestId(r.Context())
+		if s.sessionKey != nil && t.session == "" {
+			id, err := sessionId(w, r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			t.BindSession(id)
+		}
+		if err := s.Checkout(t, w); err != nil {
+			if _, ok := err.(*FraudError); ok {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ReturnHandler returns a handler for the return_url. The payment is
+// passed to fn, if not nil, then the payment accepted, cancellation or
+// declined page is rendered, depending on its response code.
+// A response posted again is detected: fn is not called and the replayed
+// page is rendered instead.
+func (s *Sogen) ReturnHandler(fn PaymentFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowClient(w, r) {
+			return
+		}
+		p, err := s.HandleReturn(w, r)
+		if err == ErrReplayedNotification {
+			s.Replayed(w, p)
+			return
+		}
+		if err == ErrSessionMismatch {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), responseStatus(err))
+			return
+		}
+		if fn != nil {
+			if err := fn(p); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		switch p.ResponseCode {
+		case "00":
+			s.Accepted(w, p)
+		case "17":
+			// Cancelled by the buyer
+			s.Cancelled(w, p)
+		default:
+			s.Declined(w, p)
+		}
+	})
+}
+
+// CancelHandler returns a handler for the cancel_url. The payment server
+// posts the payment data along, in which case the payment is passed to
+// fn, if not nil. The cancellation page is rendered.
+func (s *Sogen) CancelHandler(fn PaymentFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowClient(w, r) {
+			return
+		}
+		var p *Payment
+		data, err := responseData(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), responseStatus(err))
+			return
+		}
+		if data != "" {
+			if p, err = s.handleResponse(RequestId(r.Context()), w, data, nil); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if fn != nil {
+				if err := fn(p); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+		s.Cancelled(w, p)
+	})
+}
+
+// AutoResponseHandler returns a handler for the auto_response_url. The
+// payment is passed to fn, if not nil, unless the response was already
+// processed. If fn fails, the response is
+// forgotten so that the retry of the payment server is processed again
+// (see ProcessedUnmarker). Requests are restricted to the
+// auto_response_ips addresses, if defined in the config. They are not rate
+// limited: they all come from the payment server.
+func (s *Sogen) AutoResponseHandler(fn PaymentFunc) http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := s.HandlePayment(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), responseStatus(err))
+			return
+		}
+		switch err := s.checkReplay(replayAutoResponse, p); err {
+		case nil:
+		case ErrReplayedNotification:
+			fmt.Fprintf(w, "OK")
+			return
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if fn != nil {
+			if err := fn(p); err != nil {
+				s.uncheckReplay(replayAutoResponse, p)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		fmt.Fprintf(w, "OK")
+	})
+	if s.autoResponseIPs != nil {
+		return s.autoResponseIPs.Handler(h)
+	}
+	return h
+}
@@ -0,0 +1,120 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage is the language of the messages written to buyers when
+// no translation is available.
+const DefaultLanguage = "en"
+
+// Messages maps the English text of messages written to buyers to their
+// translation.
+type Messages map[string]string
+
+var (
+	translationsMu sync.RWMutex
+	translations   = map[string]Messages{
+		"fr": {
+			// Pages
+			"Thank you!": "Merci !",
+			"Your payment of %s has been accepted (transaction %s).":    "Votre paiement de %s a été accepté (transaction %s).",
+			"Your payment has been declined.":                           "Votre paiement a été refusé.",
+			"The transaction has been cancelled.":                       "La transaction a été annulée.",
+			"This order has already been confirmed.":                    "Cette commande a déjà été confirmée.",
+			"Order summary":                                             "Récapitulatif de la commande",
+			"Order reference:":                                          "Référence de la commande :",
+			"Amount:":                                                   "Montant :",
+			"Accepted cards:":                                           "Cartes acceptées :",
+			"Choose your card to proceed to the secure payment server:": "Choisissez votre carte pour accéder au serveur de paiement sécurisé :",
+			"Indicative price: %s":                                      "Prix indicatif : %s",
+
+			// Errors
+			"Error:":                                 "Erreur :",
+			"payment is not available at the moment": "le paiement est momentanément indisponible",
+			"order summary not available":            "récapitulatif de commande indisponible",
+			"this order has expired":                 "cette commande a expiré",
+			"payment links not available":            "liens de paiement indisponibles",
+			"this payment link has expired":          "ce lien de paiement a expiré",
+		},
+	}
+)
+
+// RegisterMessages adds translations for a language, i.e. to support a new
+// language or to translate the messages of custom templates. Existing
+// translations are overridden.
+func RegisterMessages(lang string, m Messages) {
+	translationsMu.Lock()
+	defer translationsMu.Unlock()
+	msgs, ok := translations[lang]
+	if !ok {
+		msgs = make(Messages)
+		translations[lang] = msgs
+	}
+	for k, v := range m {
+		msgs[k] = v
+	}
+}
+
+// Translate returns the translation of msg in lang, or msg if there is
+// none.
+func Translate(lang, msg string) string {
+	translationsMu.RLock()
+	defer translationsMu.RUnlock()
+	if t, ok := translations[lang][msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// hasMessages reports whether there are translations for lang.
+func hasMessages(lang string) bool {
+	translationsMu.RLock()
+	defer translationsMu.RUnlock()
+	_, ok := translations[lang]
+	return ok
+}
+
+// TemplateFuncs are the functions available to the templates registered
+// with SetTemplates(): tr translates a message in the language of the
+// transaction, i.e. {{tr "Thank you!"}}, and amount formats an amount in
+// cents of a currency code in a language (see FormatAmount()), i.e.
+// {{amount 1234 "978" "fr"}}. They must be added before parsing:
+//
+//	template.New("accepted").Funcs(sogenactif.TemplateFuncs).Parse(...)
+var TemplateFuncs = template.FuncMap{
+	"tr":     func(msg string) string { return msg },
+	"amount": formatAmount,
+}
+
+// pageLanguage returns the language of the messages written to a buyer:
+// lang, the language of the transaction, if set, else the one of the
+// merchant country if translated, else DefaultLanguage.
+func (s *Sogen) pageLanguage(lang string) string {
+	if lang != "" {
+		return lang
+	}
+	if country := strings.ToLower(s.config.MerchantCountry); hasMessages(country) {
+		return country
+	}
+	return DefaultLanguage
+}
+
+// executeIn executes a template with the messages translated in lang. A
+// template that can't be cloned (executed elsewhere already) is executed
+// as is.
+func executeIn(w io.Writer, tmpl *template.Template, lang string, data interface{}) error {
+	t, err := tmpl.Clone()
+	if err != nil {
+		return tmpl.Execute(w, data)
+	}
+	t.Funcs(template.FuncMap{"tr": func(msg string) string { return Translate(lang, msg) }})
+	return t.Execute(w, data)
+}
@@ -0,0 +1,24 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+)
+
+// Languages of the payment pages.
+var Languages = []string{"fr", "en", "de", "es", "it", "nl"}
+
+// SetLanguage sets the language of the payment pages shown to the buyer,
+// instead of the one of the merchant country.
+func (t *Transaction) SetLanguage(lang string) error {
+	for _, l := range Languages {
+		if l == lang {
+			t.language = lang
+			return nil
+		}
+	}
+	return errors.New("unsupported language " + lang)
+}
@@ -0,0 +1,161 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLockTimeout is the time NewSogen() and NextTransactionId() wait
+// for a lock.
+const DefaultLockTimeout = 30 * time.Second
+
+// Locker provides named locks shared by the instances of an application,
+// i.e. sharing a merchants root directory. NewSogen() holds a lock while
+// generating the files of a merchant.
+type Locker interface {
+	// Lock acquires the lock of a name, waiting until ctx is done. The
+	// returned function releases it.
+	Lock(ctx context.Context, name string) (unlock func() error, err error)
+}
+
+// FileLocker is a Locker using advisory locks (flock) on files of a
+// directory, for instances sharing a host or a file system supporting
+// them. It is the default Locker, on the merchants root directory.
+type FileLocker struct {
+	dir string
+	// Poll is the delay between attempts to acquire a lock, 50ms by
+	// default.
+	Poll time.Duration
+}
+
+// NewFileLocker returns a locker of files named .<name>.lock in dir,
+// created if needed.
+func NewFileLocker(dir string) *FileLocker {
+	return &FileLocker{dir: dir, Poll: 50 * time.Millisecond}
+}
+
+func (l *FileLocker) Lock(ctx context.Context, name string) (func() error, error) {
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(l.dir, "."+name+".lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			return func() error {
+				defer f.Close()
+				return unlockFile(f)
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, errors.New("lock " + name + ": " + ctx.Err().Error())
+		case <-time.After(l.Poll):
+		}
+	}
+}
+
+// Sequencer is implemented by the lockers shared by several hosts, which
+// allocate the transaction IDs of NextTransactionId() in their backend
+// rather than in a file of the merchant directory.
+type Sequencer interface {
+	// Next increments the sequence of a name, starting at 1, and returns
+	// its value. The sequence is dropped after ttl.
+	Next(ctx context.Context, name string, ttl time.Duration) (int64, error)
+}
+
+// locker returns the locker of the config, a FileLocker on the merchants
+// root directory by default.
+func (c *Config) locker() Locker {
+	if c.Locker != nil {
+		return c.Locker
+	}
+	return NewFileLocker(c.MerchantsRootDir)
+}
+
+// lock acquires a lock of the config locker, waiting up to
+// DefaultLockTimeout.
+func (c *Config) lock(name string) (func() error, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultLockTimeout)
+	defer cancel()
+	return c.locker().Lock(ctx, name)
+}
+
+// Largest transaction ID.
+const maxTransactionId = 999999
+
+// NextTransactionId allocates the next transaction ID of the merchant, to
+// be set with SetTransactionId(). IDs are unique over a day (starting at
+// 000001 every day) among all instances sharing the locker. The locker
+// must be a Sequencer, which keeps the sequence of the day, or a
+// FileLocker, in which case the last allocated ID is kept in the
+// transaction_id file of the merchant directory, shared by the instances
+// of a host.
+func (s *Sogen) NextTransactionId() (string, error) {
+	day := s.now().In(s.config.location()).Format("20060102")
+	name := "transaction-id-" + s.config.MerchantId
+	switch l := s.config.locker().(type) {
+	case Sequencer:
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultLockTimeout)
+		defer cancel()
+		// Kept past the day, for the instances whose clock is late
+		n, err := l.Next(ctx, name+"-"+day, 48*time.Hour)
+		if err != nil {
+			return "", err
+		}
+		if n > maxTransactionId {
+			return "", errors.New("no transaction ID left for " + day)
+		}
+		return fmt.Sprintf("%06d", n), nil
+	case *FileLocker:
+	default:
+		return "", errors.New("can't allocate transaction IDs: the locker is neither a Sequencer nor a FileLocker")
+	}
+	unlock, err := s.config.lock(name)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	file := filepath.Join(s.merchantBaseDir, "transaction_id")
+	last := 0
+	data, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if fields := strings.Fields(string(data)); len(fields) == 2 && fields[0] == day {
+		if last, err = strconv.Atoi(fields[1]); err != nil {
+			return "", errors.New(file + ": bad transaction ID: " + err.Error())
+		}
+	}
+	if last >= maxTransactionId {
+		return "", errors.New("no transaction ID left for " + day)
+	}
+	last++
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(fmt.Sprintf("%s %d\n", day, last)), 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", last), nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+// LogLevel is the minimum level of the messages logged by a Sogen.
+type LogLevel int
+
+const (
+	LogInfo    LogLevel = iota // Setup steps, warnings and errors (default)
+	LogWarning                 // Warnings and errors
+	LogError                   // Errors only
+	LogSilent                  // Nothing
+)
+
+var logLevels = map[string]LogLevel{
+	"info":    LogInfo,
+	"warning": LogWarning,
+	"error":   LogError,
+	"silent":  LogSilent,
+}
+
+func (l LogLevel) String() string {
+	for name, level := range logLevels {
+		if level == l {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// ParseLogLevel returns the level of a name: info, warning, error or
+// silent.
+func ParseLogLevel(name string) (LogLevel, error) {
+	l, ok := logLevels[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return LogInfo, errors.New("unknown log level " + name)
+	}
+	return l, nil
+}
+
+// logf logs a message of a level with the logger of the config, if the
+// level is enabled.
+func (c *Config) logf(level LogLevel, format string, v ...interface{}) {
+	if level < c.LogLevel {
+		return
+	}
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// Logf logs a message of a level with the logger of the config, i.e. from
+// the payment hooks of other packages.
+func (s *Sogen) Logf(level LogLevel, format string, v ...interface{}) {
+	s.config.logf(level, format, v...)
+}
@@ -0,0 +1,57 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"path"
+)
+
+// DefaultLogosTemplate renders the logos of the accepted cards.
+var DefaultLogosTemplate = template.Must(template.New(TemplateLogos).Parse(
+	`<span class="sogen-cards">{{range .}}<img src="{{.Logo}}" alt="{{.Title}}" title="{{.Title}}"> {{end}}</span>`))
+
+// Cards returns the payment means of the config (PAYMENT_MEANS) along with
+// the URL of their logo, i.e. for storefronts to show the accepted cards
+// on product pages.
+func (s *Sogen) Cards() []Card {
+	return s.acceptedCards()
+}
+
+// Logos renders the logos of the accepted cards. A nil tmpl uses the
+// "logos" template registered with SetTemplates(), or
+// DefaultLogosTemplate.
+func (s *Sogen) Logos(w io.Writer, tmpl *template.Template) error {
+	if tmpl == nil {
+		tmpl = s.template(TemplateLogos)
+	}
+	if tmpl == nil {
+		tmpl = DefaultLogosTemplate
+	}
+	return tmpl.Execute(w, s.acceptedCards())
+}
+
+// LogosHandler is like MediaHandler() but only serves the logos of the
+// accepted cards, so that the logos shown are consistent with the checkout
+// configuration:
+//
+//	http.Handle(conf.LogoPath, sogen.LogosHandler())
+func (s *Sogen) LogosHandler() http.Handler {
+	logos := make(map[string]bool)
+	for _, c := range s.acceptedCards() {
+		logos[path.Base(c.Logo)] = true
+	}
+	media := s.MediaHandler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Base(r.URL.Path)
+		if r.URL.Path != path.Join(s.config.LogoPath, name) || !logos[name] {
+			http.NotFound(w, r)
+			return
+		}
+		media.ServeHTTP(w, r)
+	})
+}
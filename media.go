@@ -0,0 +1,38 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// DefaultLogoPath is the URL path of the media files if logo_path is not
+// set.
+const DefaultLogoPath = "/media/"
+
+// Card logos and payment imagery shipped with the package.
+//
+//go:embed media/*.gif
+var media embed.FS
+
+// MediaHandler returns a handler serving the media files (card logos etc.)
+// at the logo path of the config:
+//
+//	http.Handle(conf.LogoPath, sogen.MediaHandler())
+//
+// The files of the media path of the config are served if set, the files
+// embedded in the package otherwise.
+func (s *Sogen) MediaHandler() http.Handler {
+	var root http.FileSystem
+	if s.config.MediaPath != "" {
+		root = http.Dir(s.config.MediaPath)
+	} else {
+		sub, _ := fs.Sub(media, "media")
+		root = http.FS(sub)
+	}
+	return http.StripPrefix(s.config.LogoPath, http.FileServer(root))
+}
@@ -0,0 +1,190 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// MerchantConfig holds the settings of a merchant of a [merchant "<id>"]
+// section of a config file. Unset values are the ones of the
+// [sogenactif] section.
+type MerchantConfig struct {
+	Id              string
+	Country         string
+	CurrencyCode    string
+	CancelUrl       *url.URL
+	ReturnUrl       *url.URL
+	AutoResponseUrl *url.URL
+	PaymentMeans    string // i.e. AMEX,2 for an American Express contract
+}
+
+// merchantSection returns the merchant ID of a [merchant "<id>"] section.
+func merchantSection(section string) (string, bool) {
+	if !strings.HasPrefix(section, "merchant ") {
+		return "", false
+	}
+	id := strings.TrimSpace(strings.TrimPrefix(section, "merchant "))
+	if len(id) < 2 || id[0] != '"' || id[len(id)-1] != '"' {
+		return "", false
+	}
+	return strings.TrimSpace(id[1 : len(id)-1]), true
+}
+
+// hasMerchants reports whether a config file has merchant sections.
+func hasMerchants(c *envConfig) bool {
+	for _, section := range c.Sections() {
+		if _, ok := merchantSection(section); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadMerchants parses the merchant sections of a config file.
+func loadMerchants(c *envConfig) ([]MerchantConfig, error) {
+	list := make([]MerchantConfig, 0)
+	for _, section := range c.Sections() {
+		id, ok := merchantSection(section)
+		if !ok {
+			continue
+		}
+		if id == "" {
+			return nil, errors.New("merchant section without ID")
+		}
+		m := MerchantConfig{Id: id}
+		for name, v := range map[string]*string{
+			"merchant_country":       &m.Country,
+			"merchant_currency_code": &m.CurrencyCode,
+			"payment_means":          &m.PaymentMeans,
+		} {
+			if s, err := c.String(section, name); err == nil {
+				*v = strings.TrimSpace(s)
+			}
+		}
+		for name, u := range map[string]**url.URL{
+			"cancel_url":        &m.CancelUrl,
+			"return_url":        &m.ReturnUrl,
+			"auto_response_url": &m.AutoResponseUrl,
+		} {
+			s, err := c.String(section, name)
+			if err != nil || strings.TrimSpace(s) == "" {
+				continue
+			}
+			p, err := url.Parse(strings.TrimSpace(s))
+			if err == nil {
+				p, err = handleQuery(p)
+			}
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("merchant %s: %s: %s", id, name, err.Error()))
+			}
+			*u = p
+		}
+		list = append(list, m)
+	}
+	return list, nil
+}
+
+// ForMerchant returns a copy of the config for a merchant of the config
+// file.
+func (c *Config) ForMerchant(m MerchantConfig) *Config {
+	mc := *c
+	mc.Merchants = nil
+	mc.MerchantId = m.Id
+	if m.Country != "" {
+		mc.MerchantCountry = m.Country
+	}
+	if m.CurrencyCode != "" {
+		mc.MerchantCurrencyCode = m.CurrencyCode
+	}
+	if m.CancelUrl != nil {
+		mc.CancelUrl = m.CancelUrl
+	}
+	if m.ReturnUrl != nil {
+		mc.ReturnUrl = m.ReturnUrl
+	}
+	if m.AutoResponseUrl != nil {
+		mc.AutoResponseUrl = m.AutoResponseUrl
+	}
+	if m.PaymentMeans != "" {
+		mc.PaymentMeans = m.PaymentMeans
+	}
+	return &mc
+}
+
+// Registry holds the Sogen instances of several merchants, keyed by
+// merchant ID.
+type Registry struct {
+	sogens map[string]*Sogen
+	main   string // Merchant of the [sogenactif] section, if any
+}
+
+// NewRegistry sets up the merchants of a config: the one of the
+// [sogenactif] section, if any, and the ones of the merchant sections.
+func NewRegistry(c *Config) (*Registry, error) {
+	if c == nil {
+		return nil, ErrNilConfig
+	}
+	configs := make([]*Config, 0)
+	if c.MerchantId != "" {
+		base := *c
+		base.Merchants = nil
+		configs = append(configs, &base)
+	}
+	for _, m := range c.Merchants {
+		configs = append(configs, c.ForMerchant(m))
+	}
+	r := &Registry{sogens: make(map[string]*Sogen), main: c.MerchantId}
+	for _, mc := range configs {
+		if _, ok := r.sogens[mc.MerchantId]; ok {
+			return nil, errors.New("duplicate merchant " + mc.MerchantId)
+		}
+		s, err := NewSogen(mc)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("merchant %s: %s", mc.MerchantId, err.Error()))
+		}
+		r.sogens[mc.MerchantId] = s
+	}
+	return r, nil
+}
+
+// Merchant returns the Sogen instance of a merchant.
+func (r *Registry) Merchant(id string) (*Sogen, bool) {
+	s, ok := r.sogens[id]
+	return s, ok
+}
+
+// ForPaymentMean returns the Sogen instance of the merchant accepting a
+// payment mean, i.e. the one of a separate American Express contract for
+// AMEX. The merchant of the [sogenactif] section is preferred.
+func (r *Registry) ForPaymentMean(code string) (*Sogen, bool) {
+	var found *Sogen
+	for _, id := range r.Ids() {
+		s := r.sogens[id]
+		for _, c := range s.config.configMeans() {
+			if c != code {
+				continue
+			}
+			if found == nil || id == r.main {
+				found = s
+			}
+		}
+	}
+	return found, found != nil
+}
+
+// Ids returns the sorted IDs of the merchants.
+func (r *Registry) Ids() []string {
+	ids := make([]string, 0, len(r.sogens))
+	for id := range r.sogens {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
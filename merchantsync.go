@@ -0,0 +1,85 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ObjectStore provides the content of the merchants root directory from
+// an object-storage bucket, so that several instances of an application
+// share the same certificates and parameter files. See the sogenaws (S3)
+// and sogengcs packages.
+type ObjectStore interface {
+	// List returns the names of all objects, relative to the root
+	// directory (i.e. 011223344551111/certif.fr.011223344551111.php).
+	List(ctx context.Context) ([]string, error)
+	// Get returns the content of an object.
+	Get(ctx context.Context, name string) ([]byte, error)
+}
+
+// SyncDir copies all objects of st to dir. Existing files are replaced;
+// other files of dir are left untouched.
+func SyncDir(ctx context.Context, st ObjectStore, dir string) error {
+	_, err := syncDir(ctx, st, dir)
+	return err
+}
+
+// syncDir copies all objects of st to dir and returns the number of
+// objects.
+func syncDir(ctx context.Context, st ObjectStore, dir string) (int, error) {
+	names, err := st.List(ctx)
+	if err != nil {
+		return 0, errors.New("object store: " + err.Error())
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, "/") {
+			// Directory marker
+			continue
+		}
+		clean := path.Clean("/" + name)[1:]
+		if clean == "" || clean != strings.TrimPrefix(name, "/") {
+			return 0, errors.New("object store: bad object name " + name)
+		}
+		data, err := st.Get(ctx, name)
+		if err != nil {
+			return 0, errors.New("object store: " + name + ": " + err.Error())
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(clean))
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return 0, err
+		}
+		// Write then rename so that the binaries never read a partial file
+		tmp := dst + ".sync"
+		if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+			return 0, err
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			os.Remove(tmp)
+			return 0, err
+		}
+	}
+	return len(names), nil
+}
+
+// SyncMerchantsDir syncs the merchants root directory from the object
+// store of the config, i.e. to pick up a renewed certificate.
+func (s *Sogen) SyncMerchantsDir(ctx context.Context) error {
+	if s.config.MerchantsStore == nil {
+		return errors.New("no merchants object store")
+	}
+	n, err := syncDir(ctx, s.config.MerchantsStore, s.config.MerchantsRootDir)
+	if err != nil {
+		return err
+	}
+	s.config.logf(LogInfo, "Synced %d files to %s", n, s.config.MerchantsRootDir)
+	return nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package notify emails payment outcomes: a receipt to the buyer and an
+// alert to the merchant, on accepted and declined payments:
+//
+//	n := &notify.Notifier{
+//		Mailer:   notify.NewSMTPMailer("smtp.example.com:587", auth),
+//		From:     "shop@example.com",
+//		Merchant: &receipt.Merchant{Name: "Funky Shop"},
+//		Alerts:   []string{"sales@example.com"},
+//	}
+//	n.Register(sogen)
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gotsunami/sogenactif"
+	"github.com/gotsunami/sogenactif/receipt"
+	"html/template"
+	"net/smtp"
+	"strings"
+)
+
+// Message is an HTML email.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	HTML    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(m *Message) error
+}
+
+// SMTPMailer sends emails through an SMTP server.
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer returns a mailer using the SMTP server at addr
+// (host:port). auth may be nil.
+func NewSMTPMailer(addr string, auth smtp.Auth) *SMTPMailer {
+	return &SMTPMailer{addr: addr, auth: auth}
+}
+
+func (s *SMTPMailer) Send(m *Message) error {
+	if len(m.To) == 0 {
+		return errors.New("no recipient")
+	}
+	var b bytes.Buffer
+	b.WriteString("From: " + m.From + "\r\n")
+	b.WriteString("To: " + strings.Join(m.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + m.Subject + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
+	b.WriteString(m.HTML)
+	return smtp.SendMail(s.addr, s.auth, m.From, m.To, b.Bytes())
+}
+
+// Names of the templates looked up in Notifier.Templates.
+const (
+	// Email to the buyer of an accepted payment, executed with a
+	// *receipt.Receipt.
+	TemplateBuyerAccepted = "buyer_accepted"
+	// Email to the merchant, executed with a *sogenactif.Payment.
+	TemplateMerchantAccepted = "merchant_accepted"
+	TemplateMerchantDeclined = "merchant_declined"
+)
+
+// DefaultTemplates holds the default merchant emails. The buyer email is
+// the receipt (see receipt.Template) by default.
+var DefaultTemplates = template.Must(template.New(TemplateMerchantAccepted).Parse(`<html><body>
+<p>Payment accepted: {{printf "%.2f" .Amount}} (transaction {{.TransactionId}}, customer {{.CustomerId}}).</p>
+</body></html>`))
+
+func init() {
+	template.Must(DefaultTemplates.New(TemplateMerchantDeclined).Parse(`<html><body>
+<p>Payment declined: {{printf "%.2f" .Amount}} (transaction {{.TransactionId}}, customer {{.CustomerId}},
+response code {{.ResponseCode}}, bank response code {{.BankResponseCode}}).</p>
+</body></html>`))
+}
+
+// Notifier emails payment outcomes. Buyers get a receipt of accepted
+// payments at their customer email, if any; Alerts get all outcomes.
+type Notifier struct {
+	Mailer   Mailer
+	From     string
+	Merchant *receipt.Merchant
+	Alerts   []string          // Merchant addresses
+	Language string            // Language of the buyer emails
+	Subjects map[string]string // Subjects per template name, if not the default ones
+	// Templates overrides the default emails; missing ones fall back to
+	// DefaultTemplates.
+	Templates *template.Template
+}
+
+var defaultSubjects = map[string]string{
+	TemplateBuyerAccepted:    "Your payment receipt",
+	TemplateMerchantAccepted: "Payment accepted",
+	TemplateMerchantDeclined: "Payment declined",
+}
+
+// Register sets up the notifier as payment hooks of s.
+func (n *Notifier) Register(s *sogenactif.Sogen) {
+	s.OnPaymentAccepted(func(p *sogenactif.Payment) {
+		if err := n.Accepted(p); err != nil {
+			s.Logf(sogenactif.LogError, "notify: %s", err.Error())
+		}
+	})
+	s.OnPaymentDeclined(func(p *sogenactif.Payment) {
+		if err := n.Declined(p); err != nil {
+			s.Logf(sogenactif.LogError, "notify: %s", err.Error())
+		}
+	})
+}
+
+func (n *Notifier) send(name string, to []string, data interface{}) error {
+	if len(to) == 0 {
+		return nil
+	}
+	var tmpl *template.Template
+	if n.Templates != nil {
+		tmpl = n.Templates.Lookup(name)
+	}
+	if tmpl == nil {
+		tmpl = DefaultTemplates.Lookup(name)
+	}
+	var body bytes.Buffer
+	var err error
+	if r, ok := data.(*receipt.Receipt); ok && tmpl == nil {
+		err = r.HTML(&body)
+	} else {
+		err = tmpl.Execute(&body, data)
+	}
+	if err != nil {
+		return err
+	}
+	subject := n.Subjects[name]
+	if subject == "" {
+		subject = defaultSubjects[name]
+	}
+	return n.Mailer.Send(&Message{From: n.From, To: to, Subject: subject, HTML: body.String()})
+}
+
+// Accepted emails the receipt of an accepted payment to the buyer and an
+// alert to the merchant.
+func (n *Notifier) Accepted(p *sogenactif.Payment) error {
+	if p.CustomerEmail != "" {
+		r, err := receipt.New(n.Merchant, p, n.Language)
+		if err != nil {
+			return err
+		}
+		if err := n.send(TemplateBuyerAccepted, []string{p.CustomerEmail}, r); err != nil {
+			return err
+		}
+	}
+	return n.send(TemplateMerchantAccepted, n.Alerts, p)
+}
+
+// Declined emails an alert to the merchant.
+func (n *Notifier) Declined(p *sogenactif.Payment) error {
+	return n.send(TemplateMerchantDeclined, n.Alerts, p)
+}
@@ -0,0 +1,71 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"net/http"
+	"time"
+)
+
+// Webhook posts a summary of payment outcomes to a chat incoming webhook
+// (Slack, Mattermost or any service accepting a {"text": ...} JSON
+// payload).
+type Webhook struct {
+	url    string
+	Client *http.Client
+}
+
+// NewWebhook returns a notifier posting to the webhook URL, i.e. the
+// webhook_url of a merchant config.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Register sets up the webhook as payment hooks of s.
+func (h *Webhook) Register(s *sogenactif.Sogen) {
+	fn := func(p *sogenactif.Payment) {
+		if err := h.Post(p); err != nil {
+			s.Logf(sogenactif.LogError, "notify: webhook: %s", err.Error())
+		}
+	}
+	s.OnPaymentAccepted(fn)
+	s.OnPaymentDeclined(fn)
+}
+
+// Summary returns a one-line summary of a payment outcome.
+func Summary(p *sogenactif.Payment) string {
+	currency := p.CurrencyCode
+	if c, ok := sogenactif.LookupCurrency(p.CurrencyCode); ok {
+		currency = c.Alpha
+	}
+	outcome := "accepted"
+	if p.ResponseCode != "00" {
+		outcome = "declined"
+	}
+	return fmt.Sprintf("Payment %s: %.2f %s, customer %s, transaction %s (response code %s)",
+		outcome, p.Amount, currency, p.CustomerId, p.TransactionId, p.ResponseCode)
+}
+
+// Post posts the summary of a payment.
+func (h *Webhook) Post(p *sogenactif.Payment) error {
+	body, err := json.Marshal(map[string]string{"text": Summary(p)})
+	if err != nil {
+		return err
+	}
+	resp, err := h.Client.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New("webhook returned " + resp.Status)
+	}
+	return nil
+}
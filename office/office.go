@@ -0,0 +1,289 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package office implements a client for the Sogenactif Office server
+// (server-to-server) operations, which apply to transactions previously
+// created with the payment interface: capture (validation), cancellation,
+// refund (credit), duplication and diagnostic (status query).
+//
+// A transaction is identified by its merchant, its transaction_id and its
+// payment date:
+//
+//	c := office.NewClient("014213245611111", "fr", office.NewHTTPTransport(u))
+//	res, err := c.Capture(ctx, "123456", paymentDate, 1050, "978")
+//
+// Amounts are given in the smallest unit of the currency (cents for euros).
+//
+// This package is experimental. The Office component of the bank is not
+// described in the documentation of the payment interface (see doc/): the
+// fields exchanged with the gateway are this package's own, and requests
+// are neither signed nor authenticated. Only use it with a gateway on a
+// trusted network, which relays the operations to the bank.
+package office
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operation is an operation of the Office component.
+type Operation string
+
+const (
+	Validate   Operation = "VALIDATE"   // Capture of a transaction waiting for validation
+	Cancel     Operation = "CANCEL"     // Partial or total cancellation
+	Credit     Operation = "CREDIT"     // Partial or total refund
+	Duplicate  Operation = "DUPLICATE"  // New transaction using the card of a previous one
+	Diagnostic Operation = "DIAGNOSTIC" // Current status of a transaction
+)
+
+// Transaction statuses, as returned in the new_status field.
+const (
+	StatusCancelled   = "CANCELLED"
+	StatusCaptured    = "CAPTURED"
+	StatusCredited    = "CREDITED"
+	StatusToCapture   = "TO_CAPTURE"
+	StatusToCredit    = "TO_CREDIT"
+	StatusToValidate  = "TO_VALIDATE"
+	StatusRefused     = "REFUSED"
+	StatusExpired     = "EXPIRED"
+	StatusToAuthorize = "TO_AUTHORIZE"
+)
+
+// Response code of a successful operation.
+const CodeOK = "00"
+
+// Meaning of the Office response codes.
+var responseCodes = map[string]string{
+	"00": "operation accepted",
+	"02": "authorization by phone required",
+	"03": "invalid merchant_id or missing contract",
+	"05": "authorization refused",
+	"12": "invalid transaction",
+	"14": "invalid card details",
+	"24": "operation not compatible with the transaction status",
+	"25": "transaction not found",
+	"30": "format error",
+	"34": "fraud suspicion",
+	"40": "operation not supported for this merchant",
+	"90": "service temporarily unavailable",
+}
+
+// Transport sends the fields of an operation to the Office server and
+// returns the fields of its response.
+type Transport interface {
+	Do(ctx context.Context, fields map[string]string) (map[string]string, error)
+}
+
+// HTTPTransport posts the operation fields (form-encoded) to an Office
+// gateway and decodes a form-encoded response.
+type HTTPTransport struct {
+	URL    *url.URL
+	Client *http.Client
+}
+
+// NewHTTPTransport returns a transport to the Office gateway at u.
+func NewHTTPTransport(u *url.URL) *HTTPTransport {
+	return &HTTPTransport{URL: u, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (h *HTTPTransport) Do(ctx context.Context, fields map[string]string) (map[string]string, error) {
+	if h.URL == nil {
+		return nil, errors.New("office: missing gateway URL")
+	}
+	form := url.Values{}
+	for k, v := range fields {
+		form.Set(k, v)
+	}
+	req, err := http.NewRequest("POST", h.URL.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("office: gateway returned %s", resp.Status))
+	}
+	vals, err := url.ParseQuery(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, errors.New("office: bad response: " + err.Error())
+	}
+	res := make(map[string]string)
+	for k := range vals {
+		res[k] = vals.Get(k)
+	}
+	return res, nil
+}
+
+// Response holds the result of an operation.
+type Response struct {
+	Operation       Operation
+	TransactionId   string
+	ResponseCode    string
+	NewAmount       int64  // Amount of the transaction after the operation
+	NewStatus       string // Status of the transaction after the operation, current one for a diagnostic
+	CreditAmount    int64  // Amount sent to the bank for a refund
+	AuthorisationId string
+	TransactionDate time.Time
+}
+
+// Error is returned when the Office server refuses an operation.
+type Error struct {
+	Operation    Operation
+	ResponseCode string
+}
+
+func (e *Error) Error() string {
+	msg, ok := responseCodes[e.ResponseCode]
+	if !ok {
+		msg = "unknown error"
+	}
+	return fmt.Sprintf("office: %s refused: %s (response code %s)", strings.ToLower(string(e.Operation)),
+		msg, e.ResponseCode)
+}
+
+// Client performs operations on the transactions of a merchant.
+type Client struct {
+	MerchantId      string
+	MerchantCountry string
+	// Origin of the operations (i.e program name), reported in the daily
+	// operations journal.
+	Origin    string
+	Transport Transport
+}
+
+// NewClient returns a client for a merchant.
+func NewClient(merchantId, merchantCountry string, t Transport) *Client {
+	return &Client{MerchantId: merchantId, MerchantCountry: merchantCountry, Origin: "sogenactif", Transport: t}
+}
+
+// Capture validates (sends to the bank) amount cents of a transaction
+// waiting for validation. Capturing less than the authorized amount is
+// allowed.
+func (c *Client) Capture(ctx context.Context, transactionId string, paymentDate time.Time, amount int64, currencyCode string) (*Response, error) {
+	return c.do(ctx, Validate, transactionId, paymentDate, amount, currencyCode, nil)
+}
+
+// Cancel cancels amount cents of a transaction that has not been sent to the
+// bank yet.
+func (c *Client) Cancel(ctx context.Context, transactionId string, paymentDate time.Time, amount int64, currencyCode string) (*Response, error) {
+	return c.do(ctx, Cancel, transactionId, paymentDate, amount, currencyCode, nil)
+}
+
+// Refund credits amount cents of a transaction already sent to the bank.
+func (c *Client) Refund(ctx context.Context, transactionId string, paymentDate time.Time, amount int64, currencyCode string) (*Response, error) {
+	return c.do(ctx, Credit, transactionId, paymentDate, amount, currencyCode, nil)
+}
+
+// Duplicate creates a new transaction newTransactionId of amount cents, using
+// the card details of a previous transaction.
+func (c *Client) Duplicate(ctx context.Context, transactionId string, paymentDate time.Time, newTransactionId string, amount int64, currencyCode string) (*Response, error) {
+	if newTransactionId == "" {
+		return nil, errors.New("office: missing new transaction ID")
+	}
+	return c.do(ctx, Duplicate, newTransactionId, time.Time{}, amount, currencyCode, map[string]string{
+		"from_transaction_id": transactionId,
+		"from_payment_date":   paymentDate.Format("20060102"),
+	})
+}
+
+// Diagnostic returns the current status and amount of a transaction,
+// without changing it.
+func (c *Client) Diagnostic(ctx context.Context, transactionId string, paymentDate time.Time) (*Response, error) {
+	return c.do(ctx, Diagnostic, transactionId, paymentDate, 0, "", nil)
+}
+
+func (c *Client) do(ctx context.Context, op Operation, transactionId string, paymentDate time.Time,
+	amount int64, currencyCode string, extra map[string]string) (*Response, error) {
+	if c.Transport == nil {
+		return nil, errors.New("office: no transport")
+	}
+	if transactionId == "" {
+		return nil, errors.New("office: missing transaction ID")
+	}
+	if amount <= 0 && op != Diagnostic {
+		return nil, errors.New("office: amount must be positive")
+	}
+	fields := map[string]string{
+		"operation":        string(op),
+		"merchant_id":      c.MerchantId,
+		"merchant_country": c.MerchantCountry,
+		"transaction_id":   transactionId,
+		"origin":           c.Origin,
+	}
+	if op != Diagnostic {
+		fields["amount"] = strconv.FormatInt(amount, 10)
+		fields["currency_code"] = currencyCode
+	}
+	if !paymentDate.IsZero() {
+		fields["payment_date"] = paymentDate.Format("20060102")
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+	out, err := c.Transport.Do(ctx, fields)
+	if err != nil {
+		return nil, err
+	}
+	res, err := parseResponse(op, transactionId, out)
+	if err != nil {
+		return nil, err
+	}
+	if res.ResponseCode != CodeOK {
+		return res, &Error{Operation: op, ResponseCode: res.ResponseCode}
+	}
+	return res, nil
+}
+
+func parseResponse(op Operation, transactionId string, out map[string]string) (*Response, error) {
+	res := &Response{
+		Operation:       op,
+		TransactionId:   transactionId,
+		ResponseCode:    out["response_code"],
+		NewStatus:       out["new_status"],
+		AuthorisationId: out["authorisation_id"],
+	}
+	if res.NewStatus == "" {
+		res.NewStatus = out["transaction_status"]
+	}
+	if res.ResponseCode == "" {
+		return nil, errors.New("office: missing response code")
+	}
+	var err error
+	if v := out["new_amount"]; v != "" {
+		if res.NewAmount, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.New("office: bad new_amount: " + err.Error())
+		}
+	}
+	if v := out["credit_amount"]; v != "" {
+		if res.CreditAmount, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.New("office: bad credit_amount: " + err.Error())
+		}
+	}
+	if v := out["transaction_date"]; v != "" {
+		if res.TransactionDate, err = time.Parse("20060102", v); err != nil {
+			return nil, errors.New("office: bad transaction_date: " + err.Error())
+		}
+	}
+	return res, nil
+}
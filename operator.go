@@ -0,0 +1,35 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"context"
+	"time"
+)
+
+// Operator performs the server-to-server operations on transactions
+// created with the payment interface, through the Office component of the
+// bank. Transactions are identified by their ID and payment date, amounts
+// are in cents. Each operation returns the status of the transaction after
+// it, if known.
+//
+// The office package is an experimental client of an Office gateway whose
+// requests are neither signed nor authenticated: it is not an Operator,
+// and wrapping it should be limited to gateways on a trusted network.
+type Operator interface {
+	// Capture sends amount of a transaction in validation mode to the
+	// bank.
+	Capture(ctx context.Context, transactionId string, paymentDate time.Time, amount int64, currencyCode string) (string, error)
+	// Cancel cancels amount of a transaction not sent to the bank yet.
+	Cancel(ctx context.Context, transactionId string, paymentDate time.Time, amount int64, currencyCode string) (string, error)
+	// Credit refunds amount of a transaction already sent to the bank.
+	Credit(ctx context.Context, transactionId string, paymentDate time.Time, amount int64, currencyCode string) (string, error)
+}
+
+// SetOperator sets the operator used for server-to-server operations on
+// payments (captures and refunds).
+func (s *Sogen) SetOperator(o Operator) {
+	s.operator = o
+}
@@ -0,0 +1,106 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DATA directives of the payments through a digital wallet (Paylib etc.).
+// The payment server sends them back in the response.
+const (
+	walletIndicatorKey  = "WALLET_INDICATOR"
+	walletAliasUsageKey = "WALLET_ALIAS_USAGE"
+)
+
+// WalletIndicator identifies the digital wallet used to pay. Its payment
+// mean must be offered to the buyer (see SetPaymentMeans()).
+type WalletIndicator string
+
+const (
+	WalletPaylib       WalletIndicator = "PAYLIB"
+	WalletMasterpass   WalletIndicator = "MASTERPASS"
+	WalletVisaCheckout WalletIndicator = "VISA_CHECKOUT"
+)
+
+// AliasUsage tells the wallet how the card chosen by the buyer is used.
+type AliasUsage string
+
+const (
+	// The card is used for this payment only.
+	AliasUsageOneShot AliasUsage = "ONE_SHOT"
+	// The card may be charged again without the buyer, i.e. for a
+	// subscription.
+	AliasUsageRecurring AliasUsage = "RECURRING"
+)
+
+// WalletOptions are the options of a payment through a digital wallet.
+type WalletOptions struct {
+	Indicator  WalletIndicator `json:"indicator"`
+	AliasUsage AliasUsage      `json:"alias_usage,omitempty"` // Empty for the wallet's default
+}
+
+// directives returns the DATA directives of the options, if any.
+func (w *WalletOptions) directives() string {
+	if w.Indicator == "" {
+		return ""
+	}
+	d := walletIndicatorKey + "=" + string(w.Indicator)
+	if w.AliasUsage != "" {
+		d += ";" + walletAliasUsageKey + "=" + string(w.AliasUsage)
+	}
+	return d
+}
+
+// SetWallet pays the transaction through a digital wallet such as Paylib.
+// Options with an empty indicator cancel any previous setting.
+func (t *Transaction) SetWallet(w WalletOptions) error {
+	if w.Indicator == "" {
+		t.wallet = WalletOptions{}
+		return nil
+	}
+	if strings.ContainsAny(string(w.Indicator), ";=") {
+		return errors.New(fmt.Sprintf("wallet: invalid indicator %q", w.Indicator))
+	}
+	switch w.AliasUsage {
+	case "", AliasUsageOneShot, AliasUsageRecurring:
+	default:
+		return errors.New(fmt.Sprintf("wallet: unknown alias usage %q", w.AliasUsage))
+	}
+	t.wallet = w
+	return nil
+}
+
+// Wallet returns the digital wallet options of the transaction, if any.
+func (t *Transaction) Wallet() WalletOptions {
+	return t.wallet
+}
+
+// parseWallet reads the wallet options sent back by the payment server.
+// The indicator is the code of the payment mean for a wallet mean (see
+// KindWallet) without directives.
+func parseWallet(p *Payment) WalletOptions {
+	var w WalletOptions
+	if v, ok := dataDirective(p.Data, walletIndicatorKey); ok {
+		w.Indicator = WalletIndicator(v)
+	} else if p.Mean().Kind == KindWallet {
+		w.Indicator = WalletIndicator(p.PaymentMeans)
+	}
+	if w.Indicator == "" {
+		return w
+	}
+	if v, ok := dataDirective(p.Data, walletAliasUsageKey); ok {
+		w.AliasUsage = AliasUsage(v)
+	}
+	return w
+}
+
+// IsWallet reports whether the payment was made through a digital wallet
+// rather than by entering card details.
+func (p *Payment) IsWallet() bool {
+	return p.Wallet.Indicator != ""
+}
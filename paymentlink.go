@@ -0,0 +1,181 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+)
+
+// PendingTransaction is a transaction waiting for the customer to pay,
+// i.e through a payment link.
+type PendingTransaction struct {
+	Token       string // Unique token identifying the transaction
+	Transaction *Transaction
+	Created     time.Time
+	Expires     time.Time
+	Responded   bool // Whether the payment server responded, with a declined payment
+}
+
+// TransactionStore persists pending transactions.
+type TransactionStore interface {
+	// SaveTransaction creates or updates a pending transaction.
+	SaveTransaction(p *PendingTransaction) error
+	// Transaction returns the pending transaction matching token.
+	Transaction(token string) (*PendingTransaction, error)
+	// DeleteTransaction removes a pending transaction.
+	DeleteTransaction(token string) error
+	// MarkProcessed records the key of a processed response of the payment
+	// server and reports whether it was already recorded.
+	MarkProcessed(key string) (bool, error)
+}
+
+// MemoryTransactionStore is an in-memory TransactionStore. Processed
+// response keys are kept for the lifetime of the store.
+type MemoryTransactionStore struct {
+	mu        sync.Mutex
+	pending   map[string]*PendingTransaction
+	processed map[string]bool
+}
+
+// NewMemoryTransactionStore returns an empty in-memory transaction store.
+func NewMemoryTransactionStore() *MemoryTransactionStore {
+	return &MemoryTransactionStore{
+		pending:   make(map[string]*PendingTransaction),
+		processed: make(map[string]bool),
+	}
+}
+
+func (m *MemoryTransactionStore) SaveTransaction(p *PendingTransaction) error {
+	if p == nil {
+		return errors.New("nil pending transaction")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending[p.Token] = p
+	return nil
+}
+
+func (m *MemoryTransactionStore) Transaction(token string) (*PendingTransaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.pending[token]
+	if !ok {
+		return nil, errors.New("no pending transaction " + token)
+	}
+	return p, nil
+}
+
+func (m *MemoryTransactionStore) DeleteTransaction(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, token)
+	return nil
+}
+
+func (m *MemoryTransactionStore) MarkProcessed(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.processed[key] {
+		return true, nil
+	}
+	m.processed[key] = true
+	return false, nil
+}
+
+// SetTransactionStore sets the store used to persist pending transactions
+// and the processed responses of the payment server. Without it, the last
+// processed responses are only recorded in memory: the processes of a
+// cluster, or a restarted one, can process a response again.
+func (s *Sogen) SetTransactionStore(st TransactionStore) {
+	s.transactions = st
+}
+
+// newToken returns a random URL-safe token.
+func newToken() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// CreatePaymentLink stores a transaction and returns a link to a page
+// rendering its checkout form, valid for ttl. The page is served by
+// PaymentLinkHandler(), mounted on the payment_link_url of the config.
+// The transaction gets a transaction ID if it has none (see
+// NextTransactionId()), so that the link is closed once paid.
+func (s *Sogen) CreatePaymentLink(t *Transaction, ttl time.Duration) (*url.URL, error) {
+	if t == nil {
+		return nil, errors.New("payment link: nil transaction")
+	}
+	if s.transactions == nil {
+		return nil, errors.New("payment link: no transaction store")
+	}
+	if s.config.PaymentLinkUrl == nil {
+		return nil, errors.New("payment link: missing payment_link_url in config")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("payment link: ttl must be positive")
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	if t.transId == "" {
+		if t.transId, err = s.NextTransactionId(); err != nil {
+			return nil, errors.New("payment link: " + err.Error())
+		}
+	}
+	now := s.now()
+	if err := s.transactions.SaveTransaction(&PendingTransaction{Token: token, Transaction: t,
+		Created: now, Expires: now.Add(ttl)}); err != nil {
+		return nil, err
+	}
+	u := *s.config.PaymentLinkUrl
+	u.Path = path.Join(u.Path, token)
+	return &u, nil
+}
+
+// PaymentLinkHandler returns a handler rendering the checkout form of the
+// transactions created with CreatePaymentLink(), until they are paid. It
+// must be mounted on the path of payment_link_url:
+//
+//	http.Handle(conf.PaymentLinkUrl.Path+"/", sogen.PaymentLinkHandler())
+func (s *Sogen) PaymentLinkHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.transactions == nil {
+			http.Error(w, Translate(s.pageLanguage(""), "payment links not available"), http.StatusServiceUnavailable)
+			return
+		}
+		p, err := s.transactions.Transaction(path.Base(r.URL.Path))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if s.now().After(p.Expires) {
+			s.transactions.DeleteTransaction(p.Token)
+			http.Error(w, Translate(s.pageLanguage(p.Transaction.language), "this payment link has expired"), http.StatusGone)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><body>")
+		// Checkout() updates the transaction, which is shared by the
+		// visits of the link
+		t := *p.Transaction
+		if err := s.Checkout(&t, w); err != nil {
+			lang := s.pageLanguage(p.Transaction.language)
+			fmt.Fprintf(w, "<b>%s</b> %s.", Translate(lang, "Error:"), Translate(lang, "payment is not available at the moment"))
+		}
+		fmt.Fprintf(w, "</body></html>")
+	})
+}
@@ -0,0 +1,260 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MeanKind is the kind of a payment mean. Only card means have a card
+// number, scheme and security code.
+type MeanKind string
+
+const (
+	KindCard         MeanKind = "card"
+	KindWallet       MeanKind = "wallet"        // Digital wallet, see Transaction.SetWallet()
+	KindPrivateLabel MeanKind = "private_label" // Store card, i.e. Aurore
+	KindDirectDebit  MeanKind = "direct_debit"  // i.e. SEPA direct debit
+	KindOther        MeanKind = "other"
+)
+
+// PaymentMean is a payment mean of the payment server, as listed in the
+// PAYMENT_MEANS setting and returned in Payment.PaymentMeans.
+type PaymentMean struct {
+	Code string   // Code of the payment server, i.e. VISA
+	Name string   // Display name, i.e. Visa
+	Logo string   // File name of the logo in the media files, <Code>.gif by default
+	Kind MeanKind // KindOther by default
+	// DataKeys are the DATA directives of the response specific to the
+	// mean, copied to Payment.Details (i.e. the mandate of a direct debit).
+	DataKeys []string
+}
+
+// MeanDetails are the details of a payment specific to its payment mean,
+// by DATA directive (see PaymentMean.DataKeys).
+type MeanDetails map[string]string
+
+// Payment means known by the library, by code. New means are added with
+// RegisterPaymentMean().
+var (
+	paymentMeansMu sync.RWMutex
+	paymentMeans   = map[string]PaymentMean{
+		"AMEX":       {"AMEX", "American Express", "AMEX.gif", KindCard, nil},
+		"AURORE":     {"AURORE", "Aurore", "AURORE.gif", KindPrivateLabel, nil},
+		"BOURBON":    {"BOURBON", "Bourbon", "BOURBON.gif", KindPrivateLabel, nil},
+		"CB":         {"CB", "CB", "CB.gif", KindCard, nil},
+		"CONNEXION":  {"CONNEXION", "Connexion", "CONNEXION.gif", KindPrivateLabel, nil},
+		"DELATOUR":   {"DELATOUR", "Delatour", "DELATOUR.gif", KindPrivateLabel, nil},
+		"ELV":        {"ELV", "ELV", "ELV.gif", KindDirectDebit, nil},
+		"HYPERMEDIA": {"HYPERMEDIA", "Hypermedia", "HYPERMEDIA.gif", KindPrivateLabel, nil},
+		"JCB":        {"JCB", "JCB", "JCB.gif", KindCard, nil},
+		"MASTERCARD": {"MASTERCARD", "Mastercard", "MASTERCARD.gif", KindCard, nil},
+		"NORAUTO":    {"NORAUTO", "Norauto", "NORAUTO.gif", KindPrivateLabel, nil},
+		"NOUVFRONT":  {"NOUVFRONT", "Nouvelles Frontières", "NOUVFRONT.gif", KindPrivateLabel, nil},
+		"PAYLIB":     {"PAYLIB", "Paylib", "PAYLIB.gif", KindWallet, nil},
+		"PAYPAL":     {"PAYPAL", "PayPal", "PAYPAL.gif", KindWallet, nil},
+		"PLURIEL":    {"PLURIEL", "Pluriel", "PLURIEL.gif", KindPrivateLabel, nil},
+		"SERAP":      {"SERAP", "Serap", "SERAP.gif", KindPrivateLabel, nil},
+		"TOYSRUS":    {"TOYSRUS", "Toys'R'Us", "TOYSRUS.gif", KindPrivateLabel, nil},
+		"VISA":       {"VISA", "Visa", "VISA.gif", KindCard, nil},
+	}
+)
+
+// RegisterPaymentMean adds a payment mean, or replaces the one of the
+// same code, i.e. a mean added to the platform after this release. Its
+// logo must be in the media files (see Config.MediaPath). Means can also
+// be registered by a [payment_mean "<code>"] section of the config file.
+func RegisterPaymentMean(m PaymentMean) error {
+	m.Code = strings.ToUpper(strings.TrimSpace(m.Code))
+	if m.Code == "" || strings.ContainsAny(m.Code, ",! ") {
+		return errors.New("bad payment mean code " + m.Code)
+	}
+	if m.Name == "" {
+		m.Name = m.Code
+	}
+	if m.Logo == "" {
+		m.Logo = m.Code + ".gif"
+	}
+	if m.Kind == "" {
+		m.Kind = KindOther
+	}
+	paymentMeansMu.Lock()
+	defer paymentMeansMu.Unlock()
+	paymentMeans[m.Code] = m
+	return nil
+}
+
+// LookupPaymentMean returns the payment mean of a code.
+func LookupPaymentMean(code string) (PaymentMean, bool) {
+	paymentMeansMu.RLock()
+	defer paymentMeansMu.RUnlock()
+	m, ok := paymentMeans[code]
+	return m, ok
+}
+
+// PaymentMeans returns all known payment means, sorted by code.
+func PaymentMeans() []PaymentMean {
+	paymentMeansMu.RLock()
+	defer paymentMeansMu.RUnlock()
+	list := make([]PaymentMean, 0, len(paymentMeans))
+	for _, m := range paymentMeans {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// paymentMean returns the payment mean of a code. Unknown means get
+// default metadata.
+func paymentMean(code string) PaymentMean {
+	if m, ok := LookupPaymentMean(code); ok {
+		return m
+	}
+	return PaymentMean{Code: code, Name: code, Logo: code + ".gif", Kind: KindOther}
+}
+
+// Mean returns the payment mean chosen by the customer (see
+// PaymentMeans), i.e. for its display name.
+func (p *Payment) Mean() PaymentMean {
+	return paymentMean(p.PaymentMeans)
+}
+
+// IsCard reports whether the payment was made with a card mean, as
+// opposed to i.e. a direct debit.
+func (p *Payment) IsCard() bool {
+	return p.Mean().Kind == KindCard
+}
+
+// meanDetails returns the details of the payment specific to its mean,
+// if any.
+func meanDetails(p *Payment) MeanDetails {
+	keys := p.Mean().DataKeys
+	if len(keys) == 0 {
+		return nil
+	}
+	d := make(MeanDetails)
+	for _, k := range keys {
+		if v, ok := dataDirective(p.Data, k); ok {
+			d[k] = v
+		}
+	}
+	return d
+}
+
+// paymentMeanSection returns the code of a [payment_mean "<code>"]
+// section.
+func paymentMeanSection(section string) (string, bool) {
+	if !strings.HasPrefix(section, "payment_mean ") {
+		return "", false
+	}
+	code := strings.TrimSpace(strings.TrimPrefix(section, "payment_mean "))
+	if len(code) < 2 || code[0] != '"' || code[len(code)-1] != '"' {
+		return "", false
+	}
+	return strings.TrimSpace(code[1 : len(code)-1]), true
+}
+
+// loadPaymentMeans parses the payment mean sections of a config file.
+func loadPaymentMeans(c *envConfig) ([]PaymentMean, error) {
+	list := make([]PaymentMean, 0)
+	for _, section := range c.Sections() {
+		code, ok := paymentMeanSection(section)
+		if !ok {
+			continue
+		}
+		if code == "" {
+			return nil, errors.New("payment mean section without code")
+		}
+		m := PaymentMean{Code: code}
+		var kind, keys string
+		for name, v := range map[string]*string{
+			"name":      &m.Name,
+			"logo":      &m.Logo,
+			"kind":      &kind,
+			"data_keys": &keys,
+		} {
+			if s, err := c.String(section, name); err == nil {
+				*v = strings.TrimSpace(s)
+			}
+		}
+		switch m.Kind = MeanKind(kind); m.Kind {
+		case "", KindCard, KindWallet, KindPrivateLabel, KindDirectDebit, KindOther:
+		default:
+			return nil, errors.New("payment mean " + code + ": unknown kind " + kind)
+		}
+		for _, k := range strings.Split(keys, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				m.DataKeys = append(m.DataKeys, k)
+			}
+		}
+		list = append(list, m)
+	}
+	return list, nil
+}
+
+// registerMeans registers the payment means of the config file.
+func (c *Config) registerMeans() error {
+	for _, m := range c.CustomMeans {
+		if err := RegisterPaymentMean(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetPaymentMeans sets the payment means offered for the transaction, in
+// the PAYMENT_MEANS format (i.e. AMEX,2), instead of the ones of the
+// config. The means must be enabled on the merchant's contract.
+func (t *Transaction) SetPaymentMeans(means string) error {
+	fields := strings.Split(means, ",")
+	if len(fields)%2 != 0 {
+		return errors.New("payment means: expected <code>,<block> pairs")
+	}
+	for k := 0; k < len(fields); k += 2 {
+		code := strings.TrimSpace(fields[k])
+		if _, ok := LookupPaymentMean(code); !ok {
+			return errors.New("unknown payment mean " + code)
+		}
+		if _, err := strconv.Atoi(strings.TrimSpace(fields[k+1])); err != nil {
+			return errors.New("payment means: bad block of " + code)
+		}
+	}
+	t.means = strings.Replace(means, " ", "", -1)
+	return nil
+}
+
+// PaymentMeans returns the payment means set with SetPaymentMeans(), if
+// any.
+func (t *Transaction) PaymentMeans() string {
+	return t.means
+}
+
+// configMeans returns the codes of the payment means of the PAYMENT_MEANS
+// setting, i.e CB,2,VISA,2 (each mean is followed by its display block
+// number).
+func (c *Config) configMeans() []string {
+	codes := make([]string, 0)
+	means := strings.Split(c.PaymentMeans, ",")
+	for k := 0; k < len(means); k += 2 {
+		if code := strings.TrimSpace(means[k]); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// checkPaymentMeans logs a warning for each payment mean of the config
+// which is not registered.
+func checkPaymentMeans(c *Config) {
+	for _, code := range c.configMeans() {
+		if _, ok := LookupPaymentMean(code); !ok {
+			c.logf(LogWarning, "Warning: unknown payment mean %s, see RegisterPaymentMean()", code)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+)
+
+// PaymentStatus tells whether a transaction was actually paid, from the
+// payment store.
+type PaymentStatus struct {
+	TransactionId string
+	Payment       *Payment
+	Paid          bool  // Accepted by the payment server
+	Captured      int64 // Captured amount, in cents
+	Refunded      int64 // Refunded amount, in cents
+}
+
+// PaymentStatus answers "was this order actually paid?" for a transaction
+// ID. The payment, captures and refunds are looked up in the payment
+// store. The bank is not asked: operations made from its back office are
+// not known.
+func (s *Sogen) PaymentStatus(transactionId string) (*PaymentStatus, error) {
+	if transactionId == "" {
+		return nil, errors.New("payment status: missing transaction ID")
+	}
+	if s.payments == nil {
+		return nil, errors.New("payment status: no payment store")
+	}
+	p, err := s.payments.Payment(transactionId)
+	if err != nil {
+		return nil, errors.New("payment status: " + err.Error())
+	}
+	st := &PaymentStatus{TransactionId: transactionId, Payment: p, Paid: p.ResponseCode == "00"}
+	if err := s.paymentOperations(st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// paymentOperations sums the captures and refunds of the stored payment.
+func (s *Sogen) paymentOperations(st *PaymentStatus) error {
+	captures, err := s.payments.Captures(st.TransactionId)
+	if err != nil {
+		return err
+	}
+	for _, c := range captures {
+		if c.PaymentDate.Equal(st.Payment.PaymentDate) {
+			st.Captured += c.Amount
+		}
+	}
+	refunds, err := s.payments.Refunds(st.TransactionId)
+	if err != nil {
+		return err
+	}
+	for _, r := range refunds {
+		if r.PaymentDate.Equal(st.Payment.PaymentDate) {
+			st.Refunded += r.Amount
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// platformFallbacks lists the platforms whose binaries can also run on a
+// given platform, in order of preference.
+var platformFallbacks = map[string][]string{
+	"linux_amd64": {"linux_386"},
+}
+
+// resolvePlatform returns the platform directory of the library holding
+// both the request and response binaries. The library_platform setting is
+// used if set; otherwise the running platform is tried first, then its
+// fallbacks.
+func resolvePlatform(c *Config) (string, error) {
+	candidates := []string{c.LibraryPlatform}
+	if c.LibraryPlatform == "" {
+		p := runtime.GOOS + "_" + runtime.GOARCH
+		candidates = append([]string{p}, platformFallbacks[p]...)
+	}
+	tried := make([]string, 0)
+	for _, p := range candidates {
+		found := true
+		for _, name := range []string{"request", "response"} {
+			file := filepath.Join(c.LibraryPath, p, name)
+			if _, err := os.Stat(file); err != nil {
+				tried = append(tried, file)
+				found = false
+			}
+		}
+		if found {
+			return p, nil
+		}
+	}
+	if c.LibraryPlatform == "" {
+		if err := checkPlatform(c.LibraryPath); err != nil {
+			return "", err
+		}
+	}
+	return "", &ErrMissingBinary{tried[0], tried}
+}
+
+// checkPlatform returns an *ErrUnsupportedPlatform if the library has no
+// binaries at all for the running OS.
+func checkPlatform(libpath string) error {
+	dirs, err := ioutil.ReadDir(libpath)
+	if err != nil {
+		return nil
+	}
+	available := make([]string, 0)
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(d.Name(), runtime.GOOS+"_") {
+			return nil
+		}
+		available = append(available, d.Name())
+	}
+	if len(available) == 0 {
+		return nil
+	}
+	return &ErrUnsupportedPlatform{runtime.GOOS + "_" + runtime.GOARCH, available}
+}
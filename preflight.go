@@ -0,0 +1,84 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Timeout of the URL checks made by NewSogen() with CheckUrls set.
+const checkUrlsTimeout = 5 * time.Second
+
+// URLCheck is the outcome of the check of a URL of the config.
+type URLCheck struct {
+	Name     string // Option of the URL, i.e. return_url
+	URL      *url.URL
+	Status   string // HTTP status of the response, if reachable
+	Err      error  // Why the URL is unreachable, if it is
+	Insecure bool   // The URL is not served over HTTPS
+}
+
+// CheckURLs sends a HEAD request to the cancel, return and auto response
+// URLs of the config. Any HTTP response will do: the payment server and
+// the buyers only need to connect. URLs relative to the server are
+// resolved with the base URL of the config, if any, and skipped otherwise,
+// like the ones not set. A nil client uses http.DefaultClient.
+func CheckURLs(ctx context.Context, c *Config, client *http.Client) []URLCheck {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	urls := []struct {
+		name string
+		u    *url.URL
+	}{
+		{"cancel_url", c.CancelUrl},
+		{"return_url", c.ReturnUrl},
+		{"auto_response_url", c.AutoResponseUrl},
+	}
+	checks := make([]URLCheck, 0)
+	for _, cu := range urls {
+		if cu.u != nil && !cu.u.IsAbs() && c.BaseUrl != nil {
+			cu.u = c.BaseUrl.ResolveReference(cu.u)
+		}
+		if cu.u == nil || !cu.u.IsAbs() {
+			continue
+		}
+		check := URLCheck{Name: cu.name, URL: cu.u, Insecure: cu.u.Scheme != "https"}
+		req, err := http.NewRequestWithContext(ctx, "HEAD", cu.u.String(), nil)
+		if err == nil {
+			var resp *http.Response
+			if resp, err = client.Do(req); err == nil {
+				resp.Body.Close()
+				check.Status = resp.Status
+			}
+		}
+		check.Err = err
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkUrls logs a warning for each URL of the config which is
+// unreachable or not served over HTTPS.
+func checkUrls(c *Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkUrlsTimeout)
+	defer cancel()
+	for _, check := range CheckURLs(ctx, c, nil) {
+		if check.Err != nil {
+			var uerr *url.Error
+			if errors.As(check.Err, &uerr) {
+				check.Err = uerr.Err
+			}
+			c.logf(LogWarning, "Warning: %s %s unreachable: %s", check.Name, check.URL.Redacted(), check.Err.Error())
+		}
+		if check.Insecure {
+			c.logf(LogWarning, "Warning: %s %s not served over HTTPS", check.Name, check.URL.Redacted())
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ClientIP returns the address of the client of a request. Behind the
+// trusted proxies of the config, it is the rightmost X-Forwarded-For
+// address which is not a trusted proxy.
+func (s *Sogen) ClientIP(r *http.Request) net.IP {
+	return clientIP(r, s.proxies)
+}
+
+// forwarded returns the first value of a X-Forwarded-* header.
+func forwarded(r *http.Request, name string) string {
+	return strings.TrimSpace(strings.Split(r.Header.Get(name), ",")[0])
+}
+
+// BaseURL returns the URL of the server as seen by the client of a
+// request, i.e. https://shop.example.com: the base_url of the config, if
+// set. Otherwise, requests coming from a trusted proxy honor the
+// X-Forwarded-Proto and X-Forwarded-Host headers. The Host header of
+// other requests is not trusted: nil is returned.
+func (s *Sogen) BaseURL(r *http.Request) *url.URL {
+	if s.config.BaseUrl != nil {
+		u := *s.config.BaseUrl
+		return &u
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip == nil || !contains(s.proxies, ip) {
+		return nil
+	}
+	u := &url.URL{Scheme: "http", Host: r.Host}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if proto := forwarded(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		u.Scheme = proto
+	}
+	if h := forwarded(r, "X-Forwarded-Host"); h != "" {
+		u.Host = h
+	}
+	return u
+}
+
+// ResolveURLs sets the cancel, return and auto response URLs of a
+// transaction from the URLs of the config relative to the server (i.e.
+// return_url=/sogen/return), using the base URL of the request (see
+// BaseURL()). Absolute URLs and the ones set on the transaction or its
+// customer are left untouched. An error is returned if a URL has to be
+// resolved without a base URL.
+func (s *Sogen) ResolveURLs(t *Transaction, r *http.Request) error {
+	if t == nil {
+		return nil
+	}
+	var base *url.URL
+	resolve := func(dst **url.URL, cu, u *url.URL) error {
+		if *dst != nil || cu != nil || u == nil || u.IsAbs() {
+			return nil
+		}
+		if base == nil {
+			if base = s.BaseURL(r); base == nil {
+				return errors.New("can't resolve " + u.String() + ": no base_url in the config")
+			}
+		}
+		*dst = base.ResolveReference(u)
+		return nil
+	}
+	if err := resolve(&t.cancelUrl, t.customer.CancelUrl, s.config.CancelUrl); err != nil {
+		return err
+	}
+	if err := resolve(&t.returnUrl, t.customer.ReturnUrl, s.config.ReturnUrl); err != nil {
+		return err
+	}
+	return resolve(&t.autoUrl, t.customer.AutomaticUrl, s.config.AutoResponseUrl)
+}
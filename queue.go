@@ -0,0 +1,205 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Defaults of the retries of a Queue.
+const (
+	DefaultQueueRetries = 5
+	DefaultQueueBackoff = time.Second
+)
+
+// Errors of Queue.Enqueue().
+var (
+	ErrQueueFull   = errors.New("queue full")
+	ErrQueueClosed = errors.New("queue closed")
+)
+
+// Queue runs the post-processing of payments (fulfillment etc.) in the
+// background, so that the auto response is acknowledged before the bank
+// times out and retries:
+//
+//	q := sogenactif.NewQueue(fulfill, 4, 1000)
+//	http.Handle(conf.AutoResponseUrl.Path, sogen.AutoResponseHandler(q.Enqueue))
+//
+// The payment is saved in the payment store, if any, before being queued.
+// A failed job is retried with an exponential backoff.
+//
+// The auto response is acknowledged once the payment is queued, after which
+// the bank does not send it again: without a Store, the jobs queued when
+// the process stops are lost, and fulfillment must be reconciled from the
+// payment store.
+type Queue struct {
+	fn   PaymentFunc
+	jobs chan *Payment
+	wg   sync.WaitGroup
+	// Retries is the number of attempts after the first failure of a job,
+	// DefaultQueueRetries by default.
+	Retries int
+	// Backoff is the delay before the first retry, doubled on each
+	// attempt, DefaultQueueBackoff by default.
+	Backoff time.Duration
+	// OnDeadLetter, if not nil, is called with the payments whose job
+	// failed on every attempt, along with the last error. They are logged
+	// with Logger otherwise.
+	OnDeadLetter func(p *Payment, err error)
+	// Logger receives the failed jobs, i.e. Config.Logger. Defaults to the
+	// standard logger.
+	Logger *log.Logger
+	// Store, if not nil, persists the jobs until they are processed or
+	// dead lettered. Jobs left by a previous process are queued again by
+	// Resume().
+	Store  JobStore
+	mu     sync.RWMutex
+	closed bool
+}
+
+// JobStore persists the jobs of a Queue, so that the payments acknowledged
+// to the bank are processed even if the process stops. Jobs are identified
+// by the transaction ID and payment date of their payment.
+type JobStore interface {
+	// SaveJob records the job of a payment.
+	SaveJob(p *Payment) error
+	// DeleteJob removes the job of a payment.
+	DeleteJob(p *Payment) error
+	// Jobs returns the payments whose job is not done.
+	Jobs() ([]*Payment, error)
+}
+
+// NewQueue returns a queue of up to size payments processed by fn with
+// workers goroutines.
+func NewQueue(fn PaymentFunc, workers, size int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &Queue{
+		fn:      fn,
+		jobs:    make(chan *Payment, size),
+		Retries: DefaultQueueRetries,
+		Backoff: DefaultQueueBackoff,
+	}
+	q.wg.Add(workers)
+	for k := 0; k < workers; k++ {
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue queues the processing of a payment, saving its job in the store
+// of the queue first, if any. It fails without waiting if the queue is
+// full.
+func (q *Queue) Enqueue(p *Payment) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if q.Store != nil {
+		if err := q.Store.SaveJob(p); err != nil {
+			return err
+		}
+	}
+	select {
+	case q.jobs <- p:
+		return nil
+	default:
+		// Not acknowledged: the bank sends the payment again
+		q.deleteJob(p)
+		return ErrQueueFull
+	}
+}
+
+// Resume queues the jobs of the store of the queue, i.e. those left by a
+// previous process, waiting for room in the queue. It is called once at
+// startup, before serving the auto responses.
+func (q *Queue) Resume() error {
+	if q.Store == nil {
+		return nil
+	}
+	jobs, err := q.Store.Jobs()
+	if err != nil {
+		return err
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	for _, p := range jobs {
+		q.jobs <- p
+	}
+	return nil
+}
+
+// Len returns the number of payments waiting to be processed.
+func (q *Queue) Len() int {
+	return len(q.jobs)
+}
+
+// Close stops accepting payments and waits for the queued ones to be
+// processed.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for p := range q.jobs {
+		q.process(p)
+	}
+}
+
+// process runs the job of a payment until it succeeds or the retries are
+// exhausted.
+func (q *Queue) process(p *Payment) {
+	backoff := q.Backoff
+	err := q.fn(p)
+	for k := 0; err != nil && k < q.Retries; k++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = q.fn(p)
+	}
+	q.deleteJob(p)
+	if err == nil {
+		return
+	}
+	if q.OnDeadLetter != nil {
+		q.OnDeadLetter(p, err)
+		return
+	}
+	q.logf("queue: transaction %s failed after %d attempts: %s", p.TransactionId, q.Retries+1, err.Error())
+}
+
+// deleteJob removes the job of a payment from the store of the queue, if
+// any.
+func (q *Queue) deleteJob(p *Payment) {
+	if q.Store == nil {
+		return
+	}
+	if err := q.Store.DeleteJob(p); err != nil {
+		q.logf("queue: can't delete the job of transaction %s: %s", p.TransactionId, err.Error())
+	}
+}
+
+// logf logs a message with the logger of the queue.
+func (q *Queue) logf(format string, v ...interface{}) {
+	if q.Logger != nil {
+		q.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
@@ -0,0 +1,133 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Idle buckets are dropped periodically, and once this many buckets are
+// held, then the least recently used tenth if none is idle.
+const maxBuckets = 10000
+
+// RateLimiter limits the rate of requests per key (client IP, customer ID)
+// with token buckets.
+type RateLimiter struct {
+	rate  float64 // Tokens per second
+	burst float64
+	// OnLimit, if not nil, is called with the key of every rejected
+	// request, i.e. to report or block offenders.
+	OnLimit func(key string, r *http.Request)
+	// Clock is the source of the current time. Defaults to the clock of
+	// the config of the Sogen the limiter is set on (see
+	// Sogen.SetRateLimiter()), else to the system time.
+	Clock   Clock
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a rate limiter allowing rate requests per second
+// and per key, with bursts of up to burst requests.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket)}
+}
+
+// Allow reports whether a request for key is allowed now, consuming a
+// token if so.
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.Clock != nil {
+		now = l.Clock.Now()
+	}
+	if len(l.buckets) >= maxBuckets || now.Sub(l.swept) >= sweepInterval {
+		l.prune(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune drops the buckets which would be full by now. If there are none,
+// the least recently used buckets are dropped to make room for new ones.
+func (l *RateLimiter) prune(now time.Time) {
+	for k, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+	l.swept = now
+	if len(l.buckets) < maxBuckets {
+		return
+	}
+	keys := make([]string, 0, len(l.buckets))
+	for k := range l.buckets {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return l.buckets[keys[i]].last.Before(l.buckets[keys[j]].last) })
+	for _, k := range keys[:maxBuckets/10] {
+		delete(l.buckets, k)
+	}
+}
+
+// SetRateLimiter sets the rate limiter of the checkout, return and cancel
+// handlers (see CheckoutHandler() etc.). Requests are limited per client
+// IP and, on checkout, per customer ID. Rejected requests get a 429 Too
+// Many Requests status. The payment server, whose addresses are the
+// auto_response_ips of the config, is not limited.
+func (s *Sogen) SetRateLimiter(l *RateLimiter) {
+	if l != nil && l.Clock == nil {
+		l.Clock = s.config.Clock
+	}
+	s.limiter = l
+}
+
+// allow checks the rate limit of key, if any. It writes the error
+// response and returns false if the request is rejected.
+func (s *Sogen) allow(w http.ResponseWriter, r *http.Request, key string) bool {
+	if s.limiter == nil || s.limiter.Allow(key) {
+		return true
+	}
+	if s.limiter.OnLimit != nil {
+		s.limiter.OnLimit(key, r)
+	}
+	http.Error(w, "too many requests", http.StatusTooManyRequests)
+	return false
+}
+
+// allowClient checks the rate limit of the client IP of a request, unless
+// it is the payment server.
+func (s *Sogen) allowClient(w http.ResponseWriter, r *http.Request) bool {
+	ip := clientIP(r, s.proxies)
+	if s.autoResponseIPs != nil && s.autoResponseIPs.Allowed(ip) {
+		return true
+	}
+	return s.allow(w, r, "ip:"+ip.String())
+}
@@ -0,0 +1,135 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// PendingTransactionLister is implemented by the transaction stores able
+// to list their pending transactions, as required by ReapAbandoned().
+type PendingTransactionLister interface {
+	PendingTransactions() ([]*PendingTransaction, error)
+}
+
+func (m *MemoryTransactionStore) PendingTransactions() ([]*PendingTransaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]*PendingTransaction, 0, len(m.pending))
+	for _, p := range m.pending {
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+type abandonedHook func(p *PendingTransaction)
+
+// OnAbandoned registers a function called with every pending transaction
+// removed by ReapAbandoned() without a response of the payment server,
+// i.e. to release the cart and unreserve the stock.
+func (s *Sogen) OnAbandoned(fn func(p *PendingTransaction)) {
+	s.onAbandoned = append(s.onAbandoned, fn)
+}
+
+// completeTransaction removes the pending transactions of an accepted
+// payment, so that their payment link can't be paid twice, and flags those
+// of a declined one as Responded, so that ReapAbandoned() doesn't report
+// them. The transaction store must implement PendingTransactionLister.
+func (s *Sogen) completeTransaction(p *Payment) error {
+	if s.transactions == nil || p.TransactionId == "" {
+		return nil
+	}
+	l, ok := s.transactions.(PendingTransactionLister)
+	if !ok {
+		return nil
+	}
+	pending, err := l.PendingTransactions()
+	if err != nil {
+		return err
+	}
+	for _, pt := range pending {
+		if pt.Transaction == nil || pt.Transaction.transId != p.TransactionId {
+			continue
+		}
+		if p.ResponseCode == "00" {
+			if err := s.transactions.DeleteTransaction(pt.Token); err != nil {
+				return err
+			}
+		} else if !pt.Responded {
+			responded := *pt
+			responded.Responded = true
+			if err := s.transactions.SaveTransaction(&responded); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReapAbandoned removes the pending transactions created more than ttl
+// before now, or expired if they expire later (see CreatePaymentLink()).
+// The OnAbandoned hooks are called with the ones which didn't get a
+// response of the payment server. Responses are matched by transaction ID
+// (see SetTransactionId()): transactions without one are removed without
+// being reported, since they may have been paid. It returns the number of
+// abandoned transactions. The transaction store must implement
+// PendingTransactionLister.
+func (s *Sogen) ReapAbandoned(now time.Time, ttl time.Duration) (int, error) {
+	if s.transactions == nil {
+		return 0, errors.New("reaper: no transaction store")
+	}
+	l, ok := s.transactions.(PendingTransactionLister)
+	if !ok {
+		return 0, errors.New("reaper: transaction store can't list pending transactions")
+	}
+	pending, err := l.PendingTransactions()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range pending {
+		deadline := p.Created.Add(ttl)
+		if p.Expires.After(deadline) {
+			deadline = p.Expires
+		}
+		if now.Before(deadline) {
+			continue
+		}
+		if err := s.transactions.DeleteTransaction(p.Token); err != nil {
+			return n, err
+		}
+		if p.Responded || p.Transaction == nil || p.Transaction.TransactionId() == "" {
+			continue
+		}
+		for _, fn := range s.onAbandoned {
+			fn(p)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ReaperLoop removes the abandoned pending transactions every interval
+// until ctx is done (see ReapAbandoned()). Run it in its own goroutine:
+//
+//	go sogen.ReaperLoop(ctx, time.Minute, time.Hour)
+func (s *Sogen) ReaperLoop(ctx context.Context, interval, ttl time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		if n, err := s.ReapAbandoned(s.now(), ttl); err != nil {
+			s.config.logf(LogError, "reaper: %s", err.Error())
+		} else if n > 0 {
+			s.config.logf(LogInfo, "reaper: %d abandoned transactions", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package receipt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/gotsunami/sogenactif"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Handler serves receipts at signed URLs, so that customers can get their
+// receipt without an account.
+type Handler struct {
+	// Clock is the source of the current time against which links
+	// expire, i.e. the Sogen of the payments. Defaults to the system time.
+	Clock    sogenactif.Clock
+	store    sogenactif.PaymentStore
+	merchant *Merchant
+	key      []byte
+}
+
+// NewHandler returns a handler serving the receipts of the payments of
+// store. key signs the receipt URLs.
+func NewHandler(store sogenactif.PaymentStore, m *Merchant, key []byte) *Handler {
+	return &Handler{store: store, merchant: m, key: key}
+}
+
+func (h *Handler) sign(tid, date, expires string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(tid + "|" + date + "|" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// URL returns the signed URL of the receipt of a payment, valid until
+// expires. base is the URL the handler is served at.
+func (h *Handler) URL(base *url.URL, p *sogenactif.Payment, expires time.Time) (*url.URL, error) {
+	if base == nil || p == nil {
+		return nil, errors.New("nil base URL or payment")
+	}
+	date := p.PaymentDate.Format("20060102")
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	u := *base
+	q := u.Query()
+	q.Set("tid", p.TransactionId)
+	q.Set("date", date)
+	q.Set("exp", exp)
+	q.Set("sig", h.sign(p.TransactionId, date, exp))
+	u.RawQuery = q.Encode()
+	return &u, nil
+}
+
+// now returns the current time of the clock of the handler.
+func (h *Handler) now() time.Time {
+	if h.Clock != nil {
+		return h.Clock.Now()
+	}
+	return time.Now()
+}
+
+// ServeHTTP serves a receipt as HTML, or as PDF with format=pdf. The lang
+// parameter sets the language of the receipt.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tid, date, exp := q.Get("tid"), q.Get("date"), q.Get("exp")
+	if !hmac.Equal([]byte(q.Get("sig")), []byte(h.sign(tid, date, exp))) {
+		http.Error(w, "bad signature", http.StatusForbidden)
+		return
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || h.now().Unix() > expires {
+		http.Error(w, "link expired", http.StatusGone)
+		return
+	}
+	p, err := h.store.Payment(tid)
+	if err != nil || p.PaymentDate.Format("20060102") != date {
+		http.NotFound(w, r)
+		return
+	}
+	rec, err := New(h.merchant, p, q.Get("lang"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if q.Get("format") == "pdf" {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", "inline; filename=receipt-"+date+"-"+tid+".pdf")
+		err = rec.PDF(w)
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = rec.HTML(w)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Page layout, in points (A4).
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 56
+)
+
+// pdf is a minimal PDF writer: a single A4 page of Helvetica text lines,
+// enough for a receipt.
+type pdf struct {
+	content bytes.Buffer
+	y       float64 // Baseline of the next line
+}
+
+func newPDF() *pdf {
+	return &pdf{y: pageHeight - margin}
+}
+
+// escape encodes s in WinAnsi (Latin-1) and escapes PDF string delimiters.
+func escape(s string) []byte {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r < 256:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.Bytes()
+}
+
+// text adds a line of text.
+func (p *pdf) text(size float64, s string) {
+	p.y -= size * 1.4
+	fmt.Fprintf(&p.content, "BT /F1 %g Tf %d %g Td (", size, margin, p.y)
+	p.content.Write(escape(s))
+	p.content.WriteString(") Tj ET\n")
+}
+
+// skip adds a blank line.
+func (p *pdf) skip() {
+	p.y -= 12
+}
+
+// WriteTo writes the document.
+func (p *pdf) WriteTo(w io.Writer) (int64, error) {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents 4 0 R "+
+			"/Resources << /Font << /F1 5 0 R >> >> >>", pageWidth, pageHeight),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for k, o := range objects {
+		offsets[k] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", k+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.WriteTo(w)
+}
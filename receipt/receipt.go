@@ -0,0 +1,160 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package receipt renders proof of payment receipts for accepted payments,
+// as HTML or PDF, and serves them at signed URLs:
+//
+//	m := &receipt.Merchant{Name: "Funky Shop", Address: "1 rue de la Paix, Paris"}
+//	h := receipt.NewHandler(store, m, key)
+//	http.Handle("/receipt", h)
+//	u, err := h.URL(base, payment, time.Now().Add(30*24*time.Hour))
+package receipt
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// Merchant holds the merchant information printed on receipts.
+type Merchant struct {
+	Name    string
+	Address string
+	Siret   string // Registration number, if any
+	Url     string
+}
+
+// Receipt is the proof of an accepted payment.
+type Receipt struct {
+	Merchant *Merchant
+	Payment  *sogenactif.Payment
+	Language string // Language of the labels (fr or en), defaults to fr
+}
+
+// Labels of a receipt, per language.
+var labels = map[string]map[string]string{
+	"fr": {
+		"title":       "Ticket de paiement",
+		"date":        "Date",
+		"transaction": "Transaction",
+		"card":        "Carte",
+		"amount":      "Montant",
+		"auth":        "N° d'autorisation",
+		"certificate": "Certificat",
+		"keep":        "Ticket à conserver.",
+	},
+	"en": {
+		"title":       "Payment receipt",
+		"date":        "Date",
+		"transaction": "Transaction",
+		"card":        "Card",
+		"amount":      "Amount",
+		"auth":        "Authorization ID",
+		"certificate": "Certificate",
+		"keep":        "Please keep this receipt.",
+	},
+}
+
+// New returns the receipt of an accepted payment.
+func New(m *Merchant, p *sogenactif.Payment, lang string) (*Receipt, error) {
+	if m == nil || p == nil {
+		return nil, errors.New("nil merchant or payment")
+	}
+	if p.ResponseCode != "00" {
+		return nil, errors.New(fmt.Sprintf("payment %s was not accepted", p.TransactionId))
+	}
+	if _, ok := labels[lang]; !ok {
+		lang = "fr"
+	}
+	return &Receipt{Merchant: m, Payment: p, Language: lang}, nil
+}
+
+// Line is a labelled line of a receipt.
+type Line struct {
+	Label, Value string
+}
+
+// MaskedCard returns the card number with hidden digits (see
+// Payment.MaskedCard()).
+func (r *Receipt) MaskedCard() string {
+	return r.Payment.MaskedCard()
+}
+
+// Amount returns the formatted amount, with the currency.
+func (r *Receipt) Amount() string {
+	return r.Payment.FormatAmount(r.Language)
+}
+
+// Title returns the localized title of the receipt.
+func (r *Receipt) Title() string {
+	return labels[r.Language]["title"]
+}
+
+// Footer returns the localized footer of the receipt.
+func (r *Receipt) Footer() string {
+	return labels[r.Language]["keep"]
+}
+
+// Lines returns the payment details of the receipt.
+func (r *Receipt) Lines() []Line {
+	l := labels[r.Language]
+	date := r.Payment.PaymentDate.Format("02/01/2006 15:04:05")
+	if r.Language == "en" {
+		date = r.Payment.PaymentDate.Format("2006-01-02 15:04:05")
+	}
+	return []Line{
+		{l["date"], date},
+		{l["transaction"], r.Payment.TransactionId},
+		{l["card"], strings.TrimSpace(r.Payment.Mean().Name + " " + r.MaskedCard())},
+		{l["amount"], r.Amount()},
+		{l["auth"], r.Payment.AuthorizationId},
+		{l["certificate"], r.Payment.PaymentCertificate},
+	}
+}
+
+// Template is the template used to render HTML receipts. It is executed
+// with a *Receipt.
+var Template = template.Must(template.New("receipt").Parse(`<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h2>{{.Merchant.Name}}</h2>
+<p>{{.Merchant.Address}}{{if .Merchant.Siret}}<br>SIRET {{.Merchant.Siret}}{{end}}{{if .Merchant.Url}}<br>{{.Merchant.Url}}{{end}}</p>
+<h3>{{.Title}}</h3>
+<table>
+{{range .Lines}}<tr><td>{{.Label}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+<p>{{.Footer}}</p>
+</body></html>`))
+
+// HTML renders the receipt as HTML.
+func (r *Receipt) HTML(w io.Writer) error {
+	return Template.Execute(w, r)
+}
+
+// PDF renders the receipt as a single page PDF document.
+func (r *Receipt) PDF(w io.Writer) error {
+	doc := newPDF()
+	doc.text(18, r.Merchant.Name)
+	for _, l := range strings.Split(r.Merchant.Address, "\n") {
+		doc.text(10, l)
+	}
+	if r.Merchant.Siret != "" {
+		doc.text(10, "SIRET "+r.Merchant.Siret)
+	}
+	if r.Merchant.Url != "" {
+		doc.text(10, r.Merchant.Url)
+	}
+	doc.skip()
+	doc.text(14, r.Title())
+	for _, l := range r.Lines() {
+		doc.text(11, l.Label+" : "+l.Value)
+	}
+	doc.skip()
+	doc.text(10, r.Footer())
+	_, err := doc.WriteTo(w)
+	return err
+}
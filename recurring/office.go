@@ -0,0 +1,53 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recurring
+
+import (
+	"context"
+	"errors"
+	"github.com/gotsunami/sogenactif"
+	"github.com/gotsunami/sogenactif/office"
+	"math"
+)
+
+// OfficeCharger charges subscriptions by duplicating their initial
+// transaction with the office interface, which reuses its card details
+// (see office.Client.Duplicate()). Like the office package, it is
+// experimental.
+type OfficeCharger struct {
+	Client       *office.Client
+	CurrencyCode string // ISO 4217 numeric code of the charges
+	// NewId returns the transaction ID of a charge, i.e.
+	// Sogen.NextTransactionId.
+	NewId func() (string, error)
+}
+
+func (c *OfficeCharger) Charge(s *Subscription) (*sogenactif.Payment, error) {
+	if c.Client == nil || c.NewId == nil {
+		return nil, errors.New("office charger needs a client and a transaction ID source")
+	}
+	id, err := c.NewId()
+	if err != nil {
+		return nil, err
+	}
+	// Smallest unit of the currency
+	amount := int64(math.Floor(s.Amount*100 + 0.5))
+	res, err := c.Client.Duplicate(context.Background(), s.TransactionId, s.TransactionDate, id, amount, c.CurrencyCode)
+	if err != nil {
+		return nil, err
+	}
+	return &sogenactif.Payment{
+		MerchantId:      c.Client.MerchantId,
+		MerchantCountry: c.Client.MerchantCountry,
+		Amount:          float64(amount) / 100,
+		TransactionId:   id,
+		PaymentDate:     res.TransactionDate,
+		ResponseCode:    res.ResponseCode,
+		AuthorizationId: res.AuthorisationId,
+		CurrencyCode:    c.CurrencyCode,
+		CustomerId:      s.CustomerId,
+		CardAlias:       s.Reference,
+	}, nil
+}
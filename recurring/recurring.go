@@ -0,0 +1,344 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package recurring provides support for subscriptions and recurring
+// payments on top of the Sogenactif platform.
+//
+// A subscription is created from an initial (accepted) payment, then saved
+// to a Store:
+//
+//	sub, err := recurring.NewSubscription("plan-42", p, 9.99, recurring.Period{Months: 1})
+//	err = store.Save(sub)
+//
+// A Scheduler periodically looks for due subscriptions and charges them using
+// a Charger, which performs the actual subsequent payment (office interface,
+// repeated authorizations etc.). OfficeCharger duplicates the initial
+// transaction with the office interface:
+//
+//	charger := &recurring.OfficeCharger{Client: client, CurrencyCode: "978", NewId: sogen.NextTransactionId}
+//	sched := recurring.NewScheduler(store, charger)
+//	sched.OnSuccess = func(s *recurring.Subscription, p *sogenactif.Payment) { ... }
+//	sched.OnFailure = func(s *recurring.Subscription, err error) { ... }
+//	sched.Start()
+//	defer sched.Stop()
+package recurring
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"log"
+	"sync"
+	"time"
+)
+
+// Response code of an accepted authorization.
+const codeAccepted = "00"
+
+// Period is the time between two charges of a subscription.
+type Period struct {
+	Months int
+	Days   int
+}
+
+// next returns the date of the charge following t.
+func (p Period) next(t time.Time) time.Time {
+	return t.AddDate(0, p.Months, p.Days)
+}
+
+// Subscription holds what is needed to charge a customer again after an
+// initial payment.
+type Subscription struct {
+	Id              string    // Unique subscription identifier
+	CustomerId      string    // Customer ID of the initial payment
+	Reference       string    // Card alias or subscription reference used for subsequent charges
+	TransactionId   string    // Transaction ID of the initial payment
+	TransactionDate time.Time // Transmission date of the initial payment
+	Amount          float64   // Amount of each subsequent charge
+	Period          Period    // Time between two charges
+	NextCharge      time.Time // Date of the next charge, or of the next retry of a failed one
+	Due             time.Time // Date the charge being retried was due, if any
+	Failures        int       // Consecutive failed charges
+	Active          bool
+}
+
+// NewSubscription creates an active subscription from an initial payment. The
+// first subsequent charge is scheduled one period after the payment date.
+// The card alias of the payment is the reference of the subscription: the
+// initial payment must have created one (see Transaction.CreateAlias()).
+func NewSubscription(id string, p *sogenactif.Payment, amount float64, period Period) (*Subscription, error) {
+	if id == "" {
+		return nil, errors.New("missing subscription ID")
+	}
+	if p == nil {
+		return nil, errors.New("nil initial payment")
+	}
+	if p.ResponseCode != codeAccepted {
+		return nil, errors.New(fmt.Sprintf("initial payment not accepted (response code %s)", p.ResponseCode))
+	}
+	if p.CardAlias == "" {
+		return nil, errors.New("initial payment without card alias")
+	}
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	if period.Months < 0 || period.Days < 0 || period.Months+period.Days == 0 {
+		return nil, errors.New("bad subscription period")
+	}
+	return &Subscription{
+		Id:              id,
+		CustomerId:      p.CustomerId,
+		Reference:       p.CardAlias,
+		TransactionId:   p.TransactionId,
+		TransactionDate: p.TransmissionDate,
+		Amount:          amount,
+		Period:          period,
+		NextCharge:      period.next(p.PaymentDate),
+		Active:          true,
+	}, nil
+}
+
+// Store persists subscriptions.
+type Store interface {
+	// Save creates or updates a subscription.
+	Save(s *Subscription) error
+	// Get returns the subscription matching id.
+	Get(id string) (*Subscription, error)
+	// Delete removes a subscription.
+	Delete(id string) error
+	// Due returns all active subscriptions that must be charged at t.
+	Due(t time.Time) ([]*Subscription, error)
+}
+
+// MemoryStore is an in-memory Store, mostly useful for testing.
+type MemoryStore struct {
+	mu   sync.Mutex
+	subs map[string]*Subscription
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{subs: make(map[string]*Subscription)}
+}
+
+func (m *MemoryStore) Save(s *Subscription) error {
+	if s == nil {
+		return errors.New("nil subscription")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *s
+	m.subs[s.Id] = &c
+	return nil
+}
+
+func (m *MemoryStore) Get(id string) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.subs[id]
+	if !ok {
+		return nil, errors.New("no subscription with ID " + id)
+	}
+	c := *s
+	return &c, nil
+}
+
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.subs, id)
+	return nil
+}
+
+func (m *MemoryStore) Due(t time.Time) ([]*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	due := make([]*Subscription, 0)
+	for _, s := range m.subs {
+		if s.Active && !s.NextCharge.After(t) {
+			c := *s
+			due = append(due, &c)
+		}
+	}
+	return due, nil
+}
+
+// Charger performs a subsequent charge for a subscription.
+type Charger interface {
+	Charge(s *Subscription) (*sogenactif.Payment, error)
+}
+
+// Defaults of a Scheduler.
+const (
+	DefaultInterval    = time.Hour
+	DefaultMaxFailures = 3
+)
+
+// DefaultRetryDelays are the delays before the retries of a failed charge.
+var DefaultRetryDelays = []time.Duration{24 * time.Hour, 72 * time.Hour}
+
+// Scheduler charges due subscriptions at regular intervals.
+type Scheduler struct {
+	Store   Store
+	Charger Charger
+	// Interval between two lookups for due subscriptions. Defaults to
+	// DefaultInterval.
+	Interval time.Duration
+	// A subscription is deactivated after MaxFailures consecutive failed
+	// charges. Defaults to DefaultMaxFailures.
+	MaxFailures int
+	// RetryDelays are the delays after the failed charges before the next
+	// attempts, the last one being used for the following failures.
+	// Defaults to DefaultRetryDelays.
+	RetryDelays []time.Duration
+	// Clock is the source of the current time of the runs started by
+	// Start(), i.e. sogenactif.Config.Clock. Defaults to the system time.
+	Clock sogenactif.Clock
+	// Logger receives the errors of the runs started by Start(), i.e.
+	// sogenactif.Config.Logger. Defaults to the standard logger.
+	Logger *log.Logger
+	// Called after a successful charge.
+	OnSuccess func(s *Subscription, p *sogenactif.Payment)
+	// Called after a failed charge. The subscription is inactive if it
+	// reached MaxFailures.
+	OnFailure func(s *Subscription, err error)
+
+	mu   sync.Mutex
+	quit chan bool
+	done chan bool
+}
+
+// NewScheduler returns a scheduler using default settings.
+func NewScheduler(st Store, c Charger) *Scheduler {
+	return &Scheduler{Store: st, Charger: c, Interval: DefaultInterval, MaxFailures: DefaultMaxFailures}
+}
+
+// Start runs the scheduler in its own goroutine, with the defaults for
+// unset settings. It returns an error if the scheduler is already
+// running.
+func (s *Scheduler) Start() error {
+	if s.Store == nil || s.Charger == nil {
+		return errors.New("scheduler needs a store and a charger")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.quit != nil {
+		return errors.New("scheduler already running")
+	}
+	if s.Interval <= 0 {
+		s.Interval = DefaultInterval
+	}
+	s.quit, s.done = make(chan bool), make(chan bool)
+	go func() {
+		defer close(s.done)
+		tick := time.NewTicker(s.Interval)
+		defer tick.Stop()
+		for {
+			if err := s.RunDue(s.now()); err != nil {
+				s.logf("recurring: %s", err.Error())
+			}
+			select {
+			case <-tick.C:
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// now returns the current time of the clock of the scheduler.
+func (s *Scheduler) now() time.Time {
+	if s.Clock != nil {
+		return s.Clock.Now()
+	}
+	return time.Now()
+}
+
+// retryDelay returns the delay before the next attempt after failures
+// consecutive failed charges.
+func (s *Scheduler) retryDelay(failures int) time.Duration {
+	delays := s.RetryDelays
+	if len(delays) == 0 {
+		delays = DefaultRetryDelays
+	}
+	if failures > len(delays) {
+		failures = len(delays)
+	}
+	return delays[failures-1]
+}
+
+// logf logs a message with the logger of the scheduler.
+func (s *Scheduler) logf(format string, v ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// Stop stops the scheduler and waits for the current run to complete.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.quit == nil {
+		return
+	}
+	close(s.quit)
+	<-s.done
+	s.quit, s.done = nil, nil
+}
+
+// RunDue charges all subscriptions due at t. It is called periodically once the
+// scheduler has been started but can be used on its own (i.e from a cron job).
+// A failed charge is retried after the RetryDelays; the following charges
+// stay on the schedule of the subscription.
+func (s *Scheduler) RunDue(t time.Time) error {
+	subs, err := s.Store.Due(t)
+	if err != nil {
+		return err
+	}
+	max := s.MaxFailures
+	if max <= 0 {
+		max = DefaultMaxFailures
+	}
+	for _, sub := range subs {
+		p, err := s.Charger.Charge(sub)
+		if err == nil && p == nil {
+			err = errors.New("charger returned no payment")
+		} else if err == nil && p.ResponseCode != codeAccepted {
+			err = errors.New(fmt.Sprintf("charge refused (response code %s)", p.ResponseCode))
+		}
+		if err != nil {
+			sub.Failures++
+			if sub.Due.IsZero() {
+				sub.Due = sub.NextCharge
+			}
+			if sub.Failures >= max {
+				sub.Active = false
+			} else {
+				sub.NextCharge = t.Add(s.retryDelay(sub.Failures))
+			}
+		} else {
+			due := sub.NextCharge
+			if !sub.Due.IsZero() {
+				due = sub.Due
+			}
+			sub.Failures = 0
+			sub.Due = time.Time{}
+			sub.NextCharge = sub.Period.next(due)
+		}
+		if serr := s.Store.Save(sub); serr != nil {
+			return serr
+		}
+		if err != nil {
+			if s.OnFailure != nil {
+				s.OnFailure(sub, err)
+			}
+		} else if s.OnSuccess != nil {
+			s.OnSuccess(sub, p)
+		}
+	}
+	return nil
+}
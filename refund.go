@@ -0,0 +1,143 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// RefundResult is the record of a refund made on a payment.
+type RefundResult struct {
+	TransactionId string
+	PaymentDate   time.Time // Date of the refunded payment
+	Amount        int64     // Refunded amount, in cents
+	Remaining     int64     // Amount that can still be refunded, in cents
+	Status        string    // Transaction status after the refund
+	Cancelled     bool      // The amount was cancelled before being sent to the bank
+	Date          time.Time
+}
+
+// SetPaymentStore sets the store used to persist payments handled by
+// HandlePayment and the operations made on them.
+func (s *Sogen) SetPaymentStore(st PaymentStore) {
+	s.payments = st
+}
+
+// toCents converts an amount to the smallest unit of the currency.
+func toCents(amount float64) int64 {
+	return int64(math.Floor(amount*100 + 0.5))
+}
+
+// capturedAmount returns the amount of a payment sent to the bank, in
+// cents.
+func (s *Sogen) capturedAmount(p *Payment) (int64, error) {
+	if p.CaptureMode != CaptureValidation {
+		return toCents(p.Amount), nil
+	}
+	captures, err := s.payments.Captures(p.TransactionId)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, c := range captures {
+		if c.PaymentDate.Equal(p.PaymentDate) {
+			total += c.Amount
+		}
+	}
+	return total, nil
+}
+
+// Refund refunds amountCents of an accepted payment. A zero amount refunds
+// everything that has not been refunded yet. The payment is looked up in
+// the payment store, and the amount is checked against the captured amount
+// minus previous refunds. The captured amount is the sum of the captures of
+// a payment in validation mode (see Capture()), the payment amount
+// otherwise. A payment which has not been sent to the bank yet (see
+// CapturesOn()) is cancelled rather than credited.
+func (s *Sogen) Refund(ctx context.Context, transactionId string, amountCents int64) (*RefundResult, error) {
+	if s.operator == nil {
+		return nil, errors.New("refund: no operator")
+	}
+	if s.payments == nil {
+		return nil, errors.New("refund: no payment store")
+	}
+	payment, err := s.payments.Payment(transactionId)
+	if err != nil {
+		return nil, err
+	}
+	if payment.ResponseCode != "00" {
+		return nil, errors.New(fmt.Sprintf("refund: payment %s was not accepted", payment.TransactionId))
+	}
+	if amountCents < 0 {
+		return nil, errors.New("refund: negative amount")
+	}
+
+	s.opsMu.Lock()
+	defer s.opsMu.Unlock()
+
+	previous, err := s.payments.Refunds(payment.TransactionId)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := s.capturedAmount(payment)
+	if err != nil {
+		return nil, err
+	}
+	if remaining == 0 {
+		return nil, errors.New(fmt.Sprintf("refund: payment %s has not been captured", payment.TransactionId))
+	}
+	for _, r := range previous {
+		if r.PaymentDate.Equal(payment.PaymentDate) {
+			remaining -= r.Amount
+		}
+	}
+	if remaining <= 0 {
+		return nil, errors.New(fmt.Sprintf("refund: payment %s already fully refunded", payment.TransactionId))
+	}
+	if amountCents == 0 {
+		amountCents = remaining
+	}
+	if amountCents > remaining {
+		return nil, errors.New(fmt.Sprintf("refund: amount %d exceeds refundable amount %d", amountCents, remaining))
+	}
+
+	op := s.operator.Credit
+	cancel := payment.CaptureMode != CaptureValidation && s.now().Before(payment.CapturesOn())
+	if cancel {
+		op = s.operator.Cancel
+	}
+	status, err := op(ctx, payment.TransactionId, payment.PaymentDate, amountCents, payment.CurrencyCode)
+	if err != nil {
+		return nil, err
+	}
+	r := &RefundResult{
+		TransactionId: payment.TransactionId,
+		PaymentDate:   payment.PaymentDate,
+		Amount:        amountCents,
+		Remaining:     remaining - amountCents,
+		Status:        status,
+		Cancelled:     cancel,
+		Date:          s.now(),
+	}
+	if err := s.payments.SaveRefund(r); err != nil {
+		return r, errors.New("refund done but not recorded: " + err.Error())
+	}
+	s.Audit(AuditRefund, map[string]string{
+		"transaction_id": r.TransactionId,
+		"payment_date":   r.PaymentDate.Format("20060102150405"),
+		"amount":         strconv.FormatInt(r.Amount, 10),
+		"status":         r.Status,
+		"cancelled":      strconv.FormatBool(r.Cancelled),
+	})
+	for _, fn := range s.onRefunded {
+		fn(payment, r)
+	}
+	return r, nil
+}
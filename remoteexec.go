@@ -0,0 +1,103 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// RemoteExecutor is an Executor running the binaries on another host
+// through its ExecHandler, i.e. to develop on Windows or macOS, which the
+// SDK doesn't support, against a Linux host:
+//
+//	conf.Executor = &sogenactif.RemoteExecutor{URL: "http://linux:6060/exec", Token: token}
+//
+// The binaries use the pathfile and certificate of the remote host, which
+// must be set up for the same merchant.
+type RemoteExecutor struct {
+	URL    string       // URL of the ExecHandler
+	Token  string       // Shared secret of the ExecHandler
+	Client *http.Client // http.DefaultClient if nil
+}
+
+func (e *RemoteExecutor) Exec(ctx context.Context, file string, args []string, stdout io.Writer) error {
+	form := url.Values{"binary": {filepath.Base(file)}, "arg": args}
+	req, err := http.NewRequestWithContext(ctx, "POST", e.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+e.Token)
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.New("remote executor: " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return errors.New(fmt.Sprintf("remote executor: %s: %s", resp.Status, strings.TrimSpace(string(msg))))
+	}
+	_, err = io.Copy(stdout, resp.Body)
+	return err
+}
+
+// ExecHandler serves the binaries of s to RemoteExecutors presenting
+// token. The pathfile argument is replaced by the one of s. It must be
+// served on a private network: token can't be empty.
+func (s *Sogen) ExecHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth := []byte(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if token == "" || subtle.ConstantTimeCompare(auth, []byte(token)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, 2*MaxBodyLength)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var file string
+		switch r.PostForm.Get("binary") {
+		case "request":
+			file = s.requestFile
+		case "response":
+			file = s.responseFile
+		default:
+			http.Error(w, "unknown binary", http.StatusBadRequest)
+			return
+		}
+		args := r.PostForm["arg"]
+		for k, arg := range args {
+			if strings.HasPrefix(arg, "pathfile=") {
+				args[k] = "pathfile=" + s.pathFile
+			}
+		}
+		var out bytes.Buffer
+		if err := s.config.executor().Exec(r.Context(), file, args, &out); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		out.WriteTo(w)
+	})
+}
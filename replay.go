@@ -0,0 +1,146 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrReplayedNotification is returned along with the payment when a
+// response of the payment server has already been processed, i.e. when a
+// buyer posts the return URL again.
+var ErrReplayedNotification = errors.New("notification already processed")
+
+// Kinds of responses checked for replays.
+const (
+	replayReturn       = "return"
+	replayAutoResponse = "auto"
+	replayReview       = "review"
+	replayEvent        = "event"
+)
+
+// Number of responses recorded in memory without a transaction store.
+const maxRecentResponses = 10000
+
+// recentResponses records the last processed responses of the payment
+// server when there is no transaction store, the oldest being forgotten
+// first.
+type recentResponses struct {
+	mu    sync.Mutex
+	keys  map[string]bool
+	order []string // Oldest first
+}
+
+func (r *recentResponses) mark(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.keys[key] {
+		return true
+	}
+	if r.keys == nil {
+		r.keys = make(map[string]bool)
+	}
+	if len(r.order) >= maxRecentResponses {
+		delete(r.keys, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.keys[key] = true
+	r.order = append(r.order, key)
+	return false
+}
+
+func (r *recentResponses) unmark(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.keys[key] {
+		return
+	}
+	delete(r.keys, key)
+	for i, k := range r.order {
+		if k == key {
+			r.order = append(r.order[:i:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// checkReplay records a response of the payment server and returns
+// ErrReplayedNotification if it was already recorded for the same kind.
+// Responses are recorded in the transaction store, if any, so that the
+// processes sharing it agree, in memory otherwise. A transaction ID being
+// unique over a day, a response is identified by its transaction ID and
+// payment date.
+func (s *Sogen) checkReplay(kind string, p *Payment) error {
+	if s.transactions == nil {
+		if s.recent.mark(replayKey(kind, p)) {
+			return ErrReplayedNotification
+		}
+		return nil
+	}
+	seen, err := s.transactions.MarkProcessed(replayKey(kind, p))
+	if err != nil {
+		return err
+	}
+	if seen {
+		return ErrReplayedNotification
+	}
+	return nil
+}
+
+// replayKey returns the key recording a response of the payment server.
+func replayKey(kind string, p *Payment) string {
+	return kind + ":" + p.TransactionId + "@" + p.PaymentDate.Format("20060102")
+}
+
+// ProcessedUnmarker is implemented by the transaction stores able to
+// forget a processed response, so that the auto responses whose handling
+// failed are processed again when the payment server retries them.
+type ProcessedUnmarker interface {
+	UnmarkProcessed(key string) error
+}
+
+func (m *MemoryTransactionStore) UnmarkProcessed(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.processed, key)
+	return nil
+}
+
+// uncheckReplay forgets a response recorded by checkReplay(), if the
+// transaction store supports it.
+func (s *Sogen) uncheckReplay(kind string, p *Payment) {
+	if s.transactions == nil {
+		s.recent.unmark(replayKey(kind, p))
+		return
+	}
+	u, ok := s.transactions.(ProcessedUnmarker)
+	if !ok {
+		s.config.logf(LogWarning, "Warning: transaction %s: the transaction store can't forget the failed response", p.TransactionId)
+		return
+	}
+	if err := u.UnmarkProcessed(replayKey(kind, p)); err != nil {
+		s.config.logf(LogError, "replay: %s", err.Error())
+	}
+}
+
+// HandleReturn generates a payment from the request posted to the
+// return_url, like HandlePayment(). If the response was already handled,
+// the payment is returned along with ErrReplayedNotification so that the
+// caller can skip fulfillment. With session binding, the payment is
+// returned along with ErrSessionMismatch if the session cookie does not
+// match (see SetSessionKey()); it is then left to the auto response: it is
+// neither stored nor passed to the hooks.
+func (s *Sogen) HandleReturn(w io.Writer, r *http.Request) (*Payment, error) {
+	p, err := s.handlePayment(w, r, func(p *Payment) error {
+		return s.VerifySession(p, requestSession(r))
+	})
+	if err != nil {
+		return p, err
+	}
+	return p, s.checkReplay(replayReturn, p)
+}
@@ -0,0 +1,37 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reports
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"time"
+)
+
+// Disputes converts the entries of a chargebacks report to disputes, to be
+// recorded with Sogen.RecordDispute().
+func Disputes(entries []*Entry) ([]*sogenactif.Dispute, error) {
+	disputes := make([]*sogenactif.Dispute, 0, len(entries))
+	for _, e := range entries {
+		d := &sogenactif.Dispute{
+			TransactionId: e.TransactionId,
+			PaymentDate:   e.PaymentDate,
+			Amount:        e.Amount,
+			CurrencyCode:  e.CurrencyCode,
+			Reason:        e.Fields[ColReason],
+			Reference:     e.Fields[ColReference],
+		}
+		if v := e.Fields[ColDisputeDate]; v != "" {
+			date, err := time.Parse("20060102", v)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("line %d: bad dispute date: %s", e.Line, err.Error()))
+			}
+			d.Date = date
+		}
+		disputes = append(disputes, d)
+	}
+	return disputes, nil
+}
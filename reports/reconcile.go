@@ -0,0 +1,105 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reports
+
+import (
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"math"
+	"time"
+)
+
+// DiscrepancyKind is the kind of mismatch between a report and the stored
+// payments.
+type DiscrepancyKind int
+
+const (
+	MissingPayment   DiscrepancyKind = iota // Entry in the report without stored payment
+	MissingEntry                            // Accepted payment missing from the report
+	AmountMismatch                          // Amounts differ
+	CurrencyMismatch                        // Currencies differ
+)
+
+func (k DiscrepancyKind) String() string {
+	switch k {
+	case MissingPayment:
+		return "missing payment"
+	case MissingEntry:
+		return "missing entry"
+	case AmountMismatch:
+		return "amount mismatch"
+	case CurrencyMismatch:
+		return "currency mismatch"
+	}
+	return "unknown"
+}
+
+// Discrepancy is a mismatch found while reconciling a report.
+type Discrepancy struct {
+	Kind    DiscrepancyKind
+	Entry   *Entry              // Nil for MissingEntry
+	Payment *sogenactif.Payment // Nil for MissingPayment
+}
+
+func (d *Discrepancy) String() string {
+	switch d.Kind {
+	case MissingPayment:
+		return fmt.Sprintf("%s: transaction %s (line %d)", d.Kind, d.Entry.TransactionId, d.Entry.Line)
+	case MissingEntry:
+		return fmt.Sprintf("%s: transaction %s of %s", d.Kind, d.Payment.TransactionId,
+			d.Payment.PaymentDate.Format("2006-01-02"))
+	case AmountMismatch:
+		return fmt.Sprintf("%s: transaction %s (line %d): report %d, payment %d", d.Kind, d.Entry.TransactionId,
+			d.Entry.Line, d.Entry.Amount, cents(d.Payment.Amount))
+	}
+	return fmt.Sprintf("%s: transaction %s (line %d): report %s, payment %s", d.Kind, d.Entry.TransactionId,
+		d.Entry.Line, d.Entry.CurrencyCode, d.Payment.CurrencyCode)
+}
+
+func cents(amount float64) int64 {
+	return int64(math.Floor(amount*100 + 0.5))
+}
+
+// key identifies a transaction: a transaction ID is unique over a day.
+func key(transactionId string, date time.Time) string {
+	return transactionId + "@" + date.Format("20060102")
+}
+
+// Reconcile matches report entries against the payments stored for the
+// period [from, to) and returns all discrepancies found. Only accepted
+// payments are expected in the report.
+func Reconcile(entries []*Entry, store sogenactif.PaymentStore, from, to time.Time) ([]*Discrepancy, error) {
+	payments, err := store.Payments(from, to)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]*sogenactif.Payment)
+	for _, p := range payments {
+		stored[key(p.TransactionId, p.PaymentDate)] = p
+	}
+	diffs := make([]*Discrepancy, 0)
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		k := key(e.TransactionId, e.PaymentDate)
+		seen[k] = true
+		p, ok := stored[k]
+		if !ok {
+			diffs = append(diffs, &Discrepancy{Kind: MissingPayment, Entry: e})
+			continue
+		}
+		if e.Amount != cents(p.Amount) {
+			diffs = append(diffs, &Discrepancy{Kind: AmountMismatch, Entry: e, Payment: p})
+		}
+		if e.CurrencyCode != "" && e.CurrencyCode != p.CurrencyCode {
+			diffs = append(diffs, &Discrepancy{Kind: CurrencyMismatch, Entry: e, Payment: p})
+		}
+	}
+	for _, p := range payments {
+		if p.ResponseCode == "00" && !seen[key(p.TransactionId, p.PaymentDate)] {
+			diffs = append(diffs, &Discrepancy{Kind: MissingEntry, Payment: p})
+		}
+	}
+	return diffs, nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package reports parses the daily files provided by the Sogenactif
+// platform (transactions journal, remittance statement) and reconciles
+// them against stored payments.
+//
+// Files are made of fixed-width records whose columns are described by a
+// Layout. Default layouts are provided for both files; copy and adapt them
+// if your contract delivers a different version of a file:
+//
+//	f, _ := os.Open("journal.txt")
+//	entries, err := reports.Parse(f, reports.TransactionsJournal)
+//	diffs, err := reports.Reconcile(entries, store, from, to)
+//
+// Chargebacks are imported with Disputes():
+//
+//	entries, err := reports.Parse(f, reports.Chargebacks)
+//	disputes, err := reports.Disputes(entries)
+//	for _, d := range disputes {
+//		err = sogen.RecordDispute(d)
+//	}
+package reports
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Well-known column names. Other columns are available in Entry.Fields.
+const (
+	ColMerchantId    = "merchant_id"
+	ColTransactionId = "transaction_id"
+	ColPaymentDate   = "payment_date"
+	ColAmount        = "amount"
+	ColCurrencyCode  = "currency_code"
+	ColResponseCode  = "response_code"
+	ColStatus        = "status"
+	ColPaymentMeans  = "payment_means"
+	ColOperation     = "operation"
+	ColRemittance    = "remittance_date"
+	ColDisputeDate   = "dispute_date"
+	ColReason        = "reason_code"
+	ColReference     = "reference"
+)
+
+// Column is a fixed-width column of a record.
+type Column struct {
+	Name   string
+	Offset int // Starting at 0
+	Width  int
+}
+
+// Layout describes the records of a report file.
+type Layout struct {
+	Name        string
+	HeaderLines int // Number of lines to skip at the beginning of the file
+	Columns     []Column
+}
+
+// TransactionsJournal is the default layout of the transactions journal
+// (journal des transactions).
+var TransactionsJournal = &Layout{
+	Name:        "transactions journal",
+	HeaderLines: 1,
+	Columns: []Column{
+		{ColMerchantId, 0, 15},
+		{ColTransactionId, 15, 6},
+		{ColPaymentDate, 21, 8},
+		{ColAmount, 29, 12},
+		{ColCurrencyCode, 41, 3},
+		{ColResponseCode, 44, 2},
+		{ColStatus, 46, 20},
+		{ColPaymentMeans, 66, 20},
+	},
+}
+
+// RemittanceStatement is the default layout of the remittance statement
+// (relevé des remises).
+var RemittanceStatement = &Layout{
+	Name:        "remittance statement",
+	HeaderLines: 1,
+	Columns: []Column{
+		{ColRemittance, 0, 8},
+		{ColMerchantId, 8, 15},
+		{ColTransactionId, 23, 6},
+		{ColPaymentDate, 29, 8},
+		{ColAmount, 37, 12},
+		{ColCurrencyCode, 49, 3},
+		{ColOperation, 52, 6},
+	},
+}
+
+// Chargebacks is the default layout of the chargebacks report (relevé des
+// impayés).
+var Chargebacks = &Layout{
+	Name:        "chargebacks",
+	HeaderLines: 1,
+	Columns: []Column{
+		{ColDisputeDate, 0, 8},
+		{ColMerchantId, 8, 15},
+		{ColTransactionId, 23, 6},
+		{ColPaymentDate, 29, 8},
+		{ColAmount, 37, 12},
+		{ColCurrencyCode, 49, 3},
+		{ColReason, 52, 4},
+		{ColReference, 56, 20},
+	},
+}
+
+// Entry is a record of a report file.
+type Entry struct {
+	Line          int // Line number in the file
+	MerchantId    string
+	TransactionId string
+	PaymentDate   time.Time
+	Amount        int64 // In cents
+	CurrencyCode  string
+	ResponseCode  string
+	Status        string
+	Fields        map[string]string // All columns, trimmed
+}
+
+// Parse reads all records of a report file.
+func Parse(r io.Reader, l *Layout) ([]*Entry, error) {
+	if l == nil {
+		return nil, errors.New("nil layout")
+	}
+	width := 0
+	for _, c := range l.Columns {
+		if c.Offset+c.Width > width {
+			width = c.Offset + c.Width
+		}
+	}
+	entries := make([]*Entry, 0)
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		if line <= l.HeaderLines {
+			continue
+		}
+		rec := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(rec) == "" {
+			continue
+		}
+		// Trailing blank columns may be stripped
+		if len(rec) < width {
+			rec += strings.Repeat(" ", width-len(rec))
+		}
+		e, err := parseEntry(rec, l)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s, line %d: %s", l.Name, line, err.Error()))
+		}
+		e.Line = line
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func parseEntry(rec string, l *Layout) (*Entry, error) {
+	e := &Entry{Fields: make(map[string]string)}
+	for _, c := range l.Columns {
+		e.Fields[c.Name] = strings.TrimSpace(rec[c.Offset : c.Offset+c.Width])
+	}
+	e.MerchantId = e.Fields[ColMerchantId]
+	e.TransactionId = e.Fields[ColTransactionId]
+	e.CurrencyCode = e.Fields[ColCurrencyCode]
+	e.ResponseCode = e.Fields[ColResponseCode]
+	e.Status = e.Fields[ColStatus]
+	if e.TransactionId == "" {
+		return nil, errors.New("missing transaction ID")
+	}
+	var err error
+	if v := e.Fields[ColPaymentDate]; v != "" {
+		if e.PaymentDate, err = time.Parse("20060102", v); err != nil {
+			return nil, errors.New("bad payment date: " + err.Error())
+		}
+	}
+	if v := e.Fields[ColAmount]; v != "" {
+		if e.Amount, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.New("bad amount: " + err.Error())
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIdHeader is the header holding the ID of a request, set by
+// RequestIdHandler on the request and the response.
+const RequestIdHeader = "X-Request-Id"
+
+type requestIdKey struct{}
+
+// WithRequestId returns a context holding a request ID.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId returns the request ID of a context, if any.
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// validRequestId reports whether an incoming request ID, i.e. set by a
+// reverse proxy, can be used as is: it ends up in the logs.
+func validRequestId(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RequestIdHandler returns a handler giving an ID to each request, or
+// reusing the one of the X-Request-Id header. The ID is set in the context
+// of the request (see RequestId()) and in the response header. The
+// handlers of the package log it along with payment errors.
+func RequestIdHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIdHeader)
+		if !validRequestId(id) {
+			id = newRequestId()
+		}
+		r.Header.Set(RequestIdHeader, id)
+		w.Header().Set(RequestIdHeader, id)
+		h.ServeHTTP(w, r.WithContext(WithRequestId(r.Context(), id)))
+	})
+}
+
+// logRequest logs a message about the handling of a request, along with
+// its ID, if any.
+func (s *Sogen) logRequest(id, format string, v ...interface{}) {
+	if id != "" {
+		format = "[" + id + "] " + format
+	}
+	s.config.logf(LogError, format, v...)
+}
@@ -0,0 +1,53 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Request parameters masked by RequestParams().
+var sensitiveParams = map[string]bool{
+	"merchant_id":         true,
+	"customer_email":      true,
+	"customer_ip_address": true,
+	"caddie":              true,
+	"return_context":      true,
+}
+
+// RequestParams returns the parameters passed to the request binary by
+// the last checkout of the transaction, as sorted key=value pairs, i.e.
+// amount=1234. Personal data and secrets are masked. It is nil before any
+// checkout.
+func (t *Transaction) RequestParams() []string {
+	return t.sentParams
+}
+
+// redactParams returns the sorted key=value parameters of the request
+// binary, with the values of sensitive parameters masked.
+func redactParams(params []string) []string {
+	res := make([]string, 0, len(params))
+	for _, p := range params {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 && sensitiveParams[kv[0]] {
+			p = kv[0] + "=" + redact(kv[1])
+		}
+		res = append(res, p)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// recordParams records the parameters of a checkout on the transaction
+// and sends them to the debug writer, if any, when the debug setting is
+// on.
+func (s *Sogen) recordParams(t *Transaction, params []string) {
+	t.sentParams = redactParams(params)
+	if s.config.Debug && s.debugWriter != nil {
+		fmt.Fprintf(s.debugWriter, "request %s\n", strings.Join(t.sentParams, " "))
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// MaxDataLength is the maximum length of the DATA field of a response.
+// Genuine ones are a few kilobytes long.
+const MaxDataLength = 16 << 10
+
+// MaxBodyLength is the maximum size of the body of a request of the
+// payment server: the DATA field and some room for other fields.
+const MaxBodyLength = MaxDataLength + 1<<10
+
+// Number of payment fields in the output of the response binary.
+const paymentFields = 35
+
+// Errors of a response of the payment server, matching a *ResponseError
+// with errors.Is().
+var (
+	ErrMissingData       = errors.New("missing sogen data")
+	ErrInvalidData       = errors.New("invalid sogen data")
+	ErrMalformedResponse = errors.New("malformed response")
+	// The DATA field can only be posted or sent in the query string.
+	ErrMethodNotAllowed = errors.New("sogen data must be sent with POST or GET")
+	// The DATA field must be posted form-encoded.
+	ErrUnsupportedContentType = errors.New("sogen data must be posted as application/x-www-form-urlencoded")
+	// The body is above MaxBodyLength.
+	ErrBodyTooLarge = errors.New("request body too large")
+)
+
+// ResponseError is returned for a response of the payment server which
+// can't be parsed: a hostile or truncated DATA field, or unexpected output
+// of the response binary.
+type ResponseError struct {
+	Err    error // ErrInvalidData or ErrMalformedResponse
+	Detail string
+}
+
+// responseData returns the DATA field of a request of the payment server:
+// form-encoded with POST or in the query string with GET, as some
+// configurations return buyers to the return URL. The body is limited to
+// MaxBodyLength. w may be nil.
+func responseData(w http.ResponseWriter, r *http.Request) (string, error) {
+	switch r.Method {
+	case "POST":
+		ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || ct != "application/x-www-form-urlencoded" {
+			return "", ErrUnsupportedContentType
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBodyLength)
+		if err := r.ParseForm(); err != nil {
+			var merr *http.MaxBytesError
+			if errors.As(err, &merr) {
+				return "", ErrBodyTooLarge
+			}
+			return "", &ResponseError{ErrInvalidData, err.Error()}
+		}
+		return r.PostForm.Get("DATA"), nil
+	case "GET":
+		return r.URL.Query().Get("DATA"), nil
+	}
+	return "", ErrMethodNotAllowed
+}
+
+// responseStatus returns the HTTP status of a failed response handling.
+func responseStatus(err error) int {
+	switch err {
+	case ErrMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case ErrUnsupportedContentType:
+		return http.StatusUnsupportedMediaType
+	case ErrBodyTooLarge:
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+func (e *ResponseError) Error() string {
+	return e.Err.Error() + ": " + e.Detail
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// validateData checks a DATA field before handing it to the response
+// binary: it must be a non empty alphanumeric string.
+func validateData(data string) error {
+	if len(data) == 0 {
+		return ErrMissingData
+	}
+	if len(data) > MaxDataLength {
+		return &ResponseError{ErrInvalidData, fmt.Sprintf("%d bytes long, more than %d", len(data), MaxDataLength)}
+	}
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return &ResponseError{ErrInvalidData, fmt.Sprintf("unexpected byte %q at offset %d", c, i)}
+		}
+	}
+	return nil
+}
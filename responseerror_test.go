@@ -0,0 +1,130 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// responseSogen returns a Sogen whose response binary outputs out.
+func responseSogen(out string) *Sogen {
+	return &Sogen{config: &Config{
+		Executor: outputExecutor(out),
+		Logger:   log.New(io.Discard, "", 0),
+	}}
+}
+
+// checkResponseError fails unless err is one of the errors of a response
+// which can't be parsed.
+func checkResponseError(t *testing.T, err error) {
+	var rerr *ResponseError
+	var aerr *APIError
+	switch {
+	case errors.As(err, &rerr):
+		if rerr.Err != ErrInvalidData && rerr.Err != ErrMalformedResponse {
+			t.Fatalf("unexpected response error %v", err)
+		}
+	case errors.As(err, &aerr):
+	case err == ErrMissingData, err == ErrBodyTooLarge, err == ErrUnsupportedContentType:
+	case strings.HasPrefix(err.Error(), "error: unexpected output"):
+	default:
+		t.Fatalf("untyped error %v", err)
+	}
+}
+
+func FuzzValidateData(f *testing.F) {
+	f.Add("")
+	f.Add("2020333732603028502c2360532d5c2360")
+	f.Add("DATA=2020; rm -rf /")
+	f.Add("2020\x00333")
+	f.Add(strings.Repeat("a", MaxDataLength+1))
+	f.Fuzz(func(t *testing.T, data string) {
+		err := validateData(data)
+		if err == nil {
+			if len(data) == 0 || len(data) > MaxDataLength {
+				t.Fatalf("%d bytes long DATA accepted", len(data))
+			}
+			for i := 0; i < len(data); i++ {
+				c := data[i]
+				if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+					t.Fatalf("byte %q accepted", c)
+				}
+			}
+			return
+		}
+		checkResponseError(t, err)
+	})
+}
+
+func FuzzParseResponse(f *testing.F) {
+	f.Add("2020333732603028502c2360532d5c2360", responseOutput)
+	f.Add("2020", "!0!!")
+	f.Add("2020", "!0!!"+strings.Repeat("!", paymentFields))
+	f.Add("2020", strings.Replace(responseOutput, "2500", "-2500", 1))
+	f.Add("2020", strings.Replace(responseOutput, "2500", "NaN", 1))
+	f.Add("2020", strings.Replace(responseOutput, "20130331143000", "2013", 1))
+	f.Add("2020", strings.Replace(responseOutput, "AUTHOR_CAPTURE", "PAYMENT_N", 1))
+	f.Add("2020", "!-1!Error : invalid message!")
+	f.Fuzz(func(t *testing.T, data, out string) {
+		p, err := responseSogen(out).ParseResponse(io.Discard, data)
+		if err != nil {
+			checkResponseError(t, err)
+			return
+		}
+		if p.Amount <= 0 {
+			t.Fatalf("payment of %v accepted", p.Amount)
+		}
+	})
+}
+
+func FuzzHandlePayment(f *testing.F) {
+	f.Add("POST", "application/x-www-form-urlencoded", "DATA=2020333732603028502c2360532d5c2360")
+	f.Add("POST", "application/x-www-form-urlencoded", "DATA=")
+	f.Add("POST", "application/x-www-form-urlencoded", "DATA=%zz")
+	f.Add("POST", "multipart/form-data", "DATA=2020")
+	f.Add("GET", "", "DATA=2020")
+	f.Add("PUT", "application/x-www-form-urlencoded", "DATA=2020")
+	f.Fuzz(func(t *testing.T, method, contentType, body string) {
+		if method != "GET" && method != "POST" && method != "PUT" {
+			return
+		}
+		target := "/return"
+		if method == "GET" {
+			target += "?" + body
+		}
+		r, err := http.NewRequest(method, target, strings.NewReader(body))
+		if err != nil {
+			return
+		}
+		r.Header.Set("Content-Type", contentType)
+		_, err = responseSogen(responseOutput).HandlePayment(httptest.NewRecorder(), r)
+		if err == nil {
+			return
+		}
+		if err == ErrMethodNotAllowed {
+			if method != "PUT" {
+				t.Fatalf("%s not allowed", method)
+			}
+			return
+		}
+		checkResponseError(t, err)
+	})
+}
+
+func TestHandlePaymentBodyTooLarge(t *testing.T) {
+	body := url.Values{"DATA": {strings.Repeat("a", MaxBodyLength)}}.Encode()
+	r := httptest.NewRequest("POST", "/return", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := responseSogen(responseOutput).HandlePayment(httptest.NewRecorder(), r); err != ErrBodyTooLarge {
+		t.Fatalf("got %v, want %v", err, ErrBodyTooLarge)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErasedCustomerId replaces the customer ID of payments anonymized after
+// an erasure request.
+const ErasedCustomerId = "erased"
+
+// AnonymizePayment clears the personal data of a payment (customer email
+// and IP address, caddie, return context, complementary data) while
+// keeping the accounting fields.
+func AnonymizePayment(p *Payment) {
+	p.CustomerEmail = ""
+	p.CustomerIpAddress = ""
+	p.Caddie = ""
+	p.ReturnContext = ""
+	p.Data = ""
+}
+
+func anonymized(p *Payment) bool {
+	return p.CustomerEmail == "" && p.CustomerIpAddress == "" && p.Caddie == "" && p.ReturnContext == "" &&
+		p.Data == ""
+}
+
+// ApplyRetention anonymizes the stored payments made more than
+// retention_days days before now (see Config.RetentionDays) and returns
+// the number of payments anonymized.
+func (s *Sogen) ApplyRetention(now time.Time) (int, error) {
+	if s.payments == nil {
+		return 0, errors.New("retention: no payment store")
+	}
+	if s.config.RetentionDays <= 0 {
+		return 0, nil
+	}
+	payments, err := s.payments.Payments(time.Time{}, now.AddDate(0, 0, -s.config.RetentionDays))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range payments {
+		if anonymized(p) {
+			continue
+		}
+		cp := *p
+		AnonymizePayment(&cp)
+		if err := s.payments.SavePayment(&cp); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// RetentionLoop applies the retention policy every interval until ctx is
+// done. Run it in its own goroutine:
+//
+//	go sogen.RetentionLoop(ctx, 24*time.Hour)
+func (s *Sogen) RetentionLoop(ctx context.Context, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		if n, err := s.ApplyRetention(s.now()); err != nil {
+			s.config.logf(LogError, "retention: %s", err.Error())
+		} else if n > 0 {
+			s.config.logf(LogInfo, "retention: anonymized %d payments", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// ErasureRequest honors the deletion request of a customer: all stored
+// payments of the customer are anonymized, including the customer ID, and
+// the card aliases of the customer are deleted. It returns the number of
+// payments anonymized.
+//
+// The audit trail being append-only, it can't be erased: it holds no
+// customer ID, email nor caddie, but the raw DATA of the notifications
+// (see AuditNotification) can still be decoded with the certificate of the
+// merchant.
+func (s *Sogen) ErasureRequest(customerId string) (int, error) {
+	if customerId == "" {
+		return 0, errors.New("erasure: empty customer ID")
+	}
+	if s.payments == nil {
+		return 0, errors.New("erasure: no payment store")
+	}
+	payments, err := s.payments.Payments(time.Time{}, s.now().AddDate(1, 0, 0))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range payments {
+		if p.CustomerId != customerId {
+			continue
+		}
+		cp := *p
+		AnonymizePayment(&cp)
+		cp.CustomerId = ErasedCustomerId
+		cp.CardAlias = ""
+		if err := s.payments.SavePayment(&cp); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if s.aliases != nil {
+		aliases, err := s.aliases.List(customerId)
+		if err != nil {
+			return n, err
+		}
+		for _, a := range aliases {
+			if err := s.aliases.Delete(customerId, a.Id); err != nil {
+				return n, err
+			}
+		}
+	}
+	// Don't keep the customer ID in the audit trail either
+	s.Audit(AuditErasure, map[string]string{"payments": strconv.Itoa(n)})
+	return n, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxReturnContextLength is the maximum length of the return_context
+// field.
+const MaxReturnContextLength = 256
+
+// The return_context holds the signed session state, if the transaction
+// is bound to a session, the context set with SetReturnContext() and the
+// indicative price shown to the buyer, if any (see FXQuote), separated by
+// dots. None uses the dot in its encoding.
+const returnContextSep = "."
+
+// SetReturnContext sets the context of the purchase to the JSON encoding
+// of v, i.e. order metadata. It is escaped as unpadded base64url and sent
+// back unmodified with the payment (see Payment.DecodeReturnContext()).
+func (t *Transaction) SetReturnContext(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	enc := base64.RawURLEncoding.EncodeToString(data)
+	// Leave room for the session state
+	if max := MaxReturnContextLength - 45; len(enc) > max {
+		return errors.New(fmt.Sprintf("return context longer than %d chars once encoded", max))
+	}
+	t.returnCtx = enc
+	return nil
+}
+
+// returnContext returns the return_context of a transaction, if any.
+func (s *Sogen) returnContext(t *Transaction) string {
+	state := ""
+	if t.session != "" && s.sessionKey != nil {
+		state = s.sessionState(t.session)
+	}
+	rc := state
+	if t.returnCtx != "" || t.quote != nil {
+		rc += returnContextSep + t.returnCtx
+	}
+	if t.quote != nil {
+		fx := returnContextSep + encodeQuote(t.quote)
+		if len(rc)+len(fx) > MaxReturnContextLength {
+			s.config.logf(LogWarning, "Warning: no room in the return context of transaction %s for the indicative price", t.transId)
+			return rc
+		}
+		rc += fx
+	}
+	return rc
+}
+
+// splitReturnContext returns the session state, the encoded context and
+// the encoded indicative price of a return_context.
+func splitReturnContext(rc string) (string, string, string) {
+	parts := strings.SplitN(rc, returnContextSep, 3)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return parts[0], parts[1], parts[2]
+}
+
+// DecodeReturnContext decodes into v the context set with
+// Transaction.SetReturnContext().
+func (p *Payment) DecodeReturnContext(v interface{}) error {
+	_, ctx, _ := splitReturnContext(p.ReturnContext)
+	if ctx == "" {
+		return errors.New("no return context")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(ctx)
+	if err != nil {
+		return errors.New("bad return context encoding: " + err.Error())
+	}
+	return json.Unmarshal(data, v)
+}
+
+// DecodeCaddie decodes into v a caddie set with Caddie.FromJSON().
+func (p *Payment) DecodeCaddie(v interface{}) error {
+	return Caddie(p.Caddie).ToJSON(v)
+}
@@ -0,0 +1,65 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ScoreColor is the risk color of a payment computed by the payment
+// server, if a scoring profile is enabled.
+type ScoreColor string
+
+const (
+	ScoreNone   ScoreColor = ""       // No scoring
+	ScoreGreen  ScoreColor = "GREEN"  // Low risk
+	ScoreOrange ScoreColor = "ORANGE" // Payment should be reviewed
+	ScoreRed    ScoreColor = "RED"    // High risk
+)
+
+// parseScore parses a score value or threshold. An empty value is 0.
+func parseScore(v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// needsReview reports whether a payment is flagged for manual review,
+// either by the payment server or by the fraud policy.
+func (p *Payment) needsReview() bool {
+	return p.ScoreColor == ScoreOrange || (p.Fraud != nil && p.Fraud.Decision == Review)
+}
+
+// denied reports whether the fraud policy denied a payment.
+func (p *Payment) denied() bool {
+	return p.Fraud != nil && p.Fraud.Decision == Deny
+}
+
+// OnReviewRequired registers a function called with every payment flagged
+// for manual review (orange score color or Review fraud verdict), i.e. to
+// hold the order. It is called once per payment even if both the return
+// and auto responses are handled.
+func (s *Sogen) OnReviewRequired(fn func(p *Payment)) {
+	s.onReview = append(s.onReview, fn)
+}
+
+// reviewRequired fires the OnReviewRequired hooks if p needs a review.
+func (s *Sogen) reviewRequired(p *Payment) error {
+	if len(s.onReview) == 0 || !p.needsReview() {
+		return nil
+	}
+	if err := s.checkReplay(replayReview, p); err == ErrReplayedNotification {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, fn := range s.onReview {
+		fn(p)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ConfigKeyVar is the env var holding the base64-encoded AES key (16, 24
+// or 32 bytes) decrypting the enc: values of config files.
+const ConfigKeyVar = "SOGEN_CONFIG_KEY"
+
+// Prefix of the encrypted values of config files, i.e.
+// merchant_id=enc:kX6N...
+const encryptedPrefix = "enc:"
+
+// configKey returns the key of ConfigKeyVar.
+func configKey() ([]byte, error) {
+	v := os.Getenv(ConfigKeyVar)
+	if v == "" {
+		return nil, errors.New("encrypted value but " + ConfigKeyVar + " not defined")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+	if err != nil {
+		return nil, errors.New(ConfigKeyVar + ": " + err.Error())
+	}
+	return key, nil
+}
+
+func configCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptValue encrypts a value of a config file with AES-GCM. The result,
+// prefixed with enc:, is decrypted by LoadConfig() with the key of the
+// SOGEN_CONFIG_KEY env var.
+func EncryptValue(key []byte, value string) (string, error) {
+	aead, err := configCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedPrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// decryptValue decrypts a value of a config file if it is encrypted.
+func decryptValue(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	key, err := configKey()
+	if err != nil {
+		return "", err
+	}
+	aead, err := configCipher(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("can't decrypt value, wrong key?")
+	}
+	return string(plain), nil
+}
+
+// redact masks all but the last 4 chars of a secret.
+func redact(s string) string {
+	if len(s) <= 4 {
+		return strings.Repeat("*", len(s))
+	}
+	return strings.Repeat("*", len(s)-4) + s[len(s)-4:]
+}
+
+// redactURL masks the user info, path and query of a URL holding secrets,
+// such as a webhook URL.
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host + "/***"
+}
+
+// String returns a description of the config safe to log: secrets are
+// masked.
+func (c *Config) String() string {
+	urlString := func(u *url.URL) string {
+		if u == nil {
+			return ""
+		}
+		return u.Redacted()
+	}
+	return fmt.Sprintf("merchant %s (%s), environment %q, library %s, merchants dir %s, cancel URL %s, "+
+		"return URL %s, auto response URL %s, webhook %s", redact(c.MerchantId), c.MerchantCountry, c.Environment,
+		c.LibraryPath, c.MerchantsRootDir, urlString(c.CancelUrl), urlString(c.ReturnUrl),
+		urlString(c.AutoResponseUrl), redactURL(c.WebhookUrl))
+}
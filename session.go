@@ -0,0 +1,80 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+// SessionCookie is the name of the cookie holding the session ID set by
+// CheckoutHandler().
+const SessionCookie = "sogen_session"
+
+// ErrSessionMismatch is returned along with the payment when the browser
+// posting the return URL is not the one which checked out.
+var ErrSessionMismatch = errors.New("payment bound to another session")
+
+// SetSessionKey enables session binding: the session ID of a transaction
+// (see Transaction.BindSession()) is signed with key and sent as the
+// return_context, then verified when the customer comes back.
+func (s *Sogen) SetSessionKey(key []byte) {
+	s.sessionKey = key
+}
+
+// BindSession ties the transaction to a browser session, i.e. the ID of a
+// session cookie. It requires a session key (see Sogen.SetSessionKey()).
+func (t *Transaction) BindSession(id string) {
+	t.session = id
+}
+
+// sessionState returns the signed state of a session ID.
+func (s *Sogen) sessionState(id string) string {
+	mac := hmac.New(sha256.New, s.sessionKey)
+	mac.Write([]byte(id))
+	return base64.URLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySession checks that a payment was made for the session ID. It
+// returns ErrSessionMismatch if not. Payments of transactions which were
+// not bound to a session are not checked.
+func (s *Sogen) VerifySession(p *Payment, id string) error {
+	state, _, _ := splitReturnContext(p.ReturnContext)
+	if s.sessionKey == nil || state == "" {
+		return nil
+	}
+	if !hmac.Equal([]byte(state), []byte(s.sessionState(id))) {
+		return ErrSessionMismatch
+	}
+	return nil
+}
+
+// sessionId returns the session ID of a request, setting a new session
+// cookie if none.
+func sessionId(w http.ResponseWriter, r *http.Request) (string, error) {
+	if c, err := r.Cookie(SessionCookie); err == nil && c.Value != "" {
+		return c.Value, nil
+	}
+	id, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	// The payment server posts the return URL: the cookie must be sent
+	// along with cross-site requests
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Value: id, Path: "/", HttpOnly: true,
+		Secure: true, SameSite: http.SameSiteNoneMode})
+	return id, nil
+}
+
+// requestSession returns the session ID of a request, if any.
+func requestSession(r *http.Request) string {
+	if c, err := r.Cookie(SessionCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
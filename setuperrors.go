@@ -0,0 +1,89 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors of an incomplete config, returned by NewSogen().
+var (
+	ErrNilConfig               = errors.New("can't initialize Sogen framework: nil config.")
+	ErrMissingMerchantId       = errors.New("missing merchant ID")
+	ErrMissingMerchantsRootDir = errors.New("missing merchant root directory (for config files and certificates)")
+)
+
+// ErrMissingCertificate is returned by NewSogen() when the certificate of
+// the merchant can't be read, i.e. it has not been synced yet, or when
+// several certificate files could be the one of the merchant.
+type ErrMissingCertificate struct {
+	Path       string
+	Err        error    // Underlying error, if any
+	Candidates []string // Certificate files found for the merchant ID
+}
+
+func (e *ErrMissingCertificate) Error() string {
+	if len(e.Candidates) > 0 {
+		return fmt.Sprintf("missing certificate file %s, found: %s", e.Path, strings.Join(e.Candidates, ", "))
+	}
+	return fmt.Sprintf("missing certificate file %s", e.Path)
+}
+
+func (e *ErrMissingCertificate) Unwrap() error {
+	return e.Err
+}
+
+// ErrMissingBinary is returned by NewSogen() when the request or response
+// binary of the SDK is not found.
+type ErrMissingBinary struct {
+	Path  string   // First binary not found
+	Tried []string // All the paths looked for, across platforms
+}
+
+func (e *ErrMissingBinary) Error() string {
+	return "SDK binaries not found, looked for: " + strings.Join(e.Tried, ", ")
+}
+
+// ErrUnsupportedPlatform is returned by NewSogen() when the SDK provides
+// no binaries for the running platform (i.e. windows_amd64, darwin_arm64).
+// The binaries can still run on a supported host with a RemoteExecutor.
+type ErrUnsupportedPlatform struct {
+	Platform  string
+	Available []string // Platforms of the library
+}
+
+func (e *ErrUnsupportedPlatform) Error() string {
+	return fmt.Sprintf("platform %s unsupported, the SDK binaries are only available for %s: use a remote executor (see RemoteExecutor)",
+		e.Platform, strings.Join(e.Available, ", "))
+}
+
+// ErrBadBinary is returned by NewSogen() when the checksum of a binary of
+// the SDK doesn't match the expected one.
+type ErrBadBinary struct {
+	Path     string
+	Checksum string
+	Expected string
+}
+
+func (e *ErrBadBinary) Error() string {
+	return fmt.Sprintf("binary %s: bad checksum %s (expected %s), corrupted or tampered file", e.Path, e.Checksum, e.Expected)
+}
+
+// ErrBadLibraryPath is returned by NewSogen() when the library_path can't
+// be accessed.
+type ErrBadLibraryPath struct {
+	Path string
+	Err  error
+}
+
+func (e *ErrBadLibraryPath) Error() string {
+	return "bad library_path: " + e.Err.Error()
+}
+
+func (e *ErrBadLibraryPath) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,89 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+)
+
+// Bank response codes of a soft decline: the issuer refused the payment
+// because the card holder was not authenticated (Visa/CB 1A, Mastercard
+// 65).
+var softDeclineCodes = map[string]bool{
+	"1A": true,
+	"65": true,
+}
+
+// ErrNotSoftDeclined is returned by RetryTransaction() for payments which
+// were not soft declined.
+var ErrNotSoftDeclined = errors.New("payment not soft declined")
+
+// SoftDeclined reports whether the payment was declined by the issuer for
+// missing strong customer authentication, i.e. after an exemption was
+// refused. It can be tried again with 3-D Secure (see RetryTransaction()).
+func (p *Payment) SoftDeclined() bool {
+	if p.ResponseCode == "00" || p.ResponseCode == "17" {
+		return false
+	}
+	if p.ThreeDS != nil && p.ThreeDS.Status == AuthSuccess {
+		// Already authenticated, authenticating again won't help
+		return false
+	}
+	return softDeclineCodes[p.BankResponseCode]
+}
+
+// RetryTransaction returns a new transaction for a soft declined payment,
+// forcing 3-D Secure: the challenge is mandated and no exemption is
+// requested. The customer, caddie, amount, currency, language and return
+// context of the payment are kept; the transaction must be bound to the
+// session again, if any (see Transaction.BindSession()), and given a new
+// transaction ID if the payment had one. Checkout() then prompts the
+// buyer again.
+func (s *Sogen) RetryTransaction(p *Payment) (*Transaction, error) {
+	if p == nil {
+		return nil, errors.New("nil payment")
+	}
+	if !p.SoftDeclined() {
+		return nil, ErrNotSoftDeclined
+	}
+	t, err := NewTransaction(&Customer{
+		Id:        p.CustomerId,
+		Caddie:    p.Caddie,
+		IpAddress: p.CustomerIpAddress,
+		Email:     p.CustomerEmail,
+	}, p.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if p.CurrencyCode != "" && p.CurrencyCode != s.config.MerchantCurrencyCode {
+		t.currencyCode = p.CurrencyCode
+	}
+	t.language = p.Language
+	_, t.returnCtx, _ = splitReturnContext(p.ReturnContext)
+	t.challenge = ChallengeMandated
+	return t, nil
+}
+
+type softDeclineHook func(p *Payment, retry *Transaction)
+
+// OnSoftDecline registers a function called with every soft declined
+// payment (see SoftDeclined()) and the transaction to try it again with
+// 3-D Secure, i.e. so that the storefront prompts the buyer again with
+// Checkout(). The OnPaymentDeclined hooks are called too.
+func (s *Sogen) OnSoftDecline(fn func(p *Payment, retry *Transaction)) {
+	s.onSoftDecline = append(s.onSoftDecline, fn)
+}
+
+// softDeclineEvents fires the OnSoftDecline hooks of a payment.
+func (s *Sogen) softDeclineEvents(p *Payment) {
+	t, err := s.RetryTransaction(p)
+	if err != nil {
+		s.config.logf(LogWarning, "Warning: retry of soft declined transaction %s: %s", p.TransactionId, err.Error())
+		return
+	}
+	for _, fn := range s.onSoftDecline {
+		fn(p, t)
+	}
+}
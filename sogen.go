@@ -24,7 +24,11 @@
 //
 // Initiate a payment with:
 // 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-// 		t := sogenactif.NewTransaction(&sogenactif.Customer{Id: "funkyab"}, 4.99)
+// 		t, err := sogenactif.NewTransaction(&sogenactif.Customer{Id: "funkyab"}, 4.99)
+// 		if err != nil {
+// 			http.Error(w, err.Error(), http.StatusBadRequest)
+// 			return
+// 		}
 // 		fmt.Fprintf(w, "<html><body>")
 // 		sogen.Checkout(t, w) // Will add credit card logos and a link to the secure payment server
 // 		fmt.Fprintf(w, "</body></html>")
@@ -52,47 +56,133 @@
 package sogenactif
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
-	"path"
-	"runtime"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Sogen holds information for the Sogenactif platform.
 type Sogen struct {
-	config               *Config // Config file
-	requestFile          string  // Path to request (proprietary) binary
-	responseFile         string  // Path to response (proprietary) binary
-	merchantBaseDir      string  // maps to merchant/marchant_id
-	certificatePrefix    string  // Merchant certificate prefix
-	parametersPrefix     string  // Merchant parameters file prefix
-	parametersSogenActif string  // Merchant parameters file sogenactif
-	pathFile             string  // pathfile name
+	config               *Config            // Config file
+	requestFile          string             // Path to request (proprietary) binary
+	responseFile         string             // Path to response (proprietary) binary
+	merchantBaseDir      string             // maps to merchant/marchant_id
+	certificatePrefix    string             // Merchant certificate prefix
+	parametersPrefix     string             // Merchant parameters file prefix
+	parametersSogenActif string             // Merchant parameters file sogenactif
+	pathFile             string             // pathfile name
+	aliases              AliasStore         // Card aliases (wallet), if any
+	payments             PaymentStore       // Payments and operations, if any
+	transactions         TransactionStore   // Pending transactions, if any
+	disputes             DisputeStore       // Chargebacks, if any
+	templates            *template.Template // Custom pages, if any
+	operator             Operator           // Server-to-server operations, if any
+	autoResponseIPs      *IPAllowlist       // Allowed sources of auto responses, if any
+	proxies              []*net.IPNet       // Trusted reverse proxies
+	limiter              *RateLimiter       // Rate limiter of payment endpoints, if any
+	sessionKey           []byte             // Key signing session states, if any
+	fraud                FraudPolicy        // Fraud policy, if any
+	acceptance           AcceptancePolicy   // Acceptance policy, if any
+	rates                RateProvider       // Exchange rates of indicative prices, if any
+	onReview             []func(*Payment)   // OnReviewRequired hooks
+	onAccepted           []func(*Payment)   // OnPaymentAccepted hooks
+	onDeclined           []func(*Payment)   // OnPaymentDeclined hooks
+	onRejected           []func(*Payment)   // OnPaymentRejected hooks
+	onCancelled          []func(*Payment)   // OnPaymentCancelled hooks
+	onRefunded           []refundHook       // OnPaymentRefunded hooks
+	onAbandoned          []abandonedHook    // OnAbandoned hooks
+	onDisputed           []disputeHook      // OnPaymentDisputed hooks
+	onSoftDecline        []softDeclineHook  // OnSoftDecline hooks
+	auditor              Auditor            // Audit trail, if any
+	generated            []string           // Files written by NewSogen()
+	platform             string             // Platform directory of the binaries
+	opsMu                sync.Mutex         // Serializes server-to-server operations
+	recent               recentResponses    // Processed responses, without a transaction store
+	debugWriter          io.Writer          // Debug output of the binaries, if not in pages
 }
 
 // Config holds attributes required by the platform.
 type Config struct {
 	Debug                bool
-	LogoPath             string
+	Environment          string // EnvTest or EnvProduction, if set
+	LogoPath             string // URL path of the media files
 	LibraryPath          string // Path to the provided closed-source binaries
+	LibraryPlatform      string // Platform directory of the binaries (i.e. linux_386), detected if empty
 	MerchantsRootDir     string // maps to merchant/
-	MediaPath            string // Path to static files (credit cards logos etc.)
+	MediaPath            string // Path to static files (credit cards logos etc.), embedded ones if empty
 	MerchantId           string // Merchant Id
 	MerchantCountry      string // Merchant country
 	MerchantCurrencyCode string // Merchant currency code
 	AutoResponseUrl      *url.URL
 	CancelUrl            *url.URL
 	ReturnUrl            *url.URL
+	PaymentLinkUrl       *url.URL // Base URL of payment links (optional)
+	BaseUrl              *url.URL // Public URL of the server, resolving the relative URLs (optional)
+	AutoResponseIPs      []string // Addresses allowed to post to the auto response URL (optional)
+	TrustedProxies       []string // Reverse proxies whose X-Forwarded-* headers are trusted
+	WebhookUrl           *url.URL // Chat webhook notified of payment outcomes (optional)
+	RetentionDays        int      // Days before personal data of payments are anonymized, if not 0
+	MinAmount            float64  // Minimum amount of a transaction, none if 0
+	MaxAmount            float64  // Maximum amount of a transaction, none if 0
+	// Merchants of the [merchant "<id>"] sections of the config file. See
+	// NewRegistry().
+	Merchants []MerchantConfig
+	// Exchange rates from MerchantCurrencyCode of the [fx_rates] section
+	// of the config file, by currency code (see SetRateProvider()).
+	FXRates map[string]float64
+	// Payment means of the [payment_mean "<code>"] sections of the config
+	// file, registered by NewSogen() (see RegisterPaymentMean()).
+	CustomMeans []PaymentMean
+	// Source of the merchant certificate, written to the merchant directory
+	// by NewSogen(). If nil, the certificate file must already be there.
+	CertificateSource CertificateSource
+	// Object store synced to MerchantsRootDir by NewSogen() (optional). See
+	// SyncMerchantsDir().
+	MerchantsStore ObjectStore
+	// SHA-256 checksums of the binaries, keyed by <platform>/<name> (i.e.
+	// linux_amd64/request), for binaries not in BinaryChecksums.
+	BinaryChecksums map[string]string
+	// DryRun makes NewSogen() only validate the config and the merchant
+	// setup, without writing any file. The returned Sogen can't be used.
+	DryRun bool
+	// CheckUrls makes NewSogen() check that the cancel, return and auto
+	// response URLs are reachable and served over HTTPS (see CheckURLs()).
+	// Failures are only logged.
+	CheckUrls bool
+	// Clock is the source of the current time (optional), i.e. a FixedClock
+	// in tests. Defaults to the system clock.
+	Clock Clock
+	// Executor runs the request and response binaries (optional), i.e. a
+	// stub in tests. Defaults to LocalExecutor.
+	Executor Executor
+	// Logger receives the messages of the library (optional), i.e. the
+	// logger of the application. Defaults to the standard logger.
+	Logger *log.Logger
+	// LogLevel is the minimum level of the messages logged, LogInfo by
+	// default. LogSilent logs nothing.
+	LogLevel LogLevel
+	// Locker serializes the generation of the merchant files and the
+	// allocation of transaction IDs among instances sharing
+	// MerchantsRootDir (optional). Defaults to a FileLocker on
+	// MerchantsRootDir. Other lockers must be a Sequencer to allocate
+	// transaction IDs (see Sogen.NextTransactionId()).
+	Locker Locker
+	// Location is the timezone of the dates sent by the payment server,
+	// DefaultTimezone if nil.
+	Location *time.Location
 	// Custom parameters used to generate parmcom.sogenactif. Default values are
 	// provided when calling LoadConfig(). Just override some of them before calling
 	// NewSogen().
@@ -117,7 +207,8 @@ type Customer struct {
 	// successful or cancelled payment.
 	Id string
 	// Caddie is a free field which is sent back unmodified after a successful
-	// payment. It can contains up to 2048 chars.
+	// payment. It can contains up to 2048 chars, without any ! separator.
+	// See Caddie.FromJSON to send structured data.
 	Caddie string
 	// CancelUrl can be provided to override the cancel_url variable in the config
 	// file, on a per-customer basis.
@@ -130,11 +221,40 @@ type Customer struct {
 	AutomaticUrl *url.URL
 	// Merchant custom data. Can be used to pass custom CSS url to the request
 	Data string
+	// IP address of the customer (optional), sent back unmodified. Used by
+	// fraud policies.
+	IpAddress string
+	// Email of the customer (optional), sent back unmodified.
+	Email string
 }
 
 type Transaction struct {
-	customer *Customer
-	amount   float64
+	customer     *Customer
+	amount       float64
+	installments *Installments // Payment in N times, if any
+	createAlias  bool          // Store the card in the customer's wallet
+	alias        string        // Wallet alias used to pay, if any
+	captureMode  CaptureMode   // Capture mode, if any
+	captureDay   int           // Days before capture, if a capture mode is set
+	orderChannel OrderChannel  // Order channel, if any
+	means        string        // Payment means offered, if not the config's ones
+	wallet       WalletOptions // Digital wallet (i.e. Paylib), if any
+	display      string        // Currency of the indicative price, if any
+	quote        *FXQuote      // Indicative price of the last checkout, if any
+	challenge    ChallengeMode // 3-D Secure challenge preference, if any
+	exemption    Exemption     // Requested SCA exemption, if any
+	orderId      string        // Order ID, if any
+	session      string        // Bound session ID, if any
+	requestId    string        // ID of the checkout request, if any
+	currencyCode string        // Currency, if not the merchant's one
+	language     string        // Language of the payment pages, if any
+	returnCtx    string        // Encoded return context, if any
+	transId      string        // Transaction ID, set by the payment server if empty
+	cancelUrl    *url.URL      // Cancel URL of the transaction, if any
+	returnUrl    *url.URL      // Return URL of the transaction, if any
+	autoUrl      *url.URL      // Auto response URL of the transaction, if any
+	returnQuery  url.Values    // Added to the cancel and return URLs, if any
+	sentParams   []string      // Redacted parameters of the last checkout, if any
 }
 
 // Payment holds data filled (and returned) by the secure payment server.
@@ -159,11 +279,20 @@ type Payment struct {
 	MerchantLanguage, Language           string
 	CustomerId                           string
 	CustomerEmail, CustomerIpAddress     string
-	CaptureDay, CaptureMode              string
+	CaptureDay                           int         // Days before the transaction is sent to the bank
+	CaptureMode                          CaptureMode // Empty for the default mode
 	Data                                 string
 	OrderValidity                        string
-	ScoreValue, ScoreColor, ScoreInfo    string
-	ScoreThreshold, ScoreProfile         string
+	ScoreValue, ScoreThreshold           float64
+	ScoreColor                           ScoreColor
+	ScoreInfo, ScoreProfile              string
+	Installments                         []Installment // Payment schedule of a payment in N times
+	CardAlias                            string        // Wallet alias of the card, if any
+	Wallet                               WalletOptions // Digital wallet used to pay, if any
+	Details                              MeanDetails   // Details specific to the payment mean, if any
+	ThreeDS                              *ThreeDS      // 3-D Secure indicators, if any
+	Fraud                                *Verdict      // Verdict of the fraud policy, if any
+	Rejection                            *Rejection    // Rejection of the acceptance policy, if any
 }
 
 func (p *Payment) String() string {
@@ -198,15 +327,15 @@ Customer ID: %s
 Customer Email: %s
 Customer IP Address: %s
 ----------------------------------------
-Capture Day: %s
+Capture Day: %d
 Capture Mode: %s
 Data: %s
 Order Validity: %s
 ----------------------------------------
-Score Value: %s
+Score Value: %g
 Score Color: %s
 Score Info: %s
-Score Threshold: %s
+Score Threshold: %g
 Score Profile: %s`,
 		p.MerchantId, p.MerchantCountry, p.Amount, p.TransactionId, p.PaymentMeans, p.TransmissionDate,
 		p.PaymentDate, p.PaymentCertificate, p.ResponseCode, p.AuthorizationId, p.CurrencyCode, p.CardNumber,
@@ -221,7 +350,7 @@ func (s *Sogen) requestParams(t *Transaction) []string {
 	params := map[string]string{
 		"merchant_id":      s.config.MerchantId,
 		"merchant_country": s.config.MerchantCountry,
-		"amount":           strconv.Itoa(int(t.amount * 100)),
+		"amount":           strconv.FormatInt(toCents(t.amount), 10),
 		"currency_code":    s.config.MerchantCurrencyCode,
 		"pathfile":         s.pathFile,
 		"caddie":           t.customer.Caddie,
@@ -229,17 +358,65 @@ func (s *Sogen) requestParams(t *Transaction) []string {
 	if t.customer.Id != "" {
 		params["customer_id"] = t.customer.Id
 	}
-	if t.customer.CancelUrl != nil {
-		params["cancel_return_url"] = t.customer.CancelUrl.String()
+	if u := t.transactionUrl(t.cancelUrl, t.customer.CancelUrl, s.config.CancelUrl, true); u != "" {
+		params["cancel_return_url"] = u
 	}
-	if t.customer.ReturnUrl != nil {
-		params["normal_return_url"] = t.customer.ReturnUrl.String()
+	if u := t.transactionUrl(t.returnUrl, t.customer.ReturnUrl, s.config.ReturnUrl, true); u != "" {
+		params["normal_return_url"] = u
 	}
-	if t.customer.AutomaticUrl != nil {
-		params["automatic_response_url"] = t.customer.AutomaticUrl.String()
+	if u := t.transactionUrl(t.autoUrl, t.customer.AutomaticUrl, s.config.AutoResponseUrl, false); u != "" {
+		params["automatic_response_url"] = u
 	}
+	if t.transId != "" {
+		params["transaction_id"] = t.transId
+	}
+	if t.customer.IpAddress != "" {
+		params["customer_ip_address"] = t.customer.IpAddress
+	}
+	if t.customer.Email != "" {
+		params["customer_email"] = t.customer.Email
+	}
+	data := make([]string, 0)
 	if t.customer.Data != "" {
-		params["data"] = t.customer.Data
+		data = append(data, t.customer.Data)
+	}
+	if t.orderId != "" {
+		params["order_id"] = t.orderId
+	}
+	if t.currencyCode != "" {
+		params["currency_code"] = t.currencyCode
+	}
+	if t.language != "" {
+		params["language"] = t.language
+	}
+	if t.means != "" {
+		params["payment_means"] = t.means
+	}
+	if t.orderChannel != "" {
+		params["order_channel"] = string(t.orderChannel)
+	}
+	if rc := s.returnContext(t); rc != "" {
+		params["return_context"] = rc
+	}
+	if t.captureMode != "" {
+		params["capture_mode"] = string(t.captureMode)
+		params["capture_day"] = strconv.Itoa(t.captureDay)
+	}
+	if t.installments != nil {
+		params["capture_mode"] = captureModePaymentN
+		data = append(data, t.installments.directives())
+	}
+	if w := t.walletDirectives(); w != "" {
+		data = append(data, w)
+	}
+	if w := t.wallet.directives(); w != "" {
+		data = append(data, w)
+	}
+	if d := t.threeDSDirectives(); d != "" {
+		data = append(data, d)
+	}
+	if len(data) > 0 {
+		params["data"] = strings.Join(data, ";")
 	}
 	plist := make([]string, 0)
 	for k, v := range params {
@@ -249,209 +426,346 @@ func (s *Sogen) requestParams(t *Transaction) []string {
 }
 
 // NewTransaction creates a new transaction for a customer that can be
-// used to checkout. A nil customer or a null amount returns a nil
-// transaction.
-func NewTransaction(c *Customer, amount float64) *Transaction {
-	if c == nil || amount == 0 {
-		return nil
+// used to checkout. It fails for a nil customer, an invalid caddie (see
+// ValidateCaddie()) or an amount which is not positive. The amount limits
+// of the merchant are checked by Sogen.NewTransaction() and at checkout.
+func NewTransaction(c *Customer, amount float64) (*Transaction, error) {
+	if c == nil {
+		return nil, errors.New("nil customer")
+	}
+	if err := validateAmount(amount); err != nil {
+		return nil, err
+	}
+	if err := ValidateCaddie(c.Caddie); err != nil {
+		return nil, err
 	}
-	return &Transaction{customer: c, amount: amount}
+	return &Transaction{customer: c, amount: amount}, nil
 }
 
 // NewSogen sets up all the files required by the Sogen API for
 // a giver merchant.
 func NewSogen(c *Config) (*Sogen, error) {
 	if c == nil {
-		return nil, errors.New("can't initialize Sogen framework: nil config.")
+		return nil, ErrNilConfig
 	}
 	c.MerchantId = strings.Trim(c.MerchantId, " ")
 	if c.MerchantId == "" {
-		return nil, errors.New("missing merchant ID")
+		return nil, ErrMissingMerchantId
 	}
 	c.MerchantsRootDir = strings.Trim(c.MerchantsRootDir, " ")
 	if c.MerchantsRootDir == "" {
-		return nil, errors.New("missing merchant root directory (for config files and certificates)")
+		return nil, ErrMissingMerchantsRootDir
 	}
-	if _, err := os.Stat(c.LibraryPath); err != nil {
-		return nil, errors.New("bad library_path: " + err.Error())
+	if _, err := os.Stat(c.LibraryPath); err != nil && c.localExec() {
+		return nil, &ErrBadLibraryPath{c.LibraryPath, err}
 	}
 
-	log.Printf("Initializing the Sogenactif payment system (%s)", c.MerchantId)
+	c.logf(LogInfo, "Initializing the Sogenactif payment system (%s)", redact(c.MerchantId))
 	s := new(Sogen)
 	s.config = c
-	s.merchantBaseDir = path.Join(c.MerchantsRootDir, c.MerchantId)
-	s.certificatePrefix = path.Join(s.merchantBaseDir, "certif")
-	s.parametersPrefix = path.Join(s.merchantBaseDir, "parcom")
-	s.parametersSogenActif = path.Join(s.merchantBaseDir, "parcom.sogenactif")
-	s.pathFile = path.Join(s.merchantBaseDir, "pathfile")
-	s.requestFile = path.Join(c.LibraryPath, runtime.GOOS+"_"+runtime.GOARCH, "request")
-	s.responseFile = path.Join(c.LibraryPath, runtime.GOOS+"_"+runtime.GOARCH, "response")
-	if _, err := os.Stat(s.requestFile); err != nil {
-		return nil, errors.New("request binary: " + err.Error())
-	}
-	if _, err := os.Stat(s.responseFile); err != nil {
-		return nil, errors.New("request binary: " + err.Error())
+	s.merchantBaseDir = filepath.Join(c.MerchantsRootDir, c.MerchantId)
+	s.certificatePrefix = filepath.Join(s.merchantBaseDir, "certif")
+	s.parametersPrefix = filepath.Join(s.merchantBaseDir, "parcom")
+	s.parametersSogenActif = filepath.Join(s.merchantBaseDir, "parcom.sogenactif")
+	s.pathFile = filepath.Join(s.merchantBaseDir, "pathfile")
+	if len(c.FXRates) > 0 {
+		rates := make(StaticRates)
+		for code, rate := range c.FXRates {
+			rates[c.MerchantCurrencyCode+"/"+code] = rate
+		}
+		s.rates = rates
+	}
+	platform := c.LibraryPlatform
+	var err error
+	if c.localExec() {
+		if platform, err = resolvePlatform(c); err != nil {
+			return nil, err
+		}
+		c.logf(LogInfo, "Using the %s binaries", platform)
+	} else {
+		// The binaries run elsewhere, i.e. on a Linux host with a
+		// RemoteExecutor
+		c.logf(LogInfo, "Running the binaries with %T", c.Executor)
+	}
+	s.platform = platform
+	s.requestFile = filepath.Join(c.LibraryPath, platform, "request")
+	s.responseFile = filepath.Join(c.LibraryPath, platform, "response")
+	if c.localExec() {
+		if err := verifyBinary(c, platform, "request", s.requestFile); err != nil {
+			return nil, err
+		}
+		if err := verifyBinary(c, platform, "response", s.responseFile); err != nil {
+			return nil, err
+		}
 	}
 
-	if _, err := os.Stat(s.merchantBaseDir); err != nil {
-		return nil, errors.New(fmt.Sprintf("missing certificate file in directory %s", s.merchantBaseDir))
+	proxies, err := parseRanges(c.TrustedProxies)
+	if err != nil {
+		return nil, errors.New("bad trusted_proxies: " + err.Error())
 	}
-	certFile := fmt.Sprintf("%s.%s.%s.php", s.certificatePrefix, c.MerchantCountry, c.MerchantId)
-	if _, err := os.Stat(certFile); err != nil {
-		return nil, errors.New(fmt.Sprintf("missing certificate file %s", certFile))
+	s.proxies = proxies
+	if len(c.AutoResponseIPs) > 0 {
+		a, err := NewIPAllowlist(c.AutoResponseIPs...)
+		if err != nil {
+			return nil, errors.New("bad auto_response_ips: " + err.Error())
+		}
+		a.proxies = s.proxies
+		a.config = c
+		s.autoResponseIPs = a
 	}
-	log.Printf("Found certificate file %s", certFile)
 
-	// Write pathfile
-	f, err := os.Create(s.pathFile)
-	defer f.Close()
-	if err != nil {
+	if err := c.registerMeans(); err != nil {
 		return nil, err
 	}
-	debug := "NO"
-	if s.config.Debug {
-		debug = "YES"
-	}
-	_, err = f.Write([]byte(fmt.Sprintf(`DEBUG!%s!
-D_LOGO!%s!
-F_CERTIFICATE!%s!
-F_CTYPE!php!
-F_PARAM!%s!
-F_DEFAULT!%s!
-`, debug, s.config.LogoPath, s.certificatePrefix, s.parametersPrefix, s.parametersSogenActif)))
-	if err != nil {
-		return nil, err
+	checkPaymentMeans(c)
+	if c.DryRun {
+		return s, checkCertificate(c)
 	}
-	log.Printf("Created file %s", s.pathFile)
-
-	// Write parmcom.merchant_id
-	parmcom := fmt.Sprintf("%s.%s", s.parametersPrefix, c.MerchantId)
-	f, err = os.Create(parmcom)
-	defer f.Close()
+	unlock, err := c.lock("merchant-" + c.MerchantId)
 	if err != nil {
 		return nil, err
 	}
-	_, err = f.Write([]byte(fmt.Sprintf(`LOGO!/bf/chrome/common/logo.png!
-CANCEL_URL!%s!
-RETURN_URL!%s!
-`, s.config.CancelUrl, s.config.ReturnUrl)))
-	// auto_response_url config parameter is optional
-	if s.config.AutoResponseUrl != nil {
-		_, err = f.Write([]byte(fmt.Sprintf("AUTO_RESPONSE_URL!%s!\n", s.config.AutoResponseUrl)))
+	defer unlock()
+	if c.MerchantsStore != nil {
+		n, err := syncDir(context.Background(), c.MerchantsStore, c.MerchantsRootDir)
+		if err != nil {
+			return nil, err
+		}
+		c.logf(LogInfo, "Synced %d files to %s", n, c.MerchantsRootDir)
 	}
-	if err != nil {
+	certFile := certificateFile(c)
+	if c.CertificateSource != nil {
+		if err := writeCertificate(c.CertificateSource, certFile); err != nil {
+			return nil, err
+		}
+		c.logf(LogInfo, "Wrote certificate file %s", certFile)
+	} else if certFile, err = resolveCertificate(c); err != nil {
 		return nil, err
 	}
-	log.Printf("Created file %s", parmcom)
-
-	// Write parmcom.sogenactif
-	f, err = os.Create(s.parametersSogenActif)
-	defer f.Close()
+	data, err := ioutil.ReadFile(certFile)
 	if err != nil {
-		return nil, err
+		return nil, &ErrMissingCertificate{Path: certFile, Err: err}
 	}
-	mpars := map[string]string{
-		"ADVERT":            s.config.Advert,
-		"BGCOLOR":           s.config.BgColor,
-		"BLOCK_ALIGN":       s.config.BlockAlign,
-		"BLOCK_ORDER":       s.config.BlockOrder,
-		"CONDITION":         s.config.Condition,
-		"CURRENCY":          strconv.FormatInt(int64(s.config.Currency), 10),
-		"LOGO2":             s.config.Logo2,
-		"PAYMENT_MEANS":     s.config.PaymentMeans,
-		"TARGET":            s.config.Target,
-		"TEXTCOLOR":         s.config.TextColor,
-		"LANGUAGE":          s.config.MerchantCountry,
-		"MERCHANT_COUNTRY":  s.config.MerchantCountry,
-		"MERCHANT_LANGUAGE": s.config.MerchantCountry,
-	}
-	if s.config.HeaderFlag {
-		mpars["HEADER_FLAG"] = "yes"
-	} else {
-		mpars["HEADER_FLAG"] = "no"
+	if err := verifyCertificate(c, data); err != nil {
+		return nil, errors.New(certFile + ": " + err.Error())
 	}
-	for k, v := range mpars {
-		_, err = f.Write([]byte(fmt.Sprintf("%s!%s!\n", k, v)))
-		if err != nil {
+	c.logf(LogInfo, "Found certificate file %s", certFile)
+	if expected := certificateFile(c); certFile != expected {
+		if err := linkCertificate(certFile, expected); err != nil {
+			return nil, err
+		}
+		c.logf(LogInfo, "Linked certificate file %s to %s", expected, certFile)
+		s.generated = append(s.generated, expected)
+	}
+
+	for _, f := range s.merchantFiles() {
+		if err := ioutil.WriteFile(f.path, f.data, 0666); err != nil {
 			return nil, err
 		}
+		c.logf(LogInfo, "Created file %s", f.path)
+		s.generated = append(s.generated, f.path)
 	}
-	log.Printf("Created file %s", s.parametersSogenActif)
 
+	if c.CheckUrls {
+		checkUrls(c)
+	}
 	return s, nil
 }
 
+// request runs the request binary for a transaction and returns the
+// generated HTML form and the debug info (if DEBUG is set to YES). The
+// checkout is recorded in the audit trail.
+func (s *Sogen) request(t *Transaction) (string, string, error) {
+	body, sogerr, err := s.runRequest(t)
+	details := auditTransaction(t)
+	if t.requestId != "" {
+		details["request_id"] = t.requestId
+	}
+	if err != nil {
+		details["error"] = err.Error()
+		s.logRequest(t.requestId, "checkout of customer %s: %s", t.customer.Id, err.Error())
+	}
+	s.Audit(AuditCheckout, details)
+	return body, sogerr, err
+}
+
+func (s *Sogen) runRequest(t *Transaction) (string, string, error) {
+	// The caddie may have changed since NewTransaction()
+	if err := ValidateCaddie(t.customer.Caddie); err != nil {
+		return "", "", err
+	}
+	if err := s.checkAmount(t.amount); err != nil {
+		return "", "", err
+	}
+	if s.fraud != nil {
+		v, err := s.fraud.CheckTransaction(t)
+		if err != nil {
+			return "", "", err
+		}
+		if v.Decision == Deny {
+			return "", "", &FraudError{v}
+		}
+	}
+	if err := s.checkExemption(t); err != nil {
+		return "", "", err
+	}
+	s.quote(t)
+	// Execute binary
+	params := s.requestParams(t)
+	s.recordParams(t, params)
+	res, err := runBinary(s.config.executor(), "request", s.requestFile, 1, params...)
+	if err != nil {
+		return "", "", err
+	}
+	return res.Fields[0], res.Error, nil
+}
+
 // Checkout generates an HTML form suitable to redirect the buyer
 // to the payment server.
 func (s *Sogen) Checkout(t *Transaction, w io.Writer) error {
-	// Execute binary
-	cmd := exec.Command(s.requestFile, s.requestParams(t)...)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	if err := cmd.Run(); err != nil {
+	body, sogerr, err := s.request(t)
+	if err != nil {
 		return err
 	}
-	res := strings.Split(out.String(), "!")
-	code, sogerr, body := res[1], res[2], res[3]
-	if code == "" && sogerr == "" {
-		return errors.New("error: request executable not found!")
-	} else if code != "0" {
-		return errors.New(fmt.Sprintf("error using API (error code %s)", sogerr))
-	}
 	// No error; sogerr may hold debug info if DEBUG is set to YES
-	fmt.Fprintf(w, sogerr)
-	fmt.Fprintf(w, body)
+	sogerr = s.pageDebug(sogerr)
+	if tmpl := s.template(TemplateCheckout); tmpl != nil {
+		return executeIn(w, tmpl, s.pageLanguage(t.language), s.summaryData(t, body, sogerr))
+	}
+	io.WriteString(w, sogerr)
+	if t.quote != nil {
+		lang := s.pageLanguage(t.language)
+		fmt.Fprintf(w, "<p class=\"sogen-fx\">%s</p>\n", template.HTMLEscapeString(fmt.Sprintf(Translate(lang, "Indicative price: %s"), t.quote.Format(lang))))
+	}
+	io.WriteString(w, body)
 	return nil
 }
 
-func formatToRFC3339(dt, offset string) (time.Time, error) {
-	format := "%s-%s-%sT%s:%s:%s" + offset
-	rfc, err := time.Parse(time.RFC3339, fmt.Sprintf(format, dt[:4], dt[4:6], dt[6:8], dt[8:10],
-		dt[10:12], dt[12:14]))
-	return rfc, err
-}
-
 // HandlePayment generates a payment from the Sogen's server
-// response.
+// response. The DATA field is either posted or sent in the query string;
+// other methods fail with ErrMethodNotAllowed.
 func (s *Sogen) HandlePayment(w io.Writer, r *http.Request) (*Payment, error) {
+	return s.handlePayment(w, r, nil)
+}
+
+// handlePayment generates a payment from a request like HandlePayment().
+// check, if not nil, is called with the payment before it is processed
+// (stored, notified etc.); its error is returned along with the payment.
+func (s *Sogen) handlePayment(w io.Writer, r *http.Request, check func(p *Payment) error) (*Payment, error) {
 	if r == nil {
 		return nil, errors.New("can't handle payment for nil request")
 	}
-	data := r.PostFormValue("DATA")
+	id := RequestId(r.Context())
+	rw, _ := w.(http.ResponseWriter)
+	data, err := responseData(rw, r)
+	if err != nil {
+		s.logRequest(id, "%s request from %s: %s", r.Method, r.RemoteAddr, err.Error())
+		return nil, err
+	}
 	if len(data) == 0 {
-		return nil, errors.New("missing sogen data in request")
+		s.logRequest(id, "missing sogen data in request from %s", r.RemoteAddr)
+		return nil, ErrMissingData
 	}
-	cmd := exec.Command(s.responseFile, "pathfile="+s.pathFile, "message="+data)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	if err := cmd.Run(); err != nil {
+	return s.handleResponse(id, w, data, check)
+}
+
+// ParseResponse generates a payment from the DATA field posted by the
+// Sogen's server. Debug info, if any, is written to w unless a debug
+// writer is set (see SetDebugWriter()).
+func (s *Sogen) ParseResponse(w io.Writer, data string) (*Payment, error) {
+	return s.handleResponse("", w, data, nil)
+}
+
+// DecodeResponse generates a payment from a DATA field like
+// ParseResponse(), without processing it: the payment is neither checked
+// by the fraud policy, stored nor passed to the hooks (i.e. emailed). Use
+// it to inspect a response again.
+func (s *Sogen) DecodeResponse(w io.Writer, data string) (*Payment, error) {
+	return s.decodeResponse(w, data)
+}
+
+// handleResponse parses and processes a response for the request with the
+// given ID and records it in the audit trail. check, if not nil, is called
+// with the payment before it is processed; its error is returned along with
+// the payment.
+func (s *Sogen) handleResponse(id string, w io.Writer, data string, check func(p *Payment) error) (*Payment, error) {
+	details := map[string]string{"data": data}
+	if id != "" {
+		details["request_id"] = id
+	}
+	s.Audit(AuditNotification, details)
+	p, err := s.decodeResponse(w, data)
+	if err == nil && check != nil {
+		if err := check(p); err != nil {
+			s.logRequest(id, "response: %s", err.Error())
+			return p, err
+		}
+	}
+	if err == nil {
+		err = s.processPayment(p)
+	}
+	if err != nil {
+		s.logRequest(id, "response: %s", err.Error())
+		details = map[string]string{"error": err.Error()}
+		if id != "" {
+			details["request_id"] = id
+		}
+		s.Audit(AuditParseError, details)
 		return nil, err
 	}
-	res := strings.Split(out.String(), "!")
-	code, sogerr := res[1], res[2]
+	s.Audit(AuditParsed, auditPayment(p))
+	return p, nil
+}
 
-	if code == "" && sogerr == "" {
-		return nil, errors.New("request executable not found!")
-	} else if code != "0" {
-		return nil, errors.New(fmt.Sprintf("error using API (error code %s)", sogerr))
+// decodeResponse generates a payment from a response with the response
+// binary, without side effects.
+func (s *Sogen) decodeResponse(w io.Writer, data string) (*Payment, error) {
+	if err := validateData(data); err != nil {
+		return nil, err
+	}
+	res, err := runBinary(s.config.executor(), "response", s.responseFile, 0, "pathfile="+s.pathFile, "message="+data)
+	if err != nil {
+		return nil, err
 	}
-	// sogerr holds debug info if DEBUG is set to YES
-	fmt.Fprintf(w, sogerr)
+	// The error field holds debug info if DEBUG is set to YES
+	io.WriteString(w, s.pageDebug(res.Error))
 
-	v := res[3:]
+	v := res.Fields
+	if len(v) < paymentFields {
+		return nil, &ResponseError{ErrMalformedResponse, fmt.Sprintf("%d payment fields, expected %d", len(v), paymentFields)}
+	}
 	amount, err := strconv.ParseFloat(v[2], 32)
 	if err != nil {
-		return nil, errors.New("amount conversion error: " + err.Error())
+		return nil, &ResponseError{ErrMalformedResponse, "amount: " + err.Error()}
 	}
 	amount /= 100
+	if err := validateAmount(amount); err != nil {
+		return nil, &ResponseError{ErrMalformedResponse, err.Error()}
+	}
+
+	tDate, err := parseDate(v[5], s.config.location())
+	if err != nil {
+		return nil, &ResponseError{ErrMalformedResponse, "transmission date: " + err.Error()}
+	}
+	pDateTime, err := parseDate(v[7]+v[6], s.config.location())
+	if err != nil {
+		return nil, &ResponseError{ErrMalformedResponse, "payment datetime: " + err.Error()}
+	}
+
+	captureDay := 0
+	if v[26] != "" {
+		if captureDay, err = strconv.Atoi(v[26]); err != nil {
+			return nil, &ResponseError{ErrMalformedResponse, "capture day: " + err.Error()}
+		}
+	}
 
-	tDate, err := formatToRFC3339(v[5], "+01:00")
+	scoreValue, err := parseScore(v[30])
 	if err != nil {
-		return nil, errors.New("transmission date conversion error: " + err.Error())
+		return nil, &ResponseError{ErrMalformedResponse, "score value: " + err.Error()}
 	}
-	pDateTime, err := formatToRFC3339(v[7]+v[6], "+01:00")
+	scoreThreshold, err := parseScore(v[33])
 	if err != nil {
-		return nil, errors.New("payment datetime conversion error: " + err.Error())
+		return nil, &ResponseError{ErrMalformedResponse, "score threshold: " + err.Error()}
 	}
 
 	p := Payment{
@@ -480,15 +794,57 @@ func (s *Sogen) HandlePayment(w io.Writer, r *http.Request) (*Payment, error) {
 		CustomerId:         v[23],
 		CustomerEmail:      v[24],
 		CustomerIpAddress:  v[25],
-		CaptureDay:         v[26],
-		CaptureMode:        v[27],
+		CaptureDay:         captureDay,
+		CaptureMode:        CaptureMode(v[27]),
 		Data:               v[28],
 		OrderValidity:      v[29],
-		ScoreValue:         v[30],
-		ScoreColor:         v[31],
+		ScoreValue:         scoreValue,
+		ScoreColor:         ScoreColor(v[31]),
 		ScoreInfo:          v[32],
-		ScoreThreshold:     v[33],
+		ScoreThreshold:     scoreThreshold,
 		ScoreProfile:       v[34],
 	}
+	if p.CaptureMode == CapturePaymentN {
+		// The payment is accepted anyway, keep it without its schedule
+		if p.Installments, err = parseInstallments(p.Data); err != nil {
+			s.config.logf(LogWarning, "Warning: installments schedule of transaction %s: %s", p.TransactionId, err.Error())
+		}
+	}
+	p.CardAlias, _ = dataDirective(p.Data, walletAliasKey)
+	p.Wallet = parseWallet(&p)
+	p.Details = meanDetails(&p)
+	p.ThreeDS = parseThreeDS(p.Data)
 	return &p, nil
 }
+
+// processPayment checks a decoded payment against the fraud and acceptance
+// policies, records it in the stores and fires the hooks.
+func (s *Sogen) processPayment(p *Payment) error {
+	var err error
+	if s.fraud != nil {
+		if p.Fraud, err = s.fraud.CheckPayment(p); err != nil {
+			return errors.New("fraud check error: " + err.Error())
+		}
+	}
+	if err := s.saveAlias(p); err != nil {
+		return errors.New("wallet: " + err.Error())
+	}
+	if s.payments != nil {
+		if err := s.payments.SavePayment(p); err != nil {
+			return errors.New("payment store: " + err.Error())
+		}
+	}
+	if err := s.checkAcceptance(p); err != nil {
+		return errors.New("acceptance policy: " + err.Error())
+	}
+	if err := s.completeTransaction(p); err != nil {
+		return errors.New("transaction store: " + err.Error())
+	}
+	if err := s.reviewRequired(p); err != nil {
+		return errors.New("review: " + err.Error())
+	}
+	if err := s.paymentEvents(p); err != nil {
+		return errors.New("payment events: " + err.Error())
+	}
+	return nil
+}
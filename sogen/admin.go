@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/gotsunami/sogenactif"
+	"github.com/gotsunami/sogenactif/office"
+	"github.com/gotsunami/sogenactif/stats"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// adminParams are the settings of the admin section.
+type adminParams struct {
+	User      string
+	Password  string // The admin section is disabled if empty
+	OfficeUrl string // Gateway of the office server, to make refunds
+}
+
+// officeOperator makes the operations on payments with the experimental
+// office client, whose requests are neither signed nor authenticated: the
+// gateway must be on a trusted network.
+type officeOperator struct {
+	client *office.Client
+}
+
+func (o *officeOperator) Capture(ctx context.Context, transactionId string, paymentDate time.Time, amount int64, currencyCode string) (string, error) {
+	res, err := o.client.Capture(ctx, transactionId, paymentDate, amount, currencyCode)
+	if err != nil {
+		return "", err
+	}
+	return res.NewStatus, nil
+}
+
+func (o *officeOperator) Cancel(ctx context.Context, transactionId string, paymentDate time.Time, amount int64, currencyCode string) (string, error) {
+	res, err := o.client.Cancel(ctx, transactionId, paymentDate, amount, currencyCode)
+	if err != nil {
+		return "", err
+	}
+	return res.NewStatus, nil
+}
+
+func (o *officeOperator) Credit(ctx context.Context, transactionId string, paymentDate time.Time, amount int64, currencyCode string) (string, error) {
+	res, err := o.client.Refund(ctx, transactionId, paymentDate, amount, currencyCode)
+	if err != nil {
+		return "", err
+	}
+	return res.NewStatus, nil
+}
+
+// notification is a notification received from the payment server.
+type notification struct {
+	Time      time.Time
+	RequestId string
+	Data      string
+	Error     string // Parse error, if any
+}
+
+// recentNotifications is an auditor keeping the last notifications
+// received from the payment server.
+type recentNotifications struct {
+	mu    sync.Mutex
+	list  []*notification // Most recent first
+	max   int
+	clock sogenactif.Clock // Time of the notifications, the system time if nil
+}
+
+// setClock sets the clock timing the notifications.
+func (n *recentNotifications) setClock(c sogenactif.Clock) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.clock = c
+}
+
+func (n *recentNotifications) Record(action string, details map[string]string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch action {
+	case sogenactif.AuditNotification:
+		now := time.Now()
+		if n.clock != nil {
+			now = n.clock.Now()
+		}
+		n.list = append([]*notification{{Time: now, RequestId: details["request_id"], Data: details["data"]}}, n.list...)
+		if len(n.list) > n.max {
+			n.list = n.list[:n.max]
+		}
+	case sogenactif.AuditParseError:
+		if len(n.list) > 0 {
+			n.list[0].Error = details["error"]
+		}
+	}
+	return nil
+}
+
+func (n *recentNotifications) recent() []*notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	list := make([]*notification, len(n.list))
+	copy(list, n.list)
+	return list
+}
+
+// basicAuth restricts a handler to the admin user.
+func (a *adminParams) basicAuth(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(a.User)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(a.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="sogen admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin reports whether a form was posted from the admin pages.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// registerAdmin sets up the admin section at /admin: payment statistics,
+// recent notifications and operations on payments.
+func registerAdmin(mux *http.ServeMux, sogen *sogenactif.Sogen, conf *sogenactif.Config, a *app) error {
+	if a.admin.OfficeUrl != "" {
+		u, err := url.Parse(a.admin.OfficeUrl)
+		if err != nil {
+			return err
+		}
+		log.Print("Warning: the office client is experimental, its requests are not signed")
+		sogen.SetOperator(&officeOperator{office.NewClient(conf.MerchantId, conf.MerchantCountry, office.NewHTTPTransport(u))})
+	}
+	a.notifs.setClock(sogen)
+	sogen.SetAuditor(a.notifs)
+
+	admin := http.NewServeMux()
+	admin.HandleFunc("/admin", func(w http.ResponseWriter, r *http.Request) {
+		now := sogen.Now().Add(time.Minute)
+		from := now.AddDate(0, 0, -30)
+		payments, err := a.payments.Payments(from, now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		recent := payments
+		if len(recent) > 20 {
+			recent = recent[len(recent)-20:]
+		}
+		err = adminPage.Execute(w, map[string]interface{}{
+			"Stats":         stats.Compute(payments, from, now, 0),
+			"Payments":      recent,
+			"Notifications": a.notifs.recent(),
+			"Refunds":       a.admin.OfficeUrl != "",
+			"Message":       r.URL.Query().Get("msg"),
+		})
+		if err != nil {
+			log.Print(err)
+		}
+	})
+	admin.HandleFunc("/admin/reparse", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || !sameOrigin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		p, err := sogen.DecodeResponse(ioutil.Discard, r.PostFormValue("data"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(p)
+	})
+	admin.HandleFunc("/admin/status", func(w http.ResponseWriter, r *http.Request) {
+		st, err := sogen.PaymentStatus(r.URL.Query().Get("transaction_id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(map[string]interface{}{
+			"transaction_id": st.TransactionId,
+			"paid":           st.Paid,
+			"captured":       st.Captured,
+			"refunded":       st.Refunded,
+			"payment":        st.Payment,
+		})
+	})
+	admin.HandleFunc("/admin/files", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writeFiles(w, sogen.GeneratedFiles())
+	})
+	admin.HandleFunc("/admin/refund", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || !sameOrigin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		id := r.PostFormValue("transaction_id")
+		if _, err := a.payments.Payment(id); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		// An empty amount refunds everything
+		var cents int64
+		if v := r.PostFormValue("amount"); v != "" {
+			amount, err := strconv.ParseFloat(v, 64)
+			if err != nil || amount <= 0 {
+				http.Error(w, "bad amount", http.StatusBadRequest)
+				return
+			}
+			cents = int64(math.Floor(amount*100 + 0.5))
+		}
+		msg := "Refund done"
+		res, err := sogen.Refund(r.Context(), id, cents)
+		if err != nil {
+			msg = err.Error()
+		} else {
+			if res.Cancelled {
+				msg = "Payment cancelled"
+			}
+			msg += ": " + strconv.FormatInt(res.Amount, 10) + " cents, status " + res.Status
+		}
+		http.Redirect(w, r, "/admin?msg="+url.QueryEscape(msg), http.StatusSeeOther)
+	})
+	mux.Handle("/admin", a.admin.basicAuth(admin))
+	mux.Handle("/admin/", a.admin.basicAuth(admin))
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/gotsunami/sogenactif"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// checkoutRequest is the body of POST /checkouts.
+type checkoutRequest struct {
+	Amount     float64 `json:"amount"`
+	CustomerId string  `json:"customer_id"`
+	Email      string  `json:"email"`
+	Caddie     string  `json:"caddie"`
+	OrderId    string  `json:"order_id"`
+}
+
+// checkoutResponse describes the form to post to the payment server.
+type checkoutResponse struct {
+	Action string            `json:"action"`
+	Fields map[string]string `json:"fields"`
+	Cards  []string          `json:"cards"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiStatus acknowledges a notification of the payment server, without
+// the payment details.
+type apiStatus struct {
+	Status string `json:"status"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("api: %s", err.Error())
+	}
+}
+
+// registerAPI sets up the JSON API routes:
+//
+//	POST /checkouts        returns the form to post to the payment server
+//	POST /payments/notify  handles the DATA sent by the payment server, returns {"status": "ok"}
+//	GET  /payments/{id}    returns a stored payment, to the admin user only
+//	GET  /payments         lists stored payments (see paymentsHandler), to the admin user only
+//	/blocklist             manages blocked customers, email domains and IPs, to the admin user only
+func registerAPI(mux *http.ServeMux, sogen *sogenactif.Sogen, store sogenactif.PaymentStore, blocklist *sogenactif.Blocklist, admin *adminParams) {
+	sogen.SetFraudPolicy(blocklist)
+	if admin != nil && admin.Password != "" {
+		mux.Handle("/blocklist", admin.basicAuth(blocklist.Handler()))
+	}
+	mux.HandleFunc("/checkouts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeJSON(w, http.StatusMethodNotAllowed, &apiError{"method not allowed"})
+			return
+		}
+		var req checkoutRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, &apiError{"bad request: " + err.Error()})
+			return
+		}
+		c := &sogenactif.Customer{Id: req.CustomerId, Caddie: req.Caddie, Email: req.Email}
+		if ip := sogen.ClientIP(r); ip != nil {
+			c.IpAddress = ip.String()
+		}
+		t, err := sogen.NewTransaction(c, req.Amount)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, &apiError{err.Error()})
+			return
+		}
+		if err := sogen.ResolveURLs(t, r); err != nil {
+			writeJSON(w, http.StatusInternalServerError, &apiError{err.Error()})
+			return
+		}
+		if req.OrderId != "" {
+			if err := t.SetOrderId(req.OrderId); err != nil {
+				writeJSON(w, http.StatusBadRequest, &apiError{err.Error()})
+				return
+			}
+		}
+		f, err := sogen.CheckoutForm(t)
+		if _, ok := err.(*sogenactif.FraudError); ok {
+			writeJSON(w, http.StatusForbidden, &apiError{err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, &apiError{err.Error()})
+			return
+		}
+		res := &checkoutResponse{Action: f.Action, Fields: f.Fields, Cards: make([]string, 0)}
+		for _, c := range f.Cards {
+			res.Cards = append(res.Cards, c.Name)
+		}
+		writeJSON(w, http.StatusCreated, res)
+	})
+	payment := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := store.Payment(strings.TrimPrefix(r.URL.Path, "/payments/"))
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, &apiError{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	})
+	mux.HandleFunc("/payments/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/payments/")
+		switch {
+		case id == "notify" && r.Method == "POST":
+			// HandlePayment saves the payment to the store
+			if _, err := sogen.HandlePayment(ioutil.Discard, r); err != nil {
+				writeJSON(w, http.StatusBadRequest, &apiError{err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, &apiStatus{"ok"})
+		case id != "" && id != "notify" && r.Method == "GET" && admin != nil && admin.Password != "":
+			admin.basicAuth(payment).ServeHTTP(w, r)
+		default:
+			writeJSON(w, http.StatusNotFound, &apiError{"not found"})
+		}
+	})
+}
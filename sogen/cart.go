@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cartCookie holds the cart of the demo shop.
+const cartCookie = "sogen_cart"
+
+// product is an item of the catalog of the demo shop.
+type product struct {
+	Id    string
+	Name  string
+	Price int64 // In cents
+}
+
+// loadCatalog reads the products of the [demo] section of the settings
+// file, given as <id>=<price> <name> options. A single product priced at
+// amount is returned if there is none.
+func loadCatalog(confPath string, amount float64) ([]*product, error) {
+	catalog := make([]*product, 0)
+	c := &sogenactif.ConfigFile{}
+	if confPath != "" {
+		var err error
+		if c, err = sogenactif.ReadConfigFile(confPath); err != nil {
+			return nil, err
+		}
+	}
+	if c.HasSection("demo") {
+		opts, err := c.Options("demo")
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(opts)
+		for _, id := range opts {
+			v, err := c.String("demo", id)
+			if err != nil {
+				return nil, err
+			}
+			fields := strings.SplitN(strings.TrimSpace(v), " ", 2)
+			price, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil || price <= 0 || len(fields) != 2 {
+				return nil, errors.New(fmt.Sprintf("demo product %s: expected <price> <name>", id))
+			}
+			catalog = append(catalog, &product{Id: id, Name: strings.TrimSpace(fields[1]), Price: int64(price*100 + 0.5)})
+		}
+	}
+	if len(catalog) == 0 {
+		catalog = append(catalog, &product{Id: "demo", Name: "Demo product", Price: int64(amount*100 + 0.5)})
+	}
+	return catalog, nil
+}
+
+// cartItem is a product in the cart.
+type cartItem struct {
+	Id       string
+	Name     string
+	Quantity int
+	Price    int64 // Unit price, in cents
+}
+
+func (i *cartItem) Total() int64 {
+	return int64(i.Quantity) * i.Price
+}
+
+// encodeCart serializes the items of a cart as id:quantity:price entries
+// separated by semicolons, i.e. tshirt:2:1500;mug:1:850. It is sent as
+// the caddie of the transaction, which the payment server sends back.
+func encodeCart(items []*cartItem) string {
+	entries := make([]string, 0, len(items))
+	for _, i := range items {
+		entries = append(entries, fmt.Sprintf("%s:%d:%d", i.Id, i.Quantity, i.Price))
+	}
+	return strings.Join(entries, ";")
+}
+
+// decodeCart parses the items of a cart serialized by encodeCart. Product
+// names are looked up in the catalog.
+func decodeCart(s string, catalog []*product) []*cartItem {
+	items := make([]*cartItem, 0)
+	for _, e := range strings.Split(s, ";") {
+		f := strings.Split(e, ":")
+		if len(f) != 3 {
+			continue
+		}
+		qty, err := strconv.Atoi(f[1])
+		if err != nil || qty <= 0 {
+			continue
+		}
+		price, err := strconv.ParseInt(f[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		item := &cartItem{Id: f[0], Name: f[0], Quantity: qty, Price: price}
+		for _, p := range catalog {
+			if p.Id == f[0] {
+				item.Name = p.Name
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// cart returns the items of the cart of a request, priced from the
+// catalog.
+func cart(r *http.Request, catalog []*product) []*cartItem {
+	c, err := r.Cookie(cartCookie)
+	if err != nil {
+		return nil
+	}
+	items := make([]*cartItem, 0)
+	for _, i := range decodeCart(c.Value, catalog) {
+		// The price of the cookie is not trusted
+		for _, p := range catalog {
+			if p.Id == i.Id {
+				items = append(items, &cartItem{Id: p.Id, Name: p.Name, Quantity: i.Quantity, Price: p.Price})
+			}
+		}
+	}
+	return items
+}
+
+func setCart(w http.ResponseWriter, items []*cartItem) {
+	http.SetCookie(w, &http.Cookie{Name: cartCookie, Value: encodeCart(items), Path: "/", HttpOnly: true})
+}
+
+func cartTotal(items []*cartItem) int64 {
+	var total int64
+	for _, i := range items {
+		total += i.Total()
+	}
+	return total
+}
+
+// cartHandler adds (action=add) or removes (action=remove) a product of
+// the cart, then redirects to the shop.
+func cartHandler(catalog []*product) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id := r.FormValue("product")
+		items := cart(r, catalog)
+		switch r.FormValue("action") {
+		case "add":
+			found := false
+			for _, i := range items {
+				if i.Id == id {
+					i.Quantity++
+					found = true
+				}
+			}
+			if !found {
+				for _, p := range catalog {
+					if p.Id == id {
+						items = append(items, &cartItem{Id: p.Id, Name: p.Name, Quantity: 1, Price: p.Price})
+					}
+				}
+			}
+		case "remove":
+			for k, i := range items {
+				if i.Id == id {
+					items = append(items[:k], items[k+1:]...)
+					break
+				}
+			}
+		case "clear":
+			items = nil
+		}
+		setCart(w, items)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// checkoutParams are request parameters of the demo checkouts, set with
+// flags and overridden by the query parameters of the same name.
+type checkoutParams struct {
+	CustomerId string
+	Caddie     string // Sent instead of the content of the cart, if set
+	Currency   string // ISO 4217 numeric code
+	Language   string
+}
+
+// transaction returns the transaction of a checkout of the cart.
+func (c *checkoutParams) transaction(r *http.Request, items []*cartItem) (*sogenactif.Transaction, error) {
+	p := *c
+	q := r.URL.Query()
+	for name, v := range map[string]*string{
+		"customer_id": &p.CustomerId,
+		"caddie":      &p.Caddie,
+		"currency":    &p.Currency,
+		"language":    &p.Language,
+	} {
+		if q.Get(name) != "" {
+			*v = q.Get(name)
+		}
+	}
+	if p.Caddie == "" {
+		p.Caddie = encodeCart(items)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("empty cart")
+	}
+	t, err := sogenactif.NewTransaction(&sogenactif.Customer{Id: p.CustomerId, Caddie: p.Caddie},
+		float64(cartTotal(items))/100)
+	if err != nil {
+		return nil, err
+	}
+	if p.Currency != "" {
+		if err := t.SetCurrencyCode(p.Currency); err != nil {
+			return nil, err
+		}
+	}
+	if p.Language != "" {
+		if err := t.SetLanguage(p.Language); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"github.com/gotsunami/sogenactif/notify"
+	"html"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// registerDemo sets up the handlers of the demo shop.
+func registerDemo(mux *http.ServeMux, sogen *sogenactif.Sogen, conf *sogenactif.Config, a *app) error {
+	catalog, err := loadCatalog(a.confPath, a.amount)
+	if err != nil {
+		return err
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		items := cart(r, catalog)
+		err := shopPage.Execute(w, map[string]interface{}{
+			"Catalog": catalog,
+			"Cart":    items,
+			"Total":   cartTotal(items),
+		})
+		if err != nil {
+			log.Print(err)
+		}
+	})
+	mux.HandleFunc("/cart", cartHandler(catalog))
+	mux.HandleFunc("/checkout", func(w http.ResponseWriter, r *http.Request) {
+		items := cart(r, catalog)
+		if len(items) == 0 {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		fmt.Fprintf(w, `<html><body>
+    <div style="text-align: center;"><h2>Sogenactif secure payment demo</h2></div>
+        `)
+		t, err := a.checkout.transaction(r, items)
+		if err == nil {
+			err = sogen.ResolveURLs(t, r)
+		}
+		if err == nil {
+			err = sogen.Checkout(t, w)
+		}
+		if err != nil {
+			fmt.Fprint(w, "<b>Error:</b> "+html.EscapeString(err.Error()))
+		}
+
+		fmt.Fprintf(w, "</body></html>")
+	})
+	tmpl, err := demoTemplates.Clone()
+	if err != nil {
+		return err
+	}
+	tmpl.Funcs(template.FuncMap{"items": func(caddie string) []*cartItem {
+		return decodeCart(caddie, catalog)
+	}})
+	sogen.SetTemplates(tmpl)
+	sogen.SetTransactionStore(a.transactions)
+
+	mux.HandleFunc(conf.ReturnUrl.Path, func(w http.ResponseWriter, r *http.Request) {
+		p, err := sogen.HandleReturn(w, r)
+		if err == sogenactif.ErrReplayedNotification {
+			sogen.Replayed(w, p)
+			return
+		}
+		if err != nil {
+			fmt.Fprint(w, "<html><body><b>Error:</b> "+html.EscapeString(err.Error()))
+			fmt.Fprintf(w, "<p>Try a <a href=\"/\">new transaction</a>.</p></body></html>")
+			return
+		}
+		fmt.Printf("%v\n", p)
+		switch p.ResponseCode {
+		case "00":
+			// Paid: empty the cart
+			setCart(w, nil)
+			sogen.Accepted(w, p)
+		case "17":
+			sogen.Cancelled(w, p)
+		default:
+			// Declined: keep the cart to try again
+			sogen.Declined(w, p)
+		}
+	})
+	mux.HandleFunc(conf.CancelUrl.Path, func(w http.ResponseWriter, r *http.Request) {
+		sogen.Cancelled(w, nil)
+	})
+	if conf.AutoResponseUrl != nil {
+		mux.Handle(conf.AutoResponseUrl.Path, sogen.AutoResponseHandler(func(p *sogenactif.Payment) error {
+			log.Println("Got autoresponse!")
+			// Do post-processing stuff here...
+			fmt.Printf("%v\n", p)
+			return nil
+		}))
+	}
+	if conf.PaymentLinkUrl != nil {
+		mux.Handle(conf.PaymentLinkUrl.Path+"/", sogen.PaymentLinkHandler())
+	}
+	if conf.WebhookUrl != nil {
+		notify.NewWebhook(conf.WebhookUrl.String()).Register(sogen)
+	}
+	if a.terminal {
+		mux.HandleFunc("/terminal", terminalHandler(sogen, conf.PaymentLinkUrl != nil))
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Status of a doctor check.
+const (
+	checkOk = iota
+	checkWarn
+	checkFail
+)
+
+// report prints the outcome of the doctor checks.
+type report struct {
+	color  bool
+	failed bool
+}
+
+func (r *report) print(status int, name, msg string) {
+	labels := []string{"ok", "warn", "FAIL"}
+	colors := []string{"\033[32m", "\033[33m", "\033[31m"}
+	label := fmt.Sprintf("%-4s", labels[status])
+	if r.color {
+		label = colors[status] + label + "\033[0m"
+	}
+	fmt.Printf("[%s] %-12s %s\n", label, name, msg)
+	if status == checkFail {
+		r.failed = true
+	}
+}
+
+// runDoctor validates a merchant setup end to end.
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s doctor [options] settings.conf\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	st, _ := os.Stdout.Stat()
+	color := fs.Bool("color", st != nil && st.Mode()&os.ModeCharDevice != 0, "colored output")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout of the URL checks")
+	showFiles := fs.Bool("files", false, "print the generated pathfile and parcom files")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+	}
+	r := &report{color: *color}
+	defer func() {
+		if r.failed {
+			os.Exit(1)
+		}
+	}()
+	conf, err := sogenactif.LoadConfig(fs.Arg(0))
+	if err != nil {
+		r.print(checkFail, "config", err.Error())
+		return
+	}
+	// NewSogen() logs every step
+	conf.LogLevel = sogenactif.LogSilent
+	if conf.Environment != "" {
+		r.print(checkOk, "config", fs.Arg(0)+" ("+conf.Environment+" environment)")
+	} else {
+		r.print(checkOk, "config", fs.Arg(0))
+	}
+
+	checkCertificate(r, conf)
+	checkMerchants(r, conf)
+
+	sogen, err := sogenactif.NewSogen(conf)
+	if err != nil {
+		r.print(checkFail, "setup", err.Error())
+		return
+	}
+	r.print(checkOk, "setup", "binaries and merchant files in place")
+	if *showFiles {
+		fmt.Println()
+		writeFiles(os.Stdout, sogen.GeneratedFiles())
+	}
+
+	// Dry request: the request binary signs the payment form with the
+	// certificate, which fails on inconsistent parameters
+	t, err := sogenactif.NewTransaction(&sogenactif.Customer{Id: "doctor"}, 1.00)
+	if err == nil {
+		err = sogen.Checkout(t, ioutil.Discard)
+	}
+	if err != nil {
+		r.print(checkFail, "request", err.Error())
+	} else {
+		r.print(checkOk, "request", "payment form generated")
+	}
+
+	checkURLs(r, conf, *timeout)
+}
+
+// writeFiles writes the generated files, sorted by path.
+func writeFiles(w io.Writer, files map[string][]byte) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "# %s\n%s\n", name, files[name])
+	}
+}
+
+// checkCertificate checks the header of the certificate file against the
+// config.
+func checkCertificate(r *report, c *sogenactif.Config) {
+	name := filepath.Join(c.MerchantsRootDir, c.MerchantId, fmt.Sprintf("certif.%s.%s.php", c.MerchantCountry, c.MerchantId))
+	f, err := os.Open(name)
+	if err != nil {
+		if c.CertificateSource != nil || c.MerchantsStore != nil {
+			r.print(checkWarn, "certificate", "not found, to be fetched at startup")
+		} else {
+			r.print(checkFail, "certificate", err.Error())
+		}
+		return
+	}
+	defer f.Close()
+	fields := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		parts := strings.Split(sc.Text(), "!")
+		if len(parts) == 3 && parts[2] == "" {
+			fields[parts[0]] = parts[1]
+		}
+	}
+	if fields["merchant_id"] != c.MerchantId || fields["merchant_country"] != c.MerchantCountry {
+		r.print(checkFail, "certificate", fmt.Sprintf("%s is for merchant %s (%s), not %s (%s)", name,
+			fields["merchant_id"], fields["merchant_country"], c.MerchantId, c.MerchantCountry))
+		return
+	}
+	if exp, err := time.Parse("20060102", fields["certificate_expired"]); err == nil && exp.Before(time.Now()) {
+		r.print(checkWarn, "certificate", fmt.Sprintf("%s expired on %s", name, exp.Format("2006-01-02")))
+		return
+	}
+	r.print(checkOk, "certificate", name)
+}
+
+// checkMerchants reports the merchants found in the merchants root
+// directory.
+func checkMerchants(r *report, c *sogenactif.Config) {
+	infos, err := sogenactif.DiscoverMerchants(c)
+	if err != nil {
+		r.print(checkWarn, "merchants", err.Error())
+		return
+	}
+	for _, m := range infos {
+		if m.Ready {
+			r.print(checkOk, "merchants", fmt.Sprintf("%s (%s)", m.Id, m.Country))
+		} else {
+			r.print(checkWarn, "merchants", fmt.Sprintf("%s: %s", m.Id, m.Problem))
+		}
+	}
+}
+
+// Labels of the URLs checked by sogenactif.CheckURLs().
+var urlLabels = map[string]string{
+	"cancel_url":        "cancel_url",
+	"return_url":        "return_url",
+	"auto_response_url": "auto_resp",
+}
+
+// checkURLs checks that the URLs of the config are reachable and served
+// over HTTPS.
+func checkURLs(r *report, c *sogenactif.Config, timeout time.Duration) {
+	if c.CancelUrl == nil {
+		r.print(checkFail, "cancel_url", "not set")
+	}
+	if c.ReturnUrl == nil {
+		r.print(checkFail, "return_url", "not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, check := range sogenactif.CheckURLs(ctx, c, nil) {
+		name := urlLabels[check.Name]
+		if check.Err != nil {
+			r.print(checkWarn, name, "unreachable: "+check.Err.Error())
+		} else {
+			r.print(checkOk, name, fmt.Sprintf("%s (%s)", check.URL, check.Status))
+		}
+		if check.Insecure {
+			r.print(checkWarn, name, "not served over HTTPS")
+		}
+	}
+	if c.AutoResponseUrl == nil {
+		r.print(checkWarn, "auto_resp", "auto_response_url not set, payments are only confirmed when buyers come back")
+	}
+}
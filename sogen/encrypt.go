@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+// runEncrypt encrypts a value of a settings file with the key of the
+// SOGEN_CONFIG_KEY env var.
+func runEncrypt(args []string) {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s encrypt [options] [value]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nThe value is read from stdin if not given. The key is read from $%s.\n", sogenactif.ConfigKeyVar)
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	genKey := fs.Bool("genkey", false, "print a new random key and exit")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+	}
+	log.SetFlags(0)
+	if *genKey {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(key))
+		return
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(os.Getenv(sogenactif.ConfigKeyVar)))
+	if err != nil || len(key) == 0 {
+		log.Fatalf("%s must hold a base64-encoded key, see -genkey", sogenactif.ConfigKeyVar)
+	}
+	value := fs.Arg(0)
+	if fs.NArg() == 0 {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		value = strings.TrimSpace(string(b))
+	}
+	enc, err := sogenactif.EncryptValue(key, value)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(enc)
+}
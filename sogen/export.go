@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gotsunami/sogenactif/export"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// runExport reads the payments of a -payments file, or a JSON array of
+// payments, and writes them to stdout as CSV or in the accounting format.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s export [options] payments.json\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nUse - to read payments from stdin.\n")
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	format := fs.String("f", "csv", "output format: csv or accounting")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+	}
+
+	var data []byte
+	var err error
+	if fs.Arg(0) == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	payments, err := readPayments(data)
+	if err != nil {
+		log.Fatal("can't decode payments: " + err.Error())
+	}
+
+	switch *format {
+	case "csv":
+		err = export.CSV(os.Stdout, payments)
+	case "accounting":
+		err = export.Accounting(os.Stdout, payments)
+	default:
+		log.Fatalf("unknown format %s", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+)
+
+var settingsTemplate = template.Must(template.New("settings").Parse(`[sogenactif]
+# Set it to true to enable HTML debugging
+debug=false
+merchant_id={{.MerchantId}}
+merchant_country={{.MerchantCountry}}
+# 978 is for EURO
+merchant_currency_code=978
+# Path to the lib directory holding closed-source binaries
+library_path={{.LibraryPath}}
+# Root directory holding the <merchant_id>/certif.<country>.<merchant_id>.php
+# certificate
+merchants_rootdir={{.MerchantsRootDir}}
+logo_path=/media/
+cancel_url={{.BaseUrl}}/sogen/cancel
+return_url={{.BaseUrl}}/sogen/return
+#auto_response_url={{.BaseUrl}}/sogen/autoresponse
+`))
+
+// runInit creates the layout of a merchant.
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s init [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	merchantId := fs.String("merchant-id", "", "merchant ID")
+	country := fs.String("country", "fr", "merchant country")
+	cert := fs.String("cert", "", "certificate file provided by Sogenactif")
+	rootDir := fs.String("dir", "./merchant/", "merchants root directory")
+	libPath := fs.String("lib", "../lib", "path to the lib directory holding the binaries")
+	baseUrl := fs.String("url", "http://localhost:6060", "base URL of the shop")
+	settings := fs.String("o", "settings.conf", "settings file to write")
+	force := fs.Bool("f", false, "overwrite an existing settings file")
+	fs.Parse(args)
+	if *merchantId == "" || *cert == "" || fs.NArg() != 0 {
+		fs.Usage()
+	}
+
+	data, err := ioutil.ReadFile(*cert)
+	if err != nil {
+		log.Fatal(err)
+	}
+	conf := &sogenactif.Config{
+		MerchantId:       *merchantId,
+		MerchantCountry:  *country,
+		MerchantsRootDir: *rootDir,
+		LibraryPath:      *libPath,
+	}
+	log.SetFlags(0)
+	if err := sogenactif.BootstrapMerchantDir(conf, data); err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := os.Stat(*settings); err == nil && !*force {
+		log.Fatalf("%s already exists, use -f to overwrite it", *settings)
+	}
+	f, err := os.Create(*settings)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	err = settingsTemplate.Execute(f, map[string]string{
+		"MerchantId":       conf.MerchantId,
+		"MerchantCountry":  conf.MerchantCountry,
+		"MerchantsRootDir": conf.MerchantsRootDir,
+		"LibraryPath":      conf.LibraryPath,
+		"BaseUrl":          strings.TrimRight(*baseUrl, "/"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Wrote %s\n", *settings)
+
+	fmt.Printf(`
+Next steps:
+  1. Review %s: the cancel, return and auto response URLs must be
+     reachable by the payment server.
+  2. Check the setup with:
+       %s doctor %s
+  3. Run the demo shop with:
+       %s %s
+`, *settings, os.Args[0], *settings, os.Args[0], *settings)
+	if _, err := os.Stat(*libPath); err != nil {
+		fmt.Printf("\nWarning: %s not found, copy the binaries of the SDK there.\n", *libPath)
+	}
+}
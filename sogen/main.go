@@ -7,75 +7,197 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		runDoctor(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		runInit(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "encrypt" {
+		runEncrypt(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "parse" {
+		runParse(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "version" {
+		runVersion(os.Args[2:])
+		return
+	}
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, fmt.Sprintf("Usage: %s [options] settings.conf \n", os.Args[0]))
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] settings.conf \n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -demo [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s export [options] payments.json\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s doctor [options] settings.conf\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s init [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s encrypt [options] [value]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s parse [options] settings.conf\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s version [settings.conf]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nOptions:\n")
 		flag.PrintDefaults()
 		os.Exit(2)
 	}
 	port := flag.String("p", "6060", "http server listening port")
-	amount := flag.Float64("t", 1.00, "transaction amount")
+	listen := flag.String("listen", "", "http server listening address (host:port), overrides -p")
+	autoListen := flag.String("autoresponse-listen", "", "separate listening address (host:port) of the auto response endpoint, i.e. on an internal interface")
+	readTimeout := flag.Duration("read-timeout", 10*time.Second, "maximum duration for reading a request")
+	writeTimeout := flag.Duration("write-timeout", 30*time.Second, "maximum duration for writing a response")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file, to serve HTTPS")
+	tlsKey := flag.String("tls-key", "", "TLS key file")
+	autocertDomains := flag.String("autocert", "", "comma-separated domains to serve HTTPS with Let's Encrypt certificates")
+	autocertCache := flag.String("autocert-cache", "certs", "directory caching the Let's Encrypt certificates")
+	debugAddr := flag.String("debug-addr", "", "private listening address (host:port) of the pprof and expvar endpoints")
+	accessLogs := flag.Bool("access-log", true, "log requests")
+	drain := flag.Duration("drain", 30*time.Second, "time given to in-flight requests to complete on shutdown")
+	amount := flag.Float64("t", 1.00, "price of the demo product, if the settings file has no [demo] products")
+	checkout := &checkoutParams{}
+	flag.StringVar(&checkout.CustomerId, "customer", "johndoe", "customer ID of the demo checkouts")
+	flag.StringVar(&checkout.Caddie, "caddie", "", "caddie of the demo checkouts, instead of the content of the cart")
+	flag.StringVar(&checkout.Currency, "currency", "", "ISO 4217 numeric currency code of the demo checkouts (i.e. 840), if not the merchant's one")
+	flag.StringVar(&checkout.Language, "language", "", "language of the payment pages of the demo checkouts (i.e. en)")
+	admin := &adminParams{}
+	flag.StringVar(&admin.User, "admin-user", "admin", "user of the /admin section")
+	flag.StringVar(&admin.Password, "admin-password", os.Getenv("SOGEN_ADMIN_PASSWORD"), "password of the /admin section, disabled if empty (default $SOGEN_ADMIN_PASSWORD)")
+	flag.StringVar(&admin.OfficeUrl, "office-url", "", "gateway URL of the office server, to make refunds from /admin (experimental, unsigned requests)")
+	paymentsFile := flag.String("payments", "", "JSON file storing the payments, kept in memory only if empty")
+	blocklistFile := flag.String("blocklist", "", "JSON file storing the blocklist of the API, kept in memory only if empty")
+	terminal := flag.Bool("terminal", false, "serve a virtual terminal for mail/telephone orders on /terminal")
+	api := flag.Bool("api", false, "run as a JSON API service instead of the demo")
+	demo := flag.Bool("demo", false, "run the test merchant of the payment server, without settings file")
+	demoLib := flag.String("lib", "../lib", "path to the lib directory holding the binaries, in demo mode")
+	baseUrl := flag.String("base-url", "", "public URL of the server, i.e. https://shop.example.com, resolving the relative URLs of the settings file (default http://localhost:<port> with -demo)")
+	demoDir := flag.String("demo-dir", filepath.Join(os.TempDir(), "sogen-demo"), "directory of the files of the test merchant, in demo mode")
+	remoteExec := flag.String("remote-exec", "", "URL of the /exec endpoint of a sogen -exec-agent running the binaries, i.e. on Windows or macOS")
+	execAgent := flag.Bool("exec-agent", false, "serve the binaries to -remote-exec instances on /exec, with the $SOGEN_EXEC_TOKEN secret")
+	check := flag.Bool("check", false, "validate the config and the merchant setup, without writing any file, and exit")
+	verifyFiles := flag.Bool("verify-files", false, "with -check, also compare the pathfile and parcom files of the merchant to the config")
 	flag.Parse()
-	if len(flag.Args()) != 1 {
+	if *demo == (len(flag.Args()) == 1) || len(flag.Args()) > 1 {
 		flag.Usage()
 	}
 
-	conf, err := sogenactif.LoadConfig(flag.Arg(0))
-	if err != nil {
-		log.Fatal("config file error: " + err.Error())
+	if *check {
+		conf, err := sogenactif.LoadConfig(flag.Arg(0))
+		if err != nil {
+			log.Fatal("config file error: " + err.Error())
+		}
+		conf.DryRun = true
+		sogen, err := sogenactif.NewSogen(conf)
+		if err != nil {
+			log.Fatal("invalid setup: " + err.Error())
+		}
+		if *verifyFiles {
+			diffs, err := sogen.VerifyFiles()
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, d := range diffs {
+				fmt.Println(d.String())
+			}
+			if len(diffs) > 0 {
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("%s: OK\n", flag.Arg(0))
+		return
+	}
+	var payments sogenactif.PaymentStore = sogenactif.NewMemoryPaymentStore()
+	if *paymentsFile != "" {
+		st, err := openFileStore(*paymentsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		payments = st
 	}
-	sogen, err := sogenactif.NewSogen(conf)
+	a := newApp(flag.Arg(0), *api, *amount, *terminal, checkout, payments)
+	if *blocklistFile != "" {
+		bl, err := sogenactif.OpenBlocklist(*blocklistFile, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		a.blocklist = bl
+	}
+	a.admin = admin
+	a.demoLib, a.demoDir = *demoLib, *demoDir
+	a.baseUrl = *baseUrl
+	if a.baseUrl == "" && *demo {
+		a.baseUrl = "http://localhost:" + *port
+	}
+	if (*remoteExec != "" || *execAgent) && os.Getenv("SOGEN_EXEC_TOKEN") == "" {
+		log.Fatal("-remote-exec and -exec-agent require $SOGEN_EXEC_TOKEN")
+	}
+	a.remoteExec, a.execAgent = *remoteExec, *execAgent
+	conf, err := a.load()
 	if err != nil {
 		log.Fatal(err)
 	}
+	go a.reloadOnHangup()
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		t := sogenactif.NewTransaction(&sogenactif.Customer{Id: "johndoe",
-			Caddie: "internal-transaction-666"}, *amount)
-		fmt.Fprintf(w, `<html><body>
-    <a href="https://github.com/gotsunami/sogenactif"><img style="position: absolute; top: 0; right: 0; border: 0;" src="https://s3.amazonaws.com/github/ribbons/forkme_right_red_aa0000.png" alt="Fork me on GitHub"></a>
-    <div style="text-align: center;"><h2>Sogenactif secure payment demo</h2></div>
-        `)
-		if err := sogen.Checkout(t, w); err != nil {
-			fmt.Fprintf(w, "<b>Error:</b> "+err.Error())
+	addr := ":" + *port
+	if *listen != "" {
+		addr = *listen
+	}
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      a,
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatal("both -tls-cert and -tls-key are required")
+	}
+	public := &listener{srv: srv, tls: *tlsCert != "", certFile: *tlsCert, keyFile: *tlsKey}
+	if *autocertDomains != "" {
+		if public.tls {
+			log.Fatal("-autocert can't be used with -tls-cert")
 		}
-
-		fmt.Fprintf(w, "</body></html>")
-	})
-	http.HandleFunc(conf.ReturnUrl.Path, func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "<html><body>")
-		fmt.Fprintf(w, "<h2>Thank you!</h2>")
-		p, err := sogen.HandlePayment(w, r)
-		if err != nil {
-			fmt.Fprintf(w, "<b>Error:</b> "+err.Error())
+		srv.TLSConfig = autocertManager(strings.Split(*autocertDomains, ","), *autocertCache).TLSConfig()
+		public.tls = true
+	}
+	// Wraps the handlers of the listeners with request IDs and access logs
+	wrap := func(h http.Handler) http.Handler {
+		if *accessLogs {
+			h = accessLog(h)
 		}
-		fmt.Fprintf(w, "<p>Try a <a href=\"/\">new transaction</a>.</p>")
-		fmt.Fprintf(w, "</body></html>")
-		fmt.Printf("%v\n", p)
-	})
-	http.HandleFunc(conf.CancelUrl.Path, func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "<html><body>")
-		fmt.Fprintf(w, "<h2>The transaction has been cancelled.</h2>")
-		fmt.Fprintf(w, "<p>You can <a href=\"/\">try a new one</a>.</p>")
-		fmt.Fprintf(w, "</body></html>")
-	})
-	if conf.AutoResponseUrl != nil {
-		http.HandleFunc(conf.AutoResponseUrl.Path, func(w http.ResponseWriter, r *http.Request) {
-			log.Println("Got autoresponse!")
-			p, err := sogen.HandlePayment(w, r)
-			if err != nil {
-				fmt.Fprintf(w, "<b>Error:</b> "+err.Error())
-			}
-			// Do post-processing stuff here...
-			fmt.Printf("%v\n", p)
-		})
+		return sogenactif.RequestIdHandler(h)
 	}
-	// Serve static content
-	http.Handle(conf.LogoPath, http.StripPrefix(conf.LogoPath, http.FileServer(http.Dir(conf.MediaPath))))
-
-	fmt.Printf("Starting server on port %s ...\n", *port)
-	log.Fatal(http.ListenAndServe(":"+*port, nil))
+	listeners := []*listener{public}
+	if *autoListen != "" {
+		if conf.AutoResponseUrl == nil {
+			log.Fatal("-autoresponse-listen requires an auto_response_url")
+		}
+		p := conf.AutoResponseUrl.Path
+		srv.Handler = routePath(srv.Handler, p, false)
+		listeners = append(listeners, &listener{srv: &http.Server{
+			Addr:         *autoListen,
+			Handler:      wrap(routePath(a, p, true)),
+			ReadTimeout:  *readTimeout,
+			WriteTimeout: *writeTimeout,
+		}})
+		fmt.Printf("Serving %s on %s ...\n", p, *autoListen)
+	}
+	srv.Handler = wrap(srv.Handler)
+	if *debugAddr != "" {
+		// No write timeout: CPU profiles and traces take a while
+		listeners = append(listeners, &listener{srv: &http.Server{Addr: *debugAddr, Handler: debugHandler()}})
+		fmt.Printf("Serving debug endpoints on %s ...\n", *debugAddr)
+	}
+	fmt.Printf("Starting server on %s ...\n", addr)
+	if err := serve(*drain, listeners...); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Server stopped")
 }
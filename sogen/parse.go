@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+// runParse decodes a DATA payload captured from a notification with the
+// response binary and prints the payment as JSON.
+func runParse(args []string) {
+	fs := flag.NewFlagSet("parse", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s parse [options] settings.conf\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nDATA is read from stdin if -data is not set.\n")
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	data := fs.String("data", "", "DATA payload posted by the payment server")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+	}
+	if *data == "" {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*data = string(b)
+	}
+	// Payloads pulled from logs may come as DATA=...
+	*data = strings.TrimPrefix(strings.TrimSpace(*data), "DATA=")
+
+	conf, err := sogenactif.LoadConfig(fs.Arg(0))
+	if err != nil {
+		log.Fatal("config file error: " + err.Error())
+	}
+	sogen, err := sogenactif.NewSogen(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Debug output goes to stderr, keeping stdout for the JSON output
+	p, err := sogen.DecodeResponse(os.Stderr, *data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(out))
+}
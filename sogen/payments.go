@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gotsunami/sogenactif"
+	"github.com/gotsunami/sogenactif/stats"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileStore is a payment store kept in a file, as read by the export
+// command: a journal of JSON records, one per line, holding a payment, a
+// refund or a capture. New payments and operations are appended. The file
+// is rewritten when a payment changes (i.e. is anonymized), so that its
+// previous version is not kept.
+type fileStore struct {
+	*sogenactif.MemoryPaymentStore
+	mu    sync.Mutex // Serializes file writes
+	path  string
+	saved map[string][]byte // JSON of the payments, by transaction ID and payment date
+}
+
+// fileRecord is a record of the file of a fileStore.
+type fileRecord struct {
+	Payment *sogenactif.Payment       `json:"payment,omitempty"`
+	Refund  *sogenactif.RefundResult  `json:"refund,omitempty"`
+	Capture *sogenactif.CaptureResult `json:"capture,omitempty"`
+}
+
+// readRecords decodes the records of a fileStore. A JSON array of payments,
+// as written by previous versions, is read too.
+func readRecords(data []byte) ([]*fileRecord, error) {
+	records := make([]*fileRecord, 0)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		payments := make([]*sogenactif.Payment, 0)
+		if err := json.Unmarshal(data, &payments); err != nil {
+			return nil, err
+		}
+		for _, p := range payments {
+			records = append(records, &fileRecord{Payment: p})
+		}
+		return records, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		r := new(fileRecord)
+		if err := dec.Decode(r); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+}
+
+// readPayments returns the payments of the records of a fileStore, in their
+// last version.
+func readPayments(data []byte) ([]*sogenactif.Payment, error) {
+	records, err := readRecords(data)
+	if err != nil {
+		return nil, err
+	}
+	st := sogenactif.NewMemoryPaymentStore()
+	for _, r := range records {
+		if r.Payment != nil {
+			st.SavePayment(r.Payment)
+		}
+	}
+	return st.Payments(allTime[0], allTime[1])
+}
+
+// openFileStore returns a store of the records of a file, which is created
+// on the first record if it does not exist.
+func openFileStore(path string) (*fileStore, error) {
+	st := &fileStore{MemoryPaymentStore: sogenactif.NewMemoryPaymentStore(), path: path, saved: make(map[string][]byte)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	records, err := readRecords(data)
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	for _, r := range records {
+		switch {
+		case r.Payment != nil:
+			if st.saved[paymentKey(r.Payment)], err = json.Marshal(r.Payment); err != nil {
+				return nil, err
+			}
+			st.MemoryPaymentStore.SavePayment(r.Payment)
+		case r.Refund != nil:
+			st.MemoryPaymentStore.SaveRefund(r.Refund)
+		case r.Capture != nil:
+			st.MemoryPaymentStore.SaveCapture(r.Capture)
+		}
+	}
+	if len(records) > len(st.saved) || !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		// Drops the previous versions of the payments, or converts a
+		// JSON array
+		if err := st.rewrite(); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// allTime is the time span of all payments.
+var allTime = [2]time.Time{time.Time{}, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+// paymentKey identifies a payment: a transaction ID is unique over a day.
+func paymentKey(p *sogenactif.Payment) string {
+	return p.TransactionId + "@" + p.PaymentDate.Format("20060102")
+}
+
+func (f *fileStore) SavePayment(p *sogenactif.Payment) error {
+	if p == nil {
+		return errors.New("nil payment")
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := paymentKey(p)
+	prev, update := f.saved[key]
+	if update && bytes.Equal(prev, data) {
+		// Handled by both the return and auto responses
+		return f.MemoryPaymentStore.SavePayment(p)
+	}
+	if !update {
+		if err := f.append(&fileRecord{Payment: p}); err != nil {
+			return err
+		}
+	}
+	if err := f.MemoryPaymentStore.SavePayment(p); err != nil {
+		return err
+	}
+	f.saved[key] = data
+	if update {
+		return f.rewrite()
+	}
+	return nil
+}
+
+func (f *fileStore) SaveRefund(r *sogenactif.RefundResult) error {
+	if r == nil {
+		return errors.New("nil refund")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.append(&fileRecord{Refund: r}); err != nil {
+		return err
+	}
+	return f.MemoryPaymentStore.SaveRefund(r)
+}
+
+func (f *fileStore) SaveCapture(c *sogenactif.CaptureResult) error {
+	if c == nil {
+		return errors.New("nil capture")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.append(&fileRecord{Capture: c}); err != nil {
+		return err
+	}
+	return f.MemoryPaymentStore.SaveCapture(c)
+}
+
+// append appends a record to the file. f.mu must be held.
+func (f *fileStore) append(r *fileRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// rewrite writes all the records of the store to the file. f.mu must be
+// held.
+func (f *fileStore) rewrite() error {
+	payments, err := f.Payments(allTime[0], allTime[1])
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	ids := make(map[string]bool)
+	for _, p := range payments {
+		if err := enc.Encode(&fileRecord{Payment: p}); err != nil {
+			return err
+		}
+		ids[p.TransactionId] = true
+	}
+	for id := range ids {
+		refunds, err := f.Refunds(id)
+		if err != nil {
+			return err
+		}
+		for _, r := range refunds {
+			if err := enc.Encode(&fileRecord{Refund: r}); err != nil {
+				return err
+			}
+		}
+		captures, err := f.Captures(id)
+		if err != nil {
+			return err
+		}
+		for _, c := range captures {
+			if err := enc.Encode(&fileRecord{Capture: c}); err != nil {
+				return err
+			}
+		}
+	}
+	tmp := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// dateRange returns the from and to dates (YYYY-MM-DD) of a query, the
+// 30 days up to now by default. to is included.
+func dateRange(q url.Values, now time.Time) (time.Time, time.Time, error) {
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	from := to.AddDate(0, 0, -29)
+	if v := q.Get("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return from, to, errors.New("bad from date")
+		}
+		from = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return from, to, errors.New("bad to date")
+		}
+		to = t
+	}
+	return from, to, nil
+}
+
+// paymentsHandler lists the payments of the store made between the from
+// and to dates (YYYY-MM-DD, the last 30 days by default), with the
+// response code given by code, if any. Payments are listed as HTML, or as
+// JSON with format=json or an Accept: application/json header.
+func paymentsHandler(store sogenactif.PaymentStore, clock sogenactif.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, to, err := dateRange(q, clock.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		payments, err := store.Payments(from, to.AddDate(0, 0, 1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if code := q.Get("code"); code != "" {
+			list := make([]*sogenactif.Payment, 0)
+			for _, p := range payments {
+				if p.ResponseCode == code {
+					list = append(list, p)
+				}
+			}
+			payments = list
+		}
+		if q.Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			writeJSON(w, http.StatusOK, payments)
+			return
+		}
+		err = paymentsPage.Execute(w, map[string]interface{}{
+			"From":     from.Format("2006-01-02"),
+			"To":       to.Format("2006-01-02"),
+			"Code":     q.Get("code"),
+			"Payments": payments,
+		})
+		if err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// Intervals of the buckets of /stats.json.
+var statsIntervals = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+// statsHandler writes as JSON the statistics of the payments of the store
+// made between the from and to dates (see paymentsHandler), bucketed by
+// interval (hour, day or week, day by default).
+func statsHandler(store sogenactif.PaymentStore, clock sogenactif.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, to, err := dateRange(q, clock.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		interval := statsIntervals["day"]
+		if v := q.Get("interval"); v != "" {
+			d, ok := statsIntervals[v]
+			if !ok {
+				http.Error(w, "bad interval", http.StatusBadRequest)
+				return
+			}
+			interval = d
+		}
+		report, err := stats.Query(store, from, to.AddDate(0, 0, 1), interval)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
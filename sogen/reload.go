@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"github.com/gotsunami/sogenactif"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// app is the handler of the server. Its state is kept across config
+// reloads.
+type app struct {
+	confPath     string // Runs the demo merchant if empty
+	demoLib      string // Library path of the demo merchant
+	demoDir      string // Merchants root directory of the demo merchant
+	baseUrl      string // Public URL of the server, if not the one of the config
+	api          bool
+	amount       float64
+	terminal     bool
+	payments     sogenactif.PaymentStore
+	transactions *sogenactif.MemoryTransactionStore
+	blocklist    *sogenactif.Blocklist
+	checkout     *checkoutParams // Defaults of the demo checkouts
+	remoteExec   string          // URL of the exec agent running the binaries, if any
+	execAgent    bool            // Serves the binaries to remote instances on /exec
+	admin        *adminParams
+	notifs       *recentNotifications
+	handler      atomic.Value // Handlers of the current config
+}
+
+func newApp(confPath string, api bool, amount float64, terminal bool, checkout *checkoutParams, payments sogenactif.PaymentStore) *app {
+	return &app{
+		checkout:     checkout,
+		notifs:       &recentNotifications{max: 50},
+		payments:     payments,
+		confPath:     confPath,
+		api:          api,
+		amount:       amount,
+		terminal:     terminal,
+		transactions: sogenactif.NewMemoryTransactionStore(),
+		blocklist:    sogenactif.NewBlocklist(nil),
+	}
+}
+
+// load loads the config and switches to the handlers of a new Sogen
+// instance. The current handlers are kept on error.
+func (a *app) load() (*sogenactif.Config, error) {
+	var conf *sogenactif.Config
+	if a.confPath == "" {
+		conf = sogenactif.NewDemoConfig(a.demoLib, a.demoDir)
+	} else {
+		c, err := sogenactif.LoadConfig(a.confPath)
+		if err != nil {
+			return nil, errors.New("config file error: " + err.Error())
+		}
+		conf = c
+	}
+	if a.baseUrl != "" {
+		u, err := url.Parse(a.baseUrl)
+		if err != nil || !u.IsAbs() {
+			return nil, errors.New("bad base URL " + a.baseUrl)
+		}
+		conf.BaseUrl = u
+	}
+	if a.remoteExec != "" {
+		conf.Executor = &sogenactif.RemoteExecutor{URL: a.remoteExec, Token: os.Getenv("SOGEN_EXEC_TOKEN")}
+	}
+	sogen, err := sogenactif.NewSogen(conf)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	if a.execAgent {
+		mux.Handle("/exec", sogen.ExecHandler(os.Getenv("SOGEN_EXEC_TOKEN")))
+	}
+	sogen.SetPaymentStore(a.payments)
+	if a.admin != nil && a.admin.Password != "" {
+		mux.Handle("/payments", a.admin.basicAuth(paymentsHandler(a.payments, sogen)))
+		mux.Handle("/stats.json", a.admin.basicAuth(statsHandler(a.payments, sogen)))
+		if err := registerAdmin(mux, sogen, conf, a); err != nil {
+			return nil, err
+		}
+	}
+	if a.api {
+		registerAPI(mux, sogen, a.payments, a.blocklist, a.admin)
+	} else {
+		if err := registerDemo(mux, sogen, conf, a); err != nil {
+			return nil, err
+		}
+	}
+	// Serve static content
+	mux.Handle(conf.LogoPath, sogen.MediaHandler())
+	a.handler.Store(http.Handler(mux))
+	return conf, nil
+}
+
+func (a *app) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+// reloadOnHangup reloads the config on SIGHUP. The listeners are kept:
+// changes to their addresses or to the auto response path of a separate
+// listener require a restart.
+func (a *app) reloadOnHangup() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		if _, err := a.load(); err != nil {
+			log.Printf("Can't reload %s, keeping the current config: %s", a.confPath, err.Error())
+			continue
+		}
+		log.Printf("Reloaded %s", a.confPath)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"github.com/gotsunami/sogenactif"
+	"golang.org/x/crypto/acme/autocert"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// listener is a server and its TLS settings.
+type listener struct {
+	srv               *http.Server
+	tls               bool // Serve HTTPS, with the certificate and key files or srv.TLSConfig
+	certFile, keyFile string
+}
+
+func (l *listener) listen() error {
+	if l.tls {
+		return l.srv.ListenAndServeTLS(l.certFile, l.keyFile)
+	}
+	return l.srv.ListenAndServe()
+}
+
+// serve runs the listeners until SIGINT or SIGTERM, then shuts them down
+// gracefully: in-flight requests, such as payment notifications, get up to
+// drain to complete. If a listener fails, all are shut down.
+func serve(drain time.Duration, ls ...*listener) error {
+	errs := make(chan error, len(ls))
+	for _, l := range ls {
+		go func(l *listener) {
+			errs <- l.listen()
+		}(l)
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	var err error
+	select {
+	case s := <-sig:
+		log.Printf("Got %s, shutting down ...", s)
+	case err = <-errs:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	for _, l := range ls {
+		if e := l.srv.Shutdown(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// routePath serves the requests to path p with h if only is set, or all
+// other requests otherwise, so that an endpoint is served on its own
+// listener.
+func routePath(h http.Handler, p string, only bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.URL.Path == p) != only {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// autocertManager returns a manager of Let's Encrypt certificates for the
+// domains. It answers the HTTP-01 challenges on port 80.
+func autocertManager(domains []string, cacheDir string) *autocert.Manager {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cacheDir),
+	}
+	go func() {
+		log.Fatal(http.ListenAndServe(":80", m.HTTPHandler(nil)))
+	}()
+	return m
+}
+
+// statusWriter records the status and size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// accessLog returns a handler logging requests as key=value pairs, along
+// with their ID (see sogenactif.RequestIdHandler).
+func accessLog(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		log.Printf("access request_id=%s remote=%s method=%s path=%q status=%d size=%d duration=%s",
+			sogenactif.RequestId(r.Context()), r.RemoteAddr, r.Method, r.URL.Path, sw.status, sw.size,
+			time.Since(start))
+	})
+}
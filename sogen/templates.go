@@ -0,0 +1,115 @@
+package main
+
+import (
+	"github.com/gotsunami/sogenactif"
+	"html/template"
+)
+
+// Currency of the demo shop.
+var euro, _ = sogenactif.LookupCurrency("978")
+
+// demoFuncs are the functions of the demo templates. items is bound to the
+// catalog by registerDemo().
+var demoFuncs = template.FuncMap{
+	"items": func(caddie string) []*cartItem {
+		return decodeCart(caddie, nil)
+	},
+	"euros": func(cents int64) string {
+		return sogenactif.FormatAmount(cents, euro, sogenactif.DefaultLanguage)
+	},
+	"tr": sogenactif.TemplateFuncs["tr"],
+}
+
+// Pages of the demo, registered with Sogen.SetTemplates().
+var demoTemplates = template.Must(template.New("accepted").Funcs(demoFuncs).Parse(`<html><body>
+<h2>{{tr "Thank you!"}}</h2>
+<p>{{printf (tr "Your payment of %s has been accepted.") (.FormatAmount "")}}</p>
+{{with items .Caddie}}<ul>
+{{range .}}<li>{{.Quantity}} x {{.Name}}: {{euros .Total}}</li>
+{{end}}</ul>{{end}}
+<p><a href="/">{{tr "Try a new transaction"}}</a></p>
+</body></html>`))
+
+func init() {
+	sogenactif.RegisterMessages("fr", sogenactif.Messages{
+		"Your payment of %s has been accepted.": "Votre paiement de %s a été accepté.",
+		"Try a new transaction":                 "Essayer une nouvelle transaction",
+		"Try again":                             "Réessayer",
+	})
+	template.Must(demoTemplates.New("declined").Parse(`<html><body>
+<h2>{{tr "Your payment has been declined."}}</h2>
+<p><a href="/">{{tr "Try again"}}</a></p>
+</body></html>`))
+	template.Must(demoTemplates.New("cancelled").Parse(`<html><body>
+<h2>{{tr "The transaction has been cancelled."}}</h2>
+<p><a href="/">{{tr "Try a new transaction"}}</a></p>
+</body></html>`))
+}
+
+// shopPage is the catalog and cart of the demo shop.
+var shopPage = template.Must(template.New("shop").Funcs(demoFuncs).Parse(`<html><body>
+<a href="https://github.com/gotsunami/sogenactif"><img style="position: absolute; top: 0; right: 0; border: 0;" src="https://s3.amazonaws.com/github/ribbons/forkme_right_red_aa0000.png" alt="Fork me on GitHub"></a>
+<div style="text-align: center;"><h2>Sogenactif secure payment demo</h2></div>
+<h3>Products</h3>
+<table>
+{{range .Catalog}}<tr><td>{{.Name}}</td><td>{{euros .Price}}</td><td>
+<form method="post" action="/cart"><input type="hidden" name="product" value="{{.Id}}">
+<button name="action" value="add">Add to cart</button></form></td></tr>
+{{end}}</table>
+<h3>Cart</h3>
+{{if .Cart}}<table>
+{{range .Cart}}<tr><td>{{.Quantity}} x {{.Name}}</td><td>{{euros .Total}}</td><td>
+<form method="post" action="/cart"><input type="hidden" name="product" value="{{.Id}}">
+<button name="action" value="remove">Remove</button></form></td></tr>
+{{end}}<tr><td><b>Total</b></td><td><b>{{euros .Total}}</b></td><td></td></tr>
+</table>
+<p><a href="/checkout">Checkout</a></p>
+{{else}}<p>Your cart is empty.</p>{{end}}
+</body></html>`))
+
+// paymentsPage lists payments.
+var paymentsPage = template.Must(template.New("payments").Parse(`<html><body>
+<h2>Payments</h2>
+<form method="get" action="/payments">
+From <input type="date" name="from" value="{{.From}}"> to <input type="date" name="to" value="{{.To}}">
+Response code <input type="text" name="code" size="2" value="{{.Code}}">
+<input type="submit" value="Filter"> <a href="?from={{.From}}&amp;to={{.To}}&amp;code={{.Code}}&amp;format=json">JSON</a>
+</form>
+<table border="1" cellpadding="3" style="border-collapse: collapse;">
+<tr><th>Date</th><th>Transaction</th><th>Customer</th><th>Amount</th><th>Means</th><th>Response code</th><th>Bank response code</th></tr>
+{{range .Payments}}<tr><td>{{.PaymentDate.Format "2006-01-02 15:04:05"}}</td><td>{{.TransactionId}}</td><td>{{.CustomerId}}</td>
+<td>{{printf "%.2f" .Amount}}</td><td>{{.PaymentMeans}}{{with .Wallet.Indicator}} ({{.}} wallet){{end}}</td><td>{{.ResponseCode}}</td><td>{{.BankResponseCode}}</td></tr>
+{{else}}<tr><td colspan="7">No payments.</td></tr>
+{{end}}</table>
+</body></html>`))
+
+// adminPage is the dashboard of the admin section.
+var adminPage = template.Must(template.New("admin").Parse(`<html><body>
+<h2>Admin</h2>
+{{with .Message}}<p><b>{{.}}</b></p>{{end}}
+<h3>Last 30 days</h3>
+{{with .Stats}}<p>{{.Count}} payments, {{.Accepted}} accepted ({{printf "%.1f" .Rate}}%), volume {{printf "%.2f" .Volume}}</p>
+{{if .Declines}}<table border="1" cellpadding="3" style="border-collapse: collapse;">
+<tr><th>Response code</th><th>Declines</th></tr>
+{{range .Declines}}<tr><td>{{.Code}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}{{end}}
+<h3>Recent payments</h3>
+<table border="1" cellpadding="3" style="border-collapse: collapse;">
+<tr><th>Date</th><th>Transaction</th><th>Amount</th><th>Response code</th><th></th></tr>
+{{$refunds := .Refunds}}{{range .Payments}}<tr><td>{{.PaymentDate.Format "2006-01-02 15:04:05"}}</td><td>{{.TransactionId}}</td>
+<td>{{printf "%.2f" .Amount}}</td><td>{{.ResponseCode}}</td><td>{{if and $refunds (eq .ResponseCode "00")}}
+<form method="post" action="/admin/refund"><input type="hidden" name="transaction_id" value="{{.TransactionId}}">
+<input type="text" name="amount" size="6" placeholder="all"> <input type="submit" value="Refund"></form>{{end}}</td></tr>
+{{end}}</table>
+<p><a href="/payments">All payments</a> - <a href="/admin/files">Merchant files</a></p>
+<form method="get" action="/admin/status">Was it paid? <input type="text" name="transaction_id" size="8" placeholder="transaction ID">
+<input type="submit" value="Check"></form>
+<h3>Recent notifications</h3>
+<table border="1" cellpadding="3" style="border-collapse: collapse;">
+<tr><th>Date</th><th>Request</th><th>DATA</th><th>Error</th><th></th></tr>
+{{range .Notifications}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.RequestId}}</td>
+<td><code style="word-break: break-all;">{{.Data}}</code></td><td>{{.Error}}</td><td>
+<form method="post" action="/admin/reparse"><input type="hidden" name="data" value="{{.Data}}">
+<input type="submit" value="Parse again"></form></td></tr>
+{{end}}</table>
+</body></html>`))
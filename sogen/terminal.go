@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const terminalForm = `<form method="post" action="/terminal">
+    <p>Amount: <input type="text" name="amount" value="%s"></p>
+    <p>Customer reference: <input type="text" name="customer" value="%s"></p>
+    <p>Channel: <select name="channel">
+        <option value="MOTO">Mail/telephone order</option>
+        <option value="TELEPHONE_ORDER">Telephone order</option>
+        <option value="MAIL_ORDER">Mail order</option>
+    </select></p>
+    <p><input type="submit" value="Generate payment link"></p>
+</form>`
+
+// Validity of the payment links generated by the virtual terminal.
+const terminalLinkTTL = 24 * time.Hour
+
+// terminalHandler serves a virtual terminal: an operator keys in an amount
+// and a customer reference for a mail/telephone order, then gets the link to
+// the payment server. If links is true, a shareable payment link is
+// generated too.
+func terminalHandler(sogen *sogenactif.Sogen, links bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><body>")
+		fmt.Fprintf(w, "<div style=\"text-align: center;\"><h2>Virtual terminal</h2></div>")
+		defer fmt.Fprintf(w, "</body></html>")
+
+		amount := strings.Replace(strings.TrimSpace(r.FormValue("amount")), ",", ".", 1)
+		customer := strings.TrimSpace(r.FormValue("customer"))
+		fmt.Fprintf(w, terminalForm, html.EscapeString(amount), html.EscapeString(customer))
+		if r.Method != "POST" {
+			return
+		}
+
+		a, err := strconv.ParseFloat(amount, 64)
+		if err != nil {
+			fmt.Fprintf(w, "<b>Error:</b> bad amount")
+			return
+		}
+		t, err := sogen.NewTransaction(&sogenactif.Customer{Id: customer}, a)
+		if err != nil {
+			fmt.Fprint(w, "<b>Error:</b> "+html.EscapeString(err.Error()))
+			return
+		}
+		if err := sogen.ResolveURLs(t, r); err != nil {
+			fmt.Fprint(w, "<b>Error:</b> "+html.EscapeString(err.Error()))
+			return
+		}
+		if err := t.SetOrderChannel(sogenactif.OrderChannel(r.FormValue("channel"))); err != nil {
+			fmt.Fprint(w, "<b>Error:</b> "+html.EscapeString(err.Error()))
+			return
+		}
+		fmt.Fprintf(w, "<hr><p>Payment of %.2f for customer %s:</p>", a, html.EscapeString(customer))
+		if links {
+			u, err := sogen.CreatePaymentLink(t, terminalLinkTTL)
+			if err != nil {
+				fmt.Fprint(w, "<b>Error:</b> "+html.EscapeString(err.Error()))
+				return
+			}
+			fmt.Fprintf(w, "<p>Payment link: <a href=\"%s\">%s</a></p>", u, html.EscapeString(u.String()))
+		}
+		if err := sogen.Checkout(t, w); err != nil {
+			fmt.Fprint(w, "<b>Error:</b> "+html.EscapeString(err.Error()))
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gotsunami/sogenactif"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// runVersion prints the version of the build and, given a settings file,
+// the one of the SDK binaries.
+func runVersion(args []string) {
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s version [settings.conf]\n", os.Args[0])
+		os.Exit(2)
+	}
+	v := sogenactif.Version()
+	fmt.Printf("version:  %s\n", v.Version)
+	fmt.Printf("commit:   %s\n", v.Commit)
+	fmt.Printf("date:     %s\n", v.Date)
+	fmt.Printf("go:       %s\n", v.GoVersion)
+	if len(args) == 0 {
+		return
+	}
+	conf, err := sogenactif.LoadConfig(args[0])
+	if err != nil {
+		log.Fatal("config file error: " + err.Error())
+	}
+	log.SetOutput(ioutil.Discard)
+	conf.DryRun = true
+	sogen, err := sogenactif.NewSogen(conf)
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	l, err := sogen.Library()
+	if err != nil {
+		log.Fatal(err)
+	}
+	known := "unknown release"
+	if l.Known {
+		known = "known release"
+	}
+	fmt.Printf("platform: %s\n", l.Platform)
+	fmt.Printf("request:  %s (sha256 %s, %s)\n", l.RequestFile, l.RequestChecksum, known)
+	fmt.Printf("response: %s (sha256 %s)\n", l.ResponseFile, l.ResponseChecksum)
+}
@@ -0,0 +1,59 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenaws
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"io/ioutil"
+	"strings"
+)
+
+// Bucket is a sogenactif.ObjectStore reading the objects of an S3 bucket
+// under a prefix.
+type Bucket struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewBucket returns a store of the objects of bucket whose keys start with
+// prefix (i.e. "merchant/"). Object names are relative to the prefix.
+func NewBucket(client *s3.Client, bucket, prefix string) *Bucket {
+	return &Bucket{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (b *Bucket) List(ctx context.Context) ([]string, error) {
+	names := make([]string, 0)
+	p := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(b.prefix),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range page.Contents {
+			if name := strings.TrimPrefix(aws.ToString(o.Key), b.prefix); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+func (b *Bucket) Get(ctx context.Context, name string) ([]byte, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.prefix + name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return ioutil.ReadAll(out.Body)
+}
@@ -0,0 +1,47 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogenaws reads the merchant certificate from AWS Secrets
+// Manager, or the whole merchants root directory from S3:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	conf.CertificateSource = sogenaws.NewSource(secretsmanager.NewFromConfig(cfg), "prod/sogen/certificate")
+//	// or
+//	conf.MerchantsStore = sogenaws.NewBucket(s3.NewFromConfig(cfg), "my-bucket", "merchant/")
+//	sogen, err := sogenactif.NewSogen(conf)
+package sogenaws
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// Source is a sogenactif.CertificateSource reading a secret, either a
+// string secret or a binary one.
+type Source struct {
+	client *secretsmanager.Client
+	id     string
+}
+
+// NewSource returns a source reading the secret with the given name or
+// ARN.
+func NewSource(client *secretsmanager.Client, id string) *Source {
+	return &Source{client: client, id: id}
+}
+
+func (s *Source) Certificate(ctx context.Context) ([]byte, error) {
+	out, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(s.id)})
+	if err != nil {
+		return nil, err
+	}
+	if out.SecretString != nil {
+		return []byte(*out.SecretString), nil
+	}
+	if len(out.SecretBinary) > 0 {
+		return out.SecretBinary, nil
+	}
+	return nil, errors.New("aws: empty secret " + s.id)
+}
@@ -0,0 +1,33 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogenchi mounts the payment handlers of a Sogen instance on a chi
+// router:
+//
+//	r := chi.NewRouter()
+//	sogenchi.Register(r, sogen, conf, "/checkout", checkout, paid)
+//
+// chi handlers being plain net/http handlers, the Sogen.*Handler() methods
+// can also be used directly.
+package sogenchi
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/gotsunami/sogenactif"
+)
+
+// Register adds the checkout handler on path and the return, cancel and
+// auto response handlers on the paths of the URLs defined in the config.
+// The return URL answers both POST and GET, as some configurations return
+// buyers with GET. paid is called with every payment received.
+func Register(r chi.Router, s *sogenactif.Sogen, conf *sogenactif.Config, path string,
+	checkout sogenactif.TransactionFunc, paid sogenactif.PaymentFunc) {
+	r.Method("GET", path, s.CheckoutHandler(checkout))
+	r.Method("POST", conf.ReturnUrl.Path, s.ReturnHandler(paid))
+	r.Method("GET", conf.ReturnUrl.Path, s.ReturnHandler(paid))
+	r.Handle(conf.CancelUrl.Path, s.CancelHandler(paid))
+	if conf.AutoResponseUrl != nil {
+		r.Method("POST", conf.AutoResponseUrl.Path, s.AutoResponseHandler(paid))
+	}
+}
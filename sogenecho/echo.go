@@ -0,0 +1,62 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogenecho exposes the payment handlers of a Sogen instance as echo
+// handlers:
+//
+//	e := echo.New()
+//	sogenecho.Register(e, sogen, conf, "/checkout", checkout, paid)
+package sogenecho
+
+import (
+	"github.com/gotsunami/sogenactif"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// TransactionFunc returns the transaction to check out for a request.
+type TransactionFunc func(c echo.Context) (*sogenactif.Transaction, error)
+
+// Checkout returns a handler writing the checkout block of the transaction
+// returned by fn.
+func Checkout(s *sogenactif.Sogen, fn TransactionFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		h := s.CheckoutHandler(func(r *http.Request) (*sogenactif.Transaction, error) {
+			return fn(c)
+		})
+		h.ServeHTTP(c.Response(), c.Request())
+		return nil
+	}
+}
+
+// Return returns a handler for the return_url (see Sogen.ReturnHandler()).
+func Return(s *sogenactif.Sogen, fn sogenactif.PaymentFunc) echo.HandlerFunc {
+	return echo.WrapHandler(s.ReturnHandler(fn))
+}
+
+// Cancel returns a handler for the cancel_url (see Sogen.CancelHandler()).
+func Cancel(s *sogenactif.Sogen, fn sogenactif.PaymentFunc) echo.HandlerFunc {
+	return echo.WrapHandler(s.CancelHandler(fn))
+}
+
+// AutoResponse returns a handler for the auto_response_url (see
+// Sogen.AutoResponseHandler()).
+func AutoResponse(s *sogenactif.Sogen, fn sogenactif.PaymentFunc) echo.HandlerFunc {
+	return echo.WrapHandler(s.AutoResponseHandler(fn))
+}
+
+// Register adds the checkout handler on path and the return, cancel and
+// auto response handlers on the paths of the URLs defined in the config.
+// The return URL answers both POST and GET, as some configurations return
+// buyers with GET. paid is called with every payment received.
+func Register(e *echo.Echo, s *sogenactif.Sogen, conf *sogenactif.Config, path string,
+	checkout TransactionFunc, paid sogenactif.PaymentFunc) {
+	e.GET(path, Checkout(s, checkout))
+	e.POST(conf.ReturnUrl.Path, Return(s, paid))
+	e.GET(conf.ReturnUrl.Path, Return(s, paid))
+	e.Any(conf.CancelUrl.Path, Cancel(s, paid))
+	if conf.AutoResponseUrl != nil {
+		e.POST(conf.AutoResponseUrl.Path, AutoResponse(s, paid))
+	}
+}
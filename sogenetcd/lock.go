@@ -0,0 +1,90 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogenetcd provides an etcd-backed sogenactif.Locker and
+// sogenactif.Sequencer, for clustered deployments sharing a merchants root
+// directory:
+//
+//	client, err := clientv3.New(clientv3.Config{Endpoints: endpoints})
+//	conf.Locker = sogenetcd.NewLocker(client, "/sogen/locks/")
+//	sogen, err := sogenactif.NewSogen(conf)
+package sogenetcd
+
+import (
+	"context"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"strconv"
+	"time"
+)
+
+// Prefix of the sequence keys, after the prefix of the locker.
+const sequencePrefix = "seq/"
+
+// Locker is a sogenactif.Locker using etcd mutexes. Each lock is held in
+// a session whose lease expires after TTL seconds if its owner dies.
+type Locker struct {
+	client *clientv3.Client
+	prefix string
+	// TTL is the lease of a lock, in seconds, 60 by default.
+	TTL int
+}
+
+// NewLocker returns a locker storing its keys with the given prefix.
+func NewLocker(client *clientv3.Client, prefix string) *Locker {
+	return &Locker{client: client, prefix: prefix, TTL: 60}
+}
+
+func (l *Locker) Lock(ctx context.Context, name string) (func() error, error) {
+	session, err := concurrency.NewSession(l.client, concurrency.WithTTL(l.TTL))
+	if err != nil {
+		return nil, err
+	}
+	m := concurrency.NewMutex(session, l.prefix+name)
+	if err := m.Lock(ctx); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return func() error {
+		defer session.Close()
+		return m.Unlock(context.Background())
+	}, nil
+}
+
+// Next increments a sequence with a compare-and-swap on its key, retried
+// on conflicts. A new sequence is attached to a lease of ttl.
+func (l *Locker) Next(ctx context.Context, name string, ttl time.Duration) (int64, error) {
+	key := l.prefix + sequencePrefix + name
+	for {
+		resp, err := l.client.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		var n, rev int64
+		opt := clientv3.WithIgnoreLease()
+		if len(resp.Kvs) > 0 {
+			if n, err = strconv.ParseInt(string(resp.Kvs[0].Value), 10, 64); err != nil {
+				return 0, err
+			}
+			rev = resp.Kvs[0].ModRevision
+		} else {
+			lease, err := l.client.Grant(ctx, int64(ttl.Seconds()))
+			if err != nil {
+				return 0, err
+			}
+			opt = clientv3.WithLease(lease.ID)
+		}
+		n++
+		txn, err := l.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+			Then(clientv3.OpPut(key, strconv.FormatInt(n, 10), opt)).
+			Commit()
+		if err != nil {
+			return 0, err
+		}
+		if txn.Succeeded {
+			return n, nil
+		}
+	}
+}
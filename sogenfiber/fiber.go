@@ -0,0 +1,56 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogenfiber exposes the payment handlers of a Sogen instance as
+// fiber handlers:
+//
+//	app := fiber.New()
+//	sogenfiber.Register(app, sogen, conf, "/checkout", checkout, paid)
+//
+// Fiber is not built on net/http: requests are converted by the adaptor
+// middleware, so TransactionFunc gets a *http.Request.
+package sogenfiber
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gotsunami/sogenactif"
+)
+
+// Checkout returns a handler writing the checkout block of the transaction
+// returned by fn.
+func Checkout(s *sogenactif.Sogen, fn sogenactif.TransactionFunc) fiber.Handler {
+	return adaptor.HTTPHandler(s.CheckoutHandler(fn))
+}
+
+// Return returns a handler for the return_url (see Sogen.ReturnHandler()).
+func Return(s *sogenactif.Sogen, fn sogenactif.PaymentFunc) fiber.Handler {
+	return adaptor.HTTPHandler(s.ReturnHandler(fn))
+}
+
+// Cancel returns a handler for the cancel_url (see Sogen.CancelHandler()).
+func Cancel(s *sogenactif.Sogen, fn sogenactif.PaymentFunc) fiber.Handler {
+	return adaptor.HTTPHandler(s.CancelHandler(fn))
+}
+
+// AutoResponse returns a handler for the auto_response_url (see
+// Sogen.AutoResponseHandler()).
+func AutoResponse(s *sogenactif.Sogen, fn sogenactif.PaymentFunc) fiber.Handler {
+	return adaptor.HTTPHandler(s.AutoResponseHandler(fn))
+}
+
+// Register adds the checkout handler on path and the return, cancel and
+// auto response handlers on the paths of the URLs defined in the config.
+// The return URL answers both POST and GET, as some configurations return
+// buyers with GET. paid is called with every payment received.
+func Register(r fiber.Router, s *sogenactif.Sogen, conf *sogenactif.Config, path string,
+	checkout sogenactif.TransactionFunc, paid sogenactif.PaymentFunc) {
+	r.Get(path, Checkout(s, checkout))
+	r.Post(conf.ReturnUrl.Path, Return(s, paid))
+	r.Get(conf.ReturnUrl.Path, Return(s, paid))
+	r.All(conf.CancelUrl.Path, Cancel(s, paid))
+	if conf.AutoResponseUrl != nil {
+		r.Post(conf.AutoResponseUrl.Path, AutoResponse(s, paid))
+	}
+}
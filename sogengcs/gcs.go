@@ -0,0 +1,59 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogengcs reads the merchants root directory from a Google Cloud
+// Storage bucket:
+//
+//	client, err := storage.NewClient(ctx)
+//	conf.MerchantsStore = sogengcs.NewBucket(client.Bucket("my-bucket"), "merchant/")
+//	sogen, err := sogenactif.NewSogen(conf)
+package sogengcs
+
+import (
+	"cloud.google.com/go/storage"
+	"context"
+	"google.golang.org/api/iterator"
+	"io/ioutil"
+	"strings"
+)
+
+// Bucket is a sogenactif.ObjectStore reading the objects of a bucket under
+// a prefix.
+type Bucket struct {
+	bucket *storage.BucketHandle
+	prefix string
+}
+
+// NewBucket returns a store of the objects of bucket whose names start
+// with prefix (i.e. "merchant/"). Object names are relative to the prefix.
+func NewBucket(bucket *storage.BucketHandle, prefix string) *Bucket {
+	return &Bucket{bucket: bucket, prefix: prefix}
+}
+
+func (b *Bucket) List(ctx context.Context) ([]string, error) {
+	names := make([]string, 0)
+	it := b.bucket.Objects(ctx, &storage.Query{Prefix: b.prefix})
+	for {
+		o, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if name := strings.TrimPrefix(o.Name, b.prefix); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (b *Bucket) Get(ctx context.Context, name string) ([]byte, error) {
+	r, err := b.bucket.Object(b.prefix + name).NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,61 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogengin exposes the payment handlers of a Sogen instance as gin
+// handlers:
+//
+//	r := gin.Default()
+//	sogengin.Register(r, sogen, conf, "/checkout", checkout, paid)
+package sogengin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gotsunami/sogenactif"
+	"net/http"
+)
+
+// TransactionFunc returns the transaction to check out for a request.
+type TransactionFunc func(c *gin.Context) (*sogenactif.Transaction, error)
+
+// Checkout returns a handler writing the checkout block of the transaction
+// returned by fn.
+func Checkout(s *sogenactif.Sogen, fn TransactionFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := s.CheckoutHandler(func(r *http.Request) (*sogenactif.Transaction, error) {
+			return fn(c)
+		})
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// Return returns a handler for the return_url (see Sogen.ReturnHandler()).
+func Return(s *sogenactif.Sogen, fn sogenactif.PaymentFunc) gin.HandlerFunc {
+	return gin.WrapH(s.ReturnHandler(fn))
+}
+
+// Cancel returns a handler for the cancel_url (see Sogen.CancelHandler()).
+func Cancel(s *sogenactif.Sogen, fn sogenactif.PaymentFunc) gin.HandlerFunc {
+	return gin.WrapH(s.CancelHandler(fn))
+}
+
+// AutoResponse returns a handler for the auto_response_url (see
+// Sogen.AutoResponseHandler()).
+func AutoResponse(s *sogenactif.Sogen, fn sogenactif.PaymentFunc) gin.HandlerFunc {
+	return gin.WrapH(s.AutoResponseHandler(fn))
+}
+
+// Register adds the checkout handler on path and the return, cancel and
+// auto response handlers on the paths of the URLs defined in the config.
+// The return URL answers both POST and GET, as some configurations return
+// buyers with GET. paid is called with every payment received.
+func Register(r gin.IRoutes, s *sogenactif.Sogen, conf *sogenactif.Config, path string,
+	checkout TransactionFunc, paid sogenactif.PaymentFunc) {
+	r.GET(path, Checkout(s, checkout))
+	r.POST(conf.ReturnUrl.Path, Return(s, paid))
+	r.GET(conf.ReturnUrl.Path, Return(s, paid))
+	r.Any(conf.CancelUrl.Path, Cancel(s, paid))
+	if conf.AutoResponseUrl != nil {
+		r.POST(conf.AutoResponseUrl.Path, AutoResponse(s, paid))
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogengrpc provides a gRPC server for the payment operations of a
+// Sogen instance (see sogen.proto), so that services written in any language
+// can share a single Sogenactif integration host:
+//
+//	gs := grpc.NewServer()
+//	sogengrpc.Register(gs, sogengrpc.NewServer(sogen, store))
+//	gs.Serve(lis)
+//
+// The sogenpb package is generated from sogen.proto. Run go generate
+// after changing it (requires protoc, protoc-gen-go and protoc-gen-go-grpc).
+package sogengrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative -I . sogen.proto
+//go:generate sh -c "mkdir -p sogenpb && mv sogen.pb.go sogen_grpc.pb.go sogenpb/"
+
+import (
+	"context"
+	"github.com/gotsunami/sogenactif"
+	"github.com/gotsunami/sogenactif/sogengrpc/sogenpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Server implements the Sogen gRPC service.
+type Server struct {
+	sogenpb.UnimplementedSogenServer
+	sogen *sogenactif.Sogen
+	store sogenactif.PaymentStore
+}
+
+// NewServer returns a server for a Sogen instance. The store is used to look
+// up payments; it should be the one set with Sogen.SetPaymentStore().
+func NewServer(s *sogenactif.Sogen, store sogenactif.PaymentStore) *Server {
+	return &Server{sogen: s, store: store}
+}
+
+// Register registers the service on a gRPC server.
+func Register(gs *grpc.Server, srv *Server) {
+	sogenpb.RegisterSogenServer(gs, srv)
+}
+
+func (s *Server) CreateCheckout(ctx context.Context, req *sogenpb.CheckoutRequest) (*sogenpb.CheckoutResponse, error) {
+	t, err := s.sogen.NewTransaction(&sogenactif.Customer{Id: req.GetCustomerId(), Caddie: req.GetCaddie()},
+		float64(req.GetAmountCents())/100)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.GetOrderId() != "" {
+		if err := t.SetOrderId(req.GetOrderId()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	f, err := s.sogen.CheckoutForm(t)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	res := &sogenpb.CheckoutResponse{Action: f.Action, Fields: f.Fields}
+	for _, c := range f.Cards {
+		res.Cards = append(res.Cards, c.Name)
+	}
+	return res, nil
+}
+
+func (s *Server) ParseNotification(ctx context.Context, req *sogenpb.NotificationRequest) (*sogenpb.Payment, error) {
+	p, err := s.sogen.ParseResponse(ioutil.Discard, req.GetData())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return toPB(p), nil
+}
+
+func (s *Server) GetPayment(ctx context.Context, req *sogenpb.GetPaymentRequest) (*sogenpb.Payment, error) {
+	p, err := s.payment(req.GetTransactionId())
+	if err != nil {
+		return nil, err
+	}
+	return toPB(p), nil
+}
+
+func (s *Server) Refund(ctx context.Context, req *sogenpb.RefundRequest) (*sogenpb.RefundResponse, error) {
+	if _, err := s.payment(req.GetTransactionId()); err != nil {
+		return nil, err
+	}
+	r, err := s.sogen.Refund(ctx, req.GetTransactionId(), req.GetAmountCents())
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &sogenpb.RefundResponse{
+		TransactionId:  r.TransactionId,
+		AmountCents:    r.Amount,
+		RemainingCents: r.Remaining,
+		Status:         r.Status,
+	}, nil
+}
+
+func (s *Server) payment(transactionId string) (*sogenactif.Payment, error) {
+	if s.store == nil {
+		return nil, status.Error(codes.Unimplemented, "no payment store")
+	}
+	if transactionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing transaction ID")
+	}
+	p, err := s.store.Payment(transactionId)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return p, nil
+}
+
+func toPB(p *sogenactif.Payment) *sogenpb.Payment {
+	return &sogenpb.Payment{
+		MerchantId:         p.MerchantId,
+		MerchantCountry:    p.MerchantCountry,
+		AmountCents:        int64(math.Floor(p.Amount*100 + 0.5)),
+		TransactionId:      p.TransactionId,
+		PaymentMeans:       p.PaymentMeans,
+		TransmissionDate:   p.TransmissionDate.Format(time.RFC3339),
+		PaymentDate:        p.PaymentDate.Format(time.RFC3339),
+		ResponseCode:       p.ResponseCode,
+		PaymentCertificate: p.PaymentCertificate,
+		AuthorizationId:    p.AuthorizationId,
+		CurrencyCode:       p.CurrencyCode,
+		CardNumber:         p.CardNumber,
+		BankResponseCode:   p.BankResponseCode,
+		ComplementaryCode:  p.ComplementaryCode,
+		ComplementaryInfo:  p.ComplementaryInfo,
+		ReturnContext:      p.ReturnContext,
+		Caddie:             p.Caddie,
+		CustomerId:         p.CustomerId,
+		CustomerEmail:      p.CustomerEmail,
+		CustomerIpAddress:  p.CustomerIpAddress,
+		CaptureDay:         strconv.Itoa(p.CaptureDay),
+		CaptureMode:        string(p.CaptureMode),
+		Data:               p.Data,
+		OrderValidity:      p.OrderValidity,
+	}
+}
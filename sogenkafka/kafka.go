@@ -0,0 +1,44 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogenkafka publishes payment events to a Kafka topic:
+//
+//	w := &kafka.Writer{Addr: kafka.TCP("localhost:9092"), Topic: "payments"}
+//	sogen.PublishEvents(sogenkafka.NewPublisher(w))
+//
+// Events are JSON-encoded sogenactif.PaymentEvent values, keyed by
+// transaction ID so that all events of a payment go to the same partition.
+package sogenkafka
+
+import (
+	"context"
+	"github.com/gotsunami/sogenactif"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+// Publisher publishes payment events with a Kafka writer.
+type Publisher struct {
+	writer  *kafka.Writer
+	Timeout time.Duration // Timeout of a publication
+}
+
+// NewPublisher returns a publisher using w, which defines the topic.
+func NewPublisher(w *kafka.Writer) *Publisher {
+	return &Publisher{writer: w, Timeout: 10 * time.Second}
+}
+
+func (p *Publisher) Publish(e *sogenactif.PaymentEvent) error {
+	data, err := e.Marshal()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(e.Payment.TransactionId),
+		Value:   data,
+		Headers: []kafka.Header{{Key: "type", Value: []byte(e.Type)}},
+	})
+}
@@ -0,0 +1,37 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogennats publishes payment events to NATS:
+//
+//	nc, err := nats.Connect(nats.DefaultURL)
+//	sogen.PublishEvents(sogennats.NewPublisher(nc, "payments"))
+//
+// Events are JSON-encoded sogenactif.PaymentEvent values.
+package sogennats
+
+import (
+	"github.com/gotsunami/sogenactif"
+	"github.com/nats-io/nats.go"
+)
+
+// Publisher publishes payment events to a NATS subject.
+type Publisher struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewPublisher returns a publisher to subject. The event type is appended
+// to the subject (i.e. payments.payment.accepted), so that consumers can
+// subscribe to some event types only.
+func NewPublisher(conn *nats.Conn, subject string) *Publisher {
+	return &Publisher{conn: conn, subject: subject}
+}
+
+func (p *Publisher) Publish(e *sogenactif.PaymentEvent) error {
+	data, err := e.Marshal()
+	if err != nil {
+		return err
+	}
+	return p.conn.Publish(p.subject+"."+string(e.Type), data)
+}
@@ -0,0 +1,57 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogenredis provides a Redis-backed sogenactif.Counter, so that
+// velocity checks are shared by all instances of an application:
+//
+//	rules := &sogenactif.Rules{Velocity: &sogenactif.Velocity{
+//		Counter:        sogenredis.NewCounter(client, "sogen:velocity:"),
+//		MaxPerCustomer: 5,
+//		Window:         time.Hour,
+//	}}
+//
+// and a sogenactif.Locker (see Locker).
+package sogenredis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// Counter counts events in Redis sorted sets, one per key, scored by
+// event time.
+type Counter struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewCounter returns a counter storing its keys with the given prefix.
+func NewCounter(client *redis.Client, prefix string) *Counter {
+	return &Counter{client: client, prefix: prefix}
+}
+
+func (c *Counter) Hit(key string, window time.Duration) (int, error) {
+	ctx := context.Background()
+	now := time.Now().UnixNano()
+	// Unique member, events may happen at the same time
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+	member := strconv.FormatInt(now, 10) + "-" + hex.EncodeToString(b)
+	k := c.prefix + key
+	pipe := c.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, k, "-inf", strconv.FormatInt(now-window.Nanoseconds(), 10))
+	pipe.ZAdd(ctx, k, redis.Z{Score: float64(now), Member: member})
+	card := pipe.ZCard(ctx, k)
+	pipe.Expire(ctx, k, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(card.Val()), nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenredis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// Prefix of the sequence keys, after the prefix of the locker.
+const sequencePrefix = "seq:"
+
+// Deletes the lock key only if it still holds the token of the owner.
+const unlockScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`
+
+// Locker is a sogenactif.Locker backed by Redis keys, for instances
+// running on several hosts:
+//
+//	conf.Locker = sogenredis.NewLocker(client, "sogen:lock:")
+//
+// A lock expires after TTL if its owner dies without releasing it. The
+// locker is a sogenactif.Sequencer, allocating transaction IDs with INCR.
+type Locker struct {
+	client *redis.Client
+	prefix string
+	// TTL is the expiration of a lock, 1 minute by default.
+	TTL time.Duration
+	// Poll is the delay between attempts to acquire a lock, 100ms by
+	// default.
+	Poll time.Duration
+}
+
+// NewLocker returns a locker storing its keys with the given prefix.
+func NewLocker(client *redis.Client, prefix string) *Locker {
+	return &Locker{client: client, prefix: prefix, TTL: time.Minute, Poll: 100 * time.Millisecond}
+}
+
+func (l *Locker) Lock(ctx context.Context, name string) (func() error, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(b)
+	key := l.prefix + name
+	for {
+		ok, err := l.client.SetNX(ctx, key, token, l.TTL).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func() error {
+				return l.client.Eval(context.Background(), unlockScript, []string{key}, token).Err()
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.New("lock " + name + ": " + ctx.Err().Error())
+		case <-time.After(l.Poll):
+		}
+	}
+}
+
+func (l *Locker) Next(ctx context.Context, name string, ttl time.Duration) (int64, error) {
+	key := l.prefix + sequencePrefix + name
+	pipe := l.client.TxPipeline()
+	n := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return n.Val(), nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sogenvault reads the merchant certificate from HashiCorp Vault:
+//
+//	client, err := api.NewClient(api.DefaultConfig())
+//	conf.CertificateSource = sogenvault.NewSource(client, "secret/data/sogen", "certificate")
+//	sogen, err := sogenactif.NewSogen(conf)
+package sogenvault
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/hashicorp/vault/api"
+)
+
+// Source is a sogenactif.CertificateSource reading a secret field.
+type Source struct {
+	client *api.Client
+	path   string
+	field  string
+	// Base64 is set if the field holds the base64-encoded certificate.
+	Base64 bool
+}
+
+// NewSource returns a source reading field of the secret at path. Both
+// KV version 1 and 2 (secret/data/...) engines are supported.
+func NewSource(client *api.Client, path, field string) *Source {
+	return &Source{client: client, path: path, field: field}
+}
+
+func (s *Source) Certificate(ctx context.Context) ([]byte, error) {
+	secret, err := s.client.Logical().ReadWithContext(ctx, s.path)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("vault: no secret at " + s.path)
+	}
+	data := secret.Data
+	// KV version 2 nests the fields
+	if d, ok := data["data"].(map[string]interface{}); ok {
+		data = d
+	}
+	v, ok := data[s.field].(string)
+	if !ok || v == "" {
+		return nil, errors.New("vault: no field " + s.field + " in " + s.path)
+	}
+	if s.Base64 {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return []byte(v), nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package stats computes statistics over the payments of a PaymentStore:
+// acceptance rate by period, breakdown of the declines by response code,
+// average basket and volumes by payment means.
+//
+//	r, err := stats.Query(store, from, to, 24*time.Hour)
+//	fmt.Printf("%.1f%% accepted\n", r.Rate)
+//
+// Amounts are summed regardless of their currency.
+package stats
+
+import (
+	"errors"
+	"github.com/gotsunami/sogenactif"
+	"sort"
+	"time"
+)
+
+// Summary sums up a set of payments.
+type Summary struct {
+	Count    int     `json:"count"`
+	Accepted int     `json:"accepted"`
+	Rate     float64 `json:"rate"`    // Acceptance rate, in percent
+	Volume   float64 `json:"volume"`  // Accepted amount
+	Average  float64 `json:"average"` // Average accepted basket
+}
+
+func (s *Summary) add(p *sogenactif.Payment) {
+	s.Count++
+	if p.ResponseCode == "00" {
+		s.Accepted++
+		s.Volume += p.Amount
+	}
+}
+
+func (s *Summary) done() {
+	if s.Count > 0 {
+		s.Rate = 100 * float64(s.Accepted) / float64(s.Count)
+	}
+	if s.Accepted > 0 {
+		s.Average = s.Volume / float64(s.Accepted)
+	}
+}
+
+// Bucket sums up the payments of a period.
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Summary
+}
+
+// Decline is the number of payments declined with a response code.
+type Decline struct {
+	Code  string  `json:"code"`
+	Count int     `json:"count"`
+	Share float64 `json:"share"` // Share of the declines, in percent
+}
+
+// Means sums up the payments made with a payment means (i.e. CB, VISA).
+type Means struct {
+	Means string `json:"means"`
+	Summary
+}
+
+// Wallet sums up the payments made through a digital wallet (i.e.
+// PAYLIB). Wallet is empty for the payments made by entering card details.
+type Wallet struct {
+	Wallet string `json:"wallet"`
+	Summary
+}
+
+// Report holds the statistics of the payments made in [From, To).
+type Report struct {
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Interval time.Duration `json:"interval"`
+	Summary
+	Buckets  []*Bucket  `json:"buckets"`  // One per interval, if any
+	Declines []*Decline `json:"declines"` // Most frequent first
+	Means    []*Means   `json:"means"`    // Largest volume first
+	Wallets  []*Wallet  `json:"wallets"`  // Largest volume first
+}
+
+// Compute computes the statistics of payments made in [from, to), bucketed
+// by interval. There are no buckets if interval is zero.
+func Compute(payments []*sogenactif.Payment, from, to time.Time, interval time.Duration) *Report {
+	r := &Report{
+		From:     from,
+		To:       to,
+		Interval: interval,
+		Buckets:  make([]*Bucket, 0),
+		Declines: make([]*Decline, 0),
+		Means:    make([]*Means, 0),
+		Wallets:  make([]*Wallet, 0),
+	}
+	if interval > 0 {
+		for t := from; t.Before(to); t = t.Add(interval) {
+			r.Buckets = append(r.Buckets, &Bucket{Start: t})
+		}
+	}
+	declines := make(map[string]int)
+	means := make(map[string]*Means)
+	wallets := make(map[string]*Wallet)
+	for _, p := range payments {
+		if p.PaymentDate.Before(from) || !p.PaymentDate.Before(to) {
+			continue
+		}
+		r.add(p)
+		if interval > 0 {
+			r.Buckets[int(p.PaymentDate.Sub(from)/interval)].add(p)
+		}
+		if p.ResponseCode != "00" {
+			declines[p.ResponseCode]++
+		}
+		m, ok := means[p.PaymentMeans]
+		if !ok {
+			m = &Means{Means: p.PaymentMeans}
+			means[p.PaymentMeans] = m
+			r.Means = append(r.Means, m)
+		}
+		m.add(p)
+		w, ok := wallets[string(p.Wallet.Indicator)]
+		if !ok {
+			w = &Wallet{Wallet: string(p.Wallet.Indicator)}
+			wallets[w.Wallet] = w
+			r.Wallets = append(r.Wallets, w)
+		}
+		w.add(p)
+	}
+	r.done()
+	for _, b := range r.Buckets {
+		b.done()
+	}
+	for _, m := range r.Means {
+		m.done()
+	}
+	for _, w := range r.Wallets {
+		w.done()
+	}
+	for code, n := range declines {
+		r.Declines = append(r.Declines, &Decline{
+			Code:  code,
+			Count: n,
+			Share: 100 * float64(n) / float64(r.Count-r.Accepted),
+		})
+	}
+	sort.Slice(r.Declines, func(i, j int) bool {
+		if r.Declines[i].Count != r.Declines[j].Count {
+			return r.Declines[i].Count > r.Declines[j].Count
+		}
+		return r.Declines[i].Code < r.Declines[j].Code
+	})
+	sort.SliceStable(r.Means, func(i, j int) bool { return r.Means[i].Volume > r.Means[j].Volume })
+	sort.SliceStable(r.Wallets, func(i, j int) bool { return r.Wallets[i].Volume > r.Wallets[j].Volume })
+	return r
+}
+
+// Query computes the statistics of the payments of a store made in
+// [from, to) (see Compute()).
+func Query(store sogenactif.PaymentStore, from, to time.Time, interval time.Duration) (*Report, error) {
+	if store == nil {
+		return nil, errors.New("nil payment store")
+	}
+	if !from.Before(to) {
+		return nil, errors.New("empty period")
+	}
+	payments, err := store.Payments(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return Compute(payments, from, to, interval), nil
+}
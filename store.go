@@ -0,0 +1,145 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PaymentStore persists payments returned by the payment server and the
+// operations made on them afterwards. A transaction ID being unique over a
+// day only, lookups by transaction ID return the most recent payment.
+type PaymentStore interface {
+	// SavePayment creates or updates a payment.
+	SavePayment(p *Payment) error
+	// Payment returns the most recent payment with the given transaction ID.
+	Payment(transactionId string) (*Payment, error)
+	// Payments returns all payments made in [from, to), sorted by
+	// payment date.
+	Payments(from, to time.Time) ([]*Payment, error)
+	// SaveRefund records a refund.
+	SaveRefund(r *RefundResult) error
+	// Refunds returns all refunds made on a transaction.
+	Refunds(transactionId string) ([]*RefundResult, error)
+	// SaveCapture records a capture.
+	SaveCapture(c *CaptureResult) error
+	// Captures returns all captures made on a transaction.
+	Captures(transactionId string) ([]*CaptureResult, error)
+}
+
+// MemoryPaymentStore is an in-memory PaymentStore.
+type MemoryPaymentStore struct {
+	mu       sync.Mutex
+	payments []*Payment
+	refunds  []*RefundResult
+	captures []*CaptureResult
+}
+
+// NewMemoryPaymentStore returns an empty in-memory payment store.
+func NewMemoryPaymentStore() *MemoryPaymentStore {
+	return &MemoryPaymentStore{
+		payments: make([]*Payment, 0),
+		refunds:  make([]*RefundResult, 0),
+		captures: make([]*CaptureResult, 0),
+	}
+}
+
+func (m *MemoryPaymentStore) SavePayment(p *Payment) error {
+	if p == nil {
+		return errors.New("nil payment")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, cur := range m.payments {
+		if cur.TransactionId == p.TransactionId && cur.PaymentDate.Equal(p.PaymentDate) {
+			m.payments[k] = p
+			return nil
+		}
+	}
+	m.payments = append(m.payments, p)
+	return nil
+}
+
+func (m *MemoryPaymentStore) Payment(transactionId string) (*Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var found *Payment
+	for _, p := range m.payments {
+		if p.TransactionId == transactionId && (found == nil || p.PaymentDate.After(found.PaymentDate)) {
+			found = p
+		}
+	}
+	if found == nil {
+		return nil, errors.New("no payment with transaction ID " + transactionId)
+	}
+	return found, nil
+}
+
+func (m *MemoryPaymentStore) Payments(from, to time.Time) ([]*Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*Payment, 0)
+	for _, p := range m.payments {
+		if !p.PaymentDate.Before(from) && p.PaymentDate.Before(to) {
+			list = append(list, p)
+		}
+	}
+	sort.Sort(byPaymentDate(list))
+	return list, nil
+}
+
+// byPaymentDate sorts payments by payment date.
+type byPaymentDate []*Payment
+
+func (b byPaymentDate) Len() int           { return len(b) }
+func (b byPaymentDate) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byPaymentDate) Less(i, j int) bool { return b[i].PaymentDate.Before(b[j].PaymentDate) }
+
+func (m *MemoryPaymentStore) SaveRefund(r *RefundResult) error {
+	if r == nil {
+		return errors.New("nil refund")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refunds = append(m.refunds, r)
+	return nil
+}
+
+func (m *MemoryPaymentStore) Refunds(transactionId string) ([]*RefundResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*RefundResult, 0)
+	for _, r := range m.refunds {
+		if r.TransactionId == transactionId {
+			list = append(list, r)
+		}
+	}
+	return list, nil
+}
+
+func (m *MemoryPaymentStore) SaveCapture(c *CaptureResult) error {
+	if c == nil {
+		return errors.New("nil capture")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.captures = append(m.captures, c)
+	return nil
+}
+
+func (m *MemoryPaymentStore) Captures(transactionId string) ([]*CaptureResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*CaptureResult, 0)
+	for _, c := range m.captures {
+		if c.TransactionId == transactionId {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"path"
+)
+
+// Maximum length of an order ID.
+const maxOrderIdLen = 32
+
+// Card is a payment mean accepted by the merchant.
+type Card struct {
+	Name  string // Payment mean, i.e VISA
+	Title string // Display name of the payment mean, i.e. Visa
+	Logo  string // URL path of the logo
+}
+
+// SummaryData is passed to the order summary and checkout templates.
+type SummaryData struct {
+	Amount     float64
+	Currency   string // Alphabetic currency code, i.e EUR
+	Formatted  string // Amount with the currency, in the language of the page
+	Indicative string // Indicative price in the display currency, if any
+	OrderId    string
+	CustomerId string
+	Language   string        // Language of the page, i.e fr
+	Cards      []Card        // Accepted cards
+	Form       template.HTML // Form generated by the request binary
+	Debug      template.HTML // Debug output of the request binary, if DEBUG is set and no debug writer
+}
+
+// DefaultSummaryTemplate is the default order summary page.
+var DefaultSummaryTemplate = template.Must(template.New("summary").Funcs(TemplateFuncs).Parse(`<html lang="{{.Language}}">
+<head><title>{{tr "Order summary"}}</title></head>
+<body>
+<div style="text-align: center;">
+<h2>{{tr "Order summary"}}</h2>
+{{if .OrderId}}<p>{{tr "Order reference:"}} <b>{{.OrderId}}</b></p>{{end}}
+<p>{{tr "Amount:"}} <b>{{.Formatted}}</b>{{with .Indicative}} ({{printf (tr "Indicative price: %s") .}}){{end}}</p>
+<p>{{tr "Accepted cards:"}} {{range .Cards}}<img src="{{.Logo}}" alt="{{.Title}}"> {{end}}</p>
+<p>{{tr "Choose your card to proceed to the secure payment server:"}}</p>
+{{.Form}}
+</div>
+</body>
+</html>`))
+
+// SetOrderId sets the order ID of the transaction, sent back unmodified in
+// the response.
+func (t *Transaction) SetOrderId(id string) error {
+	if len(id) > maxOrderIdLen {
+		return errors.New("order ID too long")
+	}
+	t.orderId = id
+	return nil
+}
+
+// acceptedCards returns the payment means of the PAYMENT_MEANS setting.
+func (s *Sogen) acceptedCards() []Card {
+	cards := make([]Card, 0)
+	for _, code := range s.config.configMeans() {
+		m := paymentMean(code)
+		cards = append(cards, Card{Name: m.Code, Title: m.Name, Logo: path.Join(s.config.LogoPath, m.Logo)})
+	}
+	return cards
+}
+
+// summaryData returns the template data of a transaction.
+func (s *Sogen) summaryData(t *Transaction, form, debug string) *SummaryData {
+	code := s.config.MerchantCurrencyCode
+	if t.currencyCode != "" {
+		code = t.currencyCode
+	}
+	currency := code
+	if c, ok := LookupCurrency(code); ok {
+		currency = c.Alpha
+	}
+	lang := s.pageLanguage(t.language)
+	indicative := ""
+	if t.quote != nil {
+		indicative = t.quote.Format(lang)
+	}
+	return &SummaryData{
+		Amount:     t.amount,
+		Currency:   currency,
+		Formatted:  formatAmount(toCents(t.amount), code, lang),
+		Indicative: indicative,
+		OrderId:    t.orderId,
+		CustomerId: t.customer.Id,
+		Language:   lang,
+		Cards:      s.acceptedCards(),
+		Form:       template.HTML(form),
+		Debug:      template.HTML(debug),
+	}
+}
+
+// Summary renders an order summary page for a transaction, embedding the
+// form leading to the payment server. A nil tmpl uses the "summary"
+// template registered with SetTemplates(), or DefaultSummaryTemplate.
+func (s *Sogen) Summary(w io.Writer, t *Transaction, tmpl *template.Template) error {
+	if t == nil {
+		return errors.New("nil transaction")
+	}
+	if tmpl == nil {
+		tmpl = s.template(TemplateSummary)
+	}
+	if tmpl == nil {
+		tmpl = DefaultSummaryTemplate
+	}
+	var form bytes.Buffer
+	if err := s.Checkout(t, &form); err != nil {
+		return err
+	}
+	return executeIn(w, tmpl, s.pageLanguage(t.language), s.summaryData(t, form.String(), ""))
+}
+
+// SummaryHandler returns a handler rendering the order summary page of
+// pending transactions (see SetTransactionStore()). The last element of the
+// URL path is the token of the transaction. A nil tmpl uses
+// DefaultSummaryTemplate.
+func (s *Sogen) SummaryHandler(tmpl *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.transactions == nil {
+			http.Error(w, Translate(s.pageLanguage(""), "order summary not available"), http.StatusServiceUnavailable)
+			return
+		}
+		p, err := s.transactions.Transaction(path.Base(r.URL.Path))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if !p.Expires.IsZero() && s.now().After(p.Expires) {
+			http.Error(w, Translate(s.pageLanguage(p.Transaction.language), "this order has expired"), http.StatusGone)
+			return
+		}
+		var buf bytes.Buffer
+		// Summary() updates the transaction, which is shared by the visits
+		t := *p.Transaction
+		if err := s.Summary(&buf, &t, tmpl); err != nil {
+			http.Error(w, Translate(s.pageLanguage(p.Transaction.language), "payment is not available at the moment"), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"html/template"
+	"io"
+)
+
+// Names of the templates looked up in the set registered with SetTemplates().
+const (
+	// Checkout block, executed with a *SummaryData.
+	TemplateCheckout = "checkout"
+	// Order summary page, executed with a *SummaryData.
+	TemplateSummary = "summary"
+	// Payment accepted page, executed with a *Payment.
+	TemplateAccepted = "accepted"
+	// Payment declined page, executed with a *Payment.
+	TemplateDeclined = "declined"
+	// Page shown when the return URL is posted again, executed with a
+	// *Payment.
+	TemplateReplayed = "replayed"
+	// Cancellation page, executed with a *Payment (nil if the cancellation
+	// happened before any payment data was available).
+	TemplateCancelled = "cancelled"
+	// Logos of the accepted cards, executed with a []Card.
+	TemplateLogos = "logos"
+)
+
+// DefaultTemplates holds the default payment accepted, declined,
+// cancellation and replayed pages.
+var DefaultTemplates = template.Must(template.New(TemplateAccepted).Funcs(TemplateFuncs).Parse(`<html><body>
+<h2>{{tr "Thank you!"}}</h2>
+{{if .}}<p>{{printf (tr "Your payment of %s has been accepted (transaction %s).") (.FormatAmount "") .TransactionId}}</p>{{end}}
+</body></html>`))
+
+func init() {
+	template.Must(DefaultTemplates.New(TemplateDeclined).Parse(`<html><body>
+<h2>{{tr "Your payment has been declined."}}</h2>
+</body></html>`))
+	template.Must(DefaultTemplates.New(TemplateCancelled).Parse(`<html><body>
+<h2>{{tr "The transaction has been cancelled."}}</h2>
+</body></html>`))
+	template.Must(DefaultTemplates.New(TemplateReplayed).Parse(`<html><body>
+<h2>{{tr "This order has already been confirmed."}}</h2>
+</body></html>`))
+}
+
+// SetTemplates registers the templates used to render the checkout block,
+// the order summary, payment accepted, declined and cancellation pages. Templates
+// are looked up by name (see TemplateCheckout etc.); missing ones fall back
+// to the default rendering. Templates using tr must be parsed with
+// TemplateFuncs.
+func (s *Sogen) SetTemplates(t *template.Template) {
+	s.templates = t
+}
+
+// template returns the registered template with the given name, if any.
+func (s *Sogen) template(name string) *template.Template {
+	if s.templates == nil {
+		return nil
+	}
+	return s.templates.Lookup(name)
+}
+
+// Accepted renders the payment accepted page.
+func (s *Sogen) Accepted(w io.Writer, p *Payment) error {
+	if p == nil {
+		return errors.New("nil payment")
+	}
+	return s.renderPage(w, TemplateAccepted, p)
+}
+
+// Declined renders the payment declined page.
+func (s *Sogen) Declined(w io.Writer, p *Payment) error {
+	if p == nil {
+		return errors.New("nil payment")
+	}
+	return s.renderPage(w, TemplateDeclined, p)
+}
+
+// Replayed renders the page shown when the return URL is posted again
+// (see HandleReturn()).
+func (s *Sogen) Replayed(w io.Writer, p *Payment) error {
+	return s.renderPage(w, TemplateReplayed, p)
+}
+
+// Cancelled renders the cancellation page. p may be nil.
+func (s *Sogen) Cancelled(w io.Writer, p *Payment) error {
+	return s.renderPage(w, TemplateCancelled, p)
+}
+
+func (s *Sogen) renderPage(w io.Writer, name string, p *Payment) error {
+	tmpl := s.template(name)
+	if tmpl == nil {
+		tmpl = DefaultTemplates.Lookup(name)
+	}
+	lang := ""
+	if p != nil {
+		lang = p.Language
+	}
+	return executeIn(w, tmpl, s.pageLanguage(lang), p)
+}
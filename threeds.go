@@ -0,0 +1,227 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DATA directives of 3-D Secure. The challenge mode is sent in the
+// request, the others are sent back in the response of the payment
+// server when the card holder went through 3-D Secure.
+const (
+	threeDSChallengeKey = "3DS_CHALLENGE_MODE"
+	threeDSExemptionKey = "3DS_EXEMPTION"
+	threeDSProgramKey   = "HOLDER_AUTHENT_PROGRAM"
+	threeDSEnrolledKey  = "3D_ENROLLED"
+	threeDSStatusKey    = "HOLDER_AUTHENT_STATUS"
+	threeDSGuaranteeKey = "GUARANTEE_INDICATOR"
+	threeDSECIKey       = "ECI"
+)
+
+// ChallengeMode tells the issuer whether the merchant wants the card
+// holder to be challenged (3-D Secure v2). The issuer has the last word.
+type ChallengeMode string
+
+const (
+	ChallengeNoPreference ChallengeMode = "NO_PREFERENCE"
+	ChallengePreferred    ChallengeMode = "CHALLENGE_PREFERRED"
+	ChallengeMandated     ChallengeMode = "CHALLENGE_MANDATED"
+	ChallengeNotRequested ChallengeMode = "NO_CHALLENGE_REQUESTED"
+)
+
+// SetChallengeMode sets the challenge preference of the transaction. An
+// empty mode cancels any previous setting.
+func (t *Transaction) SetChallengeMode(m ChallengeMode) error {
+	switch m {
+	case "", ChallengeNoPreference, ChallengePreferred, ChallengeMandated, ChallengeNotRequested:
+	default:
+		return errors.New(fmt.Sprintf("3-D Secure: unknown challenge mode %q", m))
+	}
+	if m == ChallengeMandated && t.exemption != "" {
+		return errors.New("3-D Secure: a challenge can't be mandated with an exemption")
+	}
+	t.challenge = m
+	return nil
+}
+
+// ChallengeMode returns the challenge preference of the transaction, if
+// any.
+func (t *Transaction) ChallengeMode() ChallengeMode {
+	return t.challenge
+}
+
+// Exemption is a PSD2 exemption from strong customer authentication
+// requested for a transaction. The issuer may refuse it and challenge the
+// card holder anyway; the liability for fraud then stays with the
+// merchant (see Payment.LiabilityShifted()).
+type Exemption string
+
+const (
+	// Payment up to LowValueLimits. The issuer challenges the card holder
+	// after 5 exempted payments or 100 EUR in a row.
+	ExemptLowValue Exemption = "LOW_VALUE"
+	// Payment to a merchant the card holder trusted at their bank.
+	ExemptTrustedBeneficiary Exemption = "TRUSTED_BENEFICIARY"
+	// Payment initiated by the merchant without the card holder, i.e. a
+	// subscription renewal agreed on beforehand. Needs a customer ID.
+	ExemptMerchantInitiated Exemption = "MERCHANT_INITIATED"
+)
+
+// LowValueLimits are the maximum amounts of a low value exemption, by
+// currency code. Exemptions are refused in other currencies.
+var LowValueLimits = map[string]float64{
+	"978": 30,
+}
+
+// SetExemption requests an exemption from strong customer authentication.
+// An empty exemption cancels any previous request. The amount of a low
+// value exemption is checked again at checkout, in the charged currency.
+func (t *Transaction) SetExemption(e Exemption) error {
+	switch e {
+	case "":
+	case ExemptLowValue:
+		if t.currencyCode != "" {
+			if err := checkLowValue(t.amount, t.currencyCode); err != nil {
+				return err
+			}
+		}
+	case ExemptTrustedBeneficiary:
+	case ExemptMerchantInitiated:
+		if t.customer.Id == "" {
+			return errors.New("3-D Secure: a merchant-initiated payment requires a customer ID")
+		}
+	default:
+		return errors.New(fmt.Sprintf("3-D Secure: unknown exemption %q", e))
+	}
+	if e != "" && t.challenge == ChallengeMandated {
+		return errors.New("3-D Secure: a challenge is mandated")
+	}
+	t.exemption = e
+	return nil
+}
+
+// Exemption returns the exemption requested for the transaction, if any.
+func (t *Transaction) Exemption() Exemption {
+	return t.exemption
+}
+
+// checkLowValue checks the amount of a low value exemption.
+func checkLowValue(amount float64, code string) error {
+	limit, ok := LowValueLimits[code]
+	if !ok {
+		return errors.New("3-D Secure: no low value exemption for currency " + code)
+	}
+	if amount > limit {
+		return errors.New(fmt.Sprintf("3-D Secure: %.2f exceeds the low value exemption limit of %.2f", amount, limit))
+	}
+	return nil
+}
+
+// checkExemption checks the exemption of a transaction before checkout.
+func (s *Sogen) checkExemption(t *Transaction) error {
+	if t.exemption != ExemptLowValue {
+		return nil
+	}
+	return checkLowValue(t.amount, s.chargedCurrency(t))
+}
+
+// threeDSDirectives returns the DATA directives related to 3-D Secure, if
+// any.
+func (t *Transaction) threeDSDirectives() string {
+	d := make([]string, 0)
+	if t.challenge != "" {
+		d = append(d, threeDSChallengeKey+"="+string(t.challenge))
+	}
+	if t.exemption != "" {
+		d = append(d, threeDSExemptionKey+"="+string(t.exemption))
+	}
+	return strings.Join(d, ";")
+}
+
+// Enrollment tells whether the card is enrolled in 3-D Secure.
+type Enrollment string
+
+const (
+	Enrolled          Enrollment = "Y"
+	NotEnrolled       Enrollment = "N"
+	EnrollmentUnknown Enrollment = "U"
+)
+
+// AuthStatus is the outcome of the authentication of the card holder.
+type AuthStatus string
+
+const (
+	AuthSuccess     AuthStatus = "SUCCESS"      // Authenticated, with or without challenge
+	AuthAttempt     AuthStatus = "ATTEMPT"      // Issuer not participating, an attempt was proved
+	AuthFailure     AuthStatus = "FAILURE"      // Authentication failed
+	AuthNotEnrolled AuthStatus = "NOT_ENROLLED" // Card not enrolled
+	AuthError       AuthStatus = "ERROR"        // Technical error, not authenticated
+	AuthBypassed    AuthStatus = "BYPASSED"     // Not attempted, i.e. an exemption was granted
+)
+
+// ThreeDS holds the 3-D Secure indicators of a payment.
+type ThreeDS struct {
+	Program    string     // i.e. 3DS_V2
+	Enrollment Enrollment // Empty if unknown
+	Status     AuthStatus
+	// Guarantee is the liability shift indicator of the payment server:
+	// Y if the issuer bears the fraud chargebacks, N if not, U if unknown.
+	Guarantee string
+	ECI       string // Electronic commerce indicator
+}
+
+// parseThreeDS reads the 3-D Secure indicators sent back by the payment
+// server, if any.
+func parseThreeDS(data string) *ThreeDS {
+	d := new(ThreeDS)
+	found := false
+	for key, v := range map[string]*string{
+		threeDSProgramKey:   &d.Program,
+		threeDSGuaranteeKey: &d.Guarantee,
+		threeDSECIKey:       &d.ECI,
+	} {
+		if s, ok := dataDirective(data, key); ok {
+			*v = s
+			found = true
+		}
+	}
+	if s, ok := dataDirective(data, threeDSEnrolledKey); ok {
+		d.Enrollment = Enrollment(s)
+		found = true
+	}
+	if s, ok := dataDirective(data, threeDSStatusKey); ok {
+		d.Status = AuthStatus(s)
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return d
+}
+
+// Authenticated reports whether the card holder was authenticated with
+// 3-D Secure, including attempts.
+func (p *Payment) Authenticated() bool {
+	if p.ThreeDS == nil {
+		return false
+	}
+	return p.ThreeDS.Status == AuthSuccess || p.ThreeDS.Status == AuthAttempt
+}
+
+// LiabilityShifted reports whether the liability for fraud chargebacks
+// shifted to the issuer. The guarantee indicator is used if sent, else the
+// authentication status.
+func (p *Payment) LiabilityShifted() bool {
+	if p.ThreeDS == nil {
+		return false
+	}
+	if p.ThreeDS.Guarantee != "" {
+		return p.ThreeDS.Guarantee == "Y"
+	}
+	return p.Authenticated()
+}
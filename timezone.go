@@ -0,0 +1,44 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"time"
+	// Europe/Paris must load on hosts without a tz database
+	_ "time/tzdata"
+)
+
+// DefaultTimezone is the timezone of the dates sent by the payment
+// server.
+const DefaultTimezone = "Europe/Paris"
+
+var defaultLocation = mustLoadLocation(DefaultTimezone)
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// location returns the timezone of the dates of the payment server.
+func (c *Config) location() *time.Location {
+	if c.Location != nil {
+		return c.Location
+	}
+	return defaultLocation
+}
+
+// parseDate parses a YYYYMMDDhhmmss date of the payment server, in its
+// timezone. The offset depends on the date: +01:00 in winter (CET), +02:00
+// in summer (CEST). The hour repeated when DST ends is taken as CET.
+func parseDate(dt string, loc *time.Location) (time.Time, error) {
+	if len(dt) != 14 {
+		return time.Time{}, errors.New("expected a YYYYMMDDhhmmss date, got " + dt)
+	}
+	return time.ParseInLocation("20060102150405", dt, loc)
+}
@@ -0,0 +1,72 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateDST(t *testing.T) {
+	tests := []struct {
+		name string
+		date string
+		utc  string // Expected instant, RFC 3339 in UTC
+	}{
+		{"winter", "20130115120000", "2013-01-15T11:00:00Z"},
+		{"summer", "20130715120000", "2013-07-15T10:00:00Z"},
+		// Last Sunday of March: 02:00 CET jumps to 03:00 CEST
+		{"before spring forward", "20130331015959", "2013-03-31T00:59:59Z"},
+		{"skipped hour", "20130331023000", "2013-03-31T01:30:00Z"},
+		{"after spring forward", "20130331030000", "2013-03-31T01:00:00Z"},
+		// Last Sunday of October: 03:00 CEST goes back to 02:00 CET
+		{"before fall back", "20131027015959", "2013-10-26T23:59:59Z"},
+		{"repeated hour", "20131027023000", "2013-10-27T01:30:00Z"},
+		{"after fall back", "20131027030000", "2013-10-27T02:00:00Z"},
+		{"day after fall back", "20131028000000", "2013-10-27T23:00:00Z"},
+	}
+	for _, tt := range tests {
+		got, err := parseDate(tt.date, defaultLocation)
+		if err != nil {
+			t.Errorf("%s: parseDate(%q): %s", tt.name, tt.date, err)
+			continue
+		}
+		if s := got.UTC().Format(time.RFC3339); s != tt.utc {
+			t.Errorf("%s: parseDate(%q) = %s, want %s", tt.name, tt.date, s, tt.utc)
+		}
+	}
+}
+
+func TestParseDateLocation(t *testing.T) {
+	tests := []struct {
+		tz   string
+		date string
+		utc  string
+	}{
+		{"UTC", "20130331023000", "2013-03-31T02:30:00Z"},
+		{"America/New_York", "20130310013000", "2013-03-10T06:30:00Z"},
+		{"America/New_York", "20130310033000", "2013-03-10T07:30:00Z"},
+		{"America/New_York", "20131103003000", "2013-11-03T04:30:00Z"},
+		{"America/New_York", "20131103023000", "2013-11-03T07:30:00Z"},
+	}
+	for _, tt := range tests {
+		got, err := parseDate(tt.date, mustLoadLocation(tt.tz))
+		if err != nil {
+			t.Errorf("%s: parseDate(%q): %s", tt.tz, tt.date, err)
+			continue
+		}
+		if s := got.UTC().Format(time.RFC3339); s != tt.utc {
+			t.Errorf("%s: parseDate(%q) = %s, want %s", tt.tz, tt.date, s, tt.utc)
+		}
+	}
+}
+
+func TestParseDateMalformed(t *testing.T) {
+	for _, date := range []string{"", "20130331", "2013033102300", "201303310230000", "2013-03-31 02:30"} {
+		if _, err := parseDate(date, defaultLocation); err == nil {
+			t.Errorf("parseDate(%q): expected an error", date)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Version of the serialized form of a transaction.
+const transactionVersion = 1
+
+// OrderId returns the order ID of the transaction, if any.
+func (t *Transaction) OrderId() string {
+	return t.orderId
+}
+
+// TransactionId returns the transaction ID set with SetTransactionId(),
+// if any.
+func (t *Transaction) TransactionId() string {
+	return t.transId
+}
+
+// CurrencyCode returns the currency set with SetCurrencyCode(), if any.
+func (t *Transaction) CurrencyCode() string {
+	return t.currencyCode
+}
+
+// Language returns the language of the payment pages, if any.
+func (t *Transaction) Language() string {
+	return t.language
+}
+
+// OrderChannel returns the order channel of the transaction, if any.
+func (t *Transaction) OrderChannel() OrderChannel {
+	return t.orderChannel
+}
+
+// Capture returns the capture mode, if any, and the capture day(s).
+func (t *Transaction) Capture() (CaptureMode, int) {
+	return t.captureMode, t.captureDay
+}
+
+// Installments returns a copy of the payment in N times of the
+// transaction, if any.
+func (t *Transaction) Installments() *Installments {
+	if t.installments == nil {
+		return nil
+	}
+	c := *t.installments
+	return &c
+}
+
+// customerData is the serialized form of a Customer.
+type customerData struct {
+	Id           string `json:"id,omitempty"`
+	Caddie       string `json:"caddie,omitempty"`
+	CancelUrl    string `json:"cancel_url,omitempty"`
+	ReturnUrl    string `json:"return_url,omitempty"`
+	AutomaticUrl string `json:"automatic_url,omitempty"`
+	Data         string `json:"data,omitempty"`
+	IpAddress    string `json:"ip_address,omitempty"`
+	Email        string `json:"email,omitempty"`
+}
+
+// transactionData is the serialized form of a Transaction.
+type transactionData struct {
+	Version      int           `json:"v"`
+	Customer     customerData  `json:"customer"`
+	Amount       float64       `json:"amount"`
+	Installments *Installments `json:"installments,omitempty"`
+	CreateAlias  bool          `json:"create_alias,omitempty"`
+	Alias        string        `json:"alias,omitempty"`
+	CaptureMode  CaptureMode   `json:"capture_mode,omitempty"`
+	CaptureDay   int           `json:"capture_day,omitempty"`
+	OrderChannel OrderChannel  `json:"order_channel,omitempty"`
+	Means        string        `json:"payment_means,omitempty"`
+	Wallet       WalletOptions `json:"wallet"`
+	Display      string        `json:"display_currency,omitempty"`
+	Challenge    ChallengeMode `json:"challenge_mode,omitempty"`
+	Exemption    Exemption     `json:"exemption,omitempty"`
+	OrderId      string        `json:"order_id,omitempty"`
+	Session      string        `json:"session,omitempty"`
+	RequestId    string        `json:"request_id,omitempty"`
+	CurrencyCode string        `json:"currency_code,omitempty"`
+	Language     string        `json:"language,omitempty"`
+	ReturnCtx    string        `json:"return_context,omitempty"`
+	TransId      string        `json:"transaction_id,omitempty"`
+	CancelUrl    string        `json:"cancel_url,omitempty"`
+	ReturnUrl    string        `json:"return_url,omitempty"`
+	AutoUrl      string        `json:"auto_response_url,omitempty"`
+	ReturnQuery  url.Values    `json:"return_query,omitempty"`
+}
+
+func urlString(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+func parseOptionalUrl(name, s string) (*url.URL, error) {
+	if s == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.New(name + ": " + err.Error())
+	}
+	return u, nil
+}
+
+// MarshalJSON serializes the transaction and its options, i.e. to be
+// resumed on another node (see ResumeCheckout()).
+func (t *Transaction) MarshalJSON() ([]byte, error) {
+	c := t.customer
+	if c == nil {
+		return nil, errors.New("nil customer")
+	}
+	return json.Marshal(&transactionData{
+		Version: transactionVersion,
+		Customer: customerData{
+			Id:           c.Id,
+			Caddie:       c.Caddie,
+			CancelUrl:    urlString(c.CancelUrl),
+			ReturnUrl:    urlString(c.ReturnUrl),
+			AutomaticUrl: urlString(c.AutomaticUrl),
+			Data:         c.Data,
+			IpAddress:    c.IpAddress,
+			Email:        c.Email,
+		},
+		Amount:       t.amount,
+		Installments: t.installments,
+		CreateAlias:  t.createAlias,
+		Alias:        t.alias,
+		CaptureMode:  t.captureMode,
+		CaptureDay:   t.captureDay,
+		OrderChannel: t.orderChannel,
+		Means:        t.means,
+		Wallet:       t.wallet,
+		Display:      t.display,
+		Challenge:    t.challenge,
+		Exemption:    t.exemption,
+		OrderId:      t.orderId,
+		Session:      t.session,
+		RequestId:    t.requestId,
+		CurrencyCode: t.currencyCode,
+		Language:     t.language,
+		ReturnCtx:    t.returnCtx,
+		TransId:      t.transId,
+		CancelUrl:    urlString(t.cancelUrl),
+		ReturnUrl:    urlString(t.returnUrl),
+		AutoUrl:      urlString(t.autoUrl),
+		ReturnQuery:  t.returnQuery,
+	})
+}
+
+// UnmarshalJSON restores a transaction serialized with MarshalJSON().
+func (t *Transaction) UnmarshalJSON(b []byte) error {
+	var d transactionData
+	if err := json.Unmarshal(b, &d); err != nil {
+		return err
+	}
+	if d.Version != transactionVersion {
+		return errors.New(fmt.Sprintf("unsupported transaction version %d", d.Version))
+	}
+	if err := validateAmount(d.Amount); err != nil {
+		return err
+	}
+	if strings.ContainsAny(d.Alias, ";=!") {
+		return errors.New(fmt.Sprintf("invalid wallet alias %q", d.Alias))
+	}
+	c := &Customer{
+		Id:        d.Customer.Id,
+		Caddie:    d.Customer.Caddie,
+		Data:      d.Customer.Data,
+		IpAddress: d.Customer.IpAddress,
+		Email:     d.Customer.Email,
+	}
+	var err error
+	if c.CancelUrl, err = parseOptionalUrl("customer cancel URL", d.Customer.CancelUrl); err != nil {
+		return err
+	}
+	if c.ReturnUrl, err = parseOptionalUrl("customer return URL", d.Customer.ReturnUrl); err != nil {
+		return err
+	}
+	if c.AutomaticUrl, err = parseOptionalUrl("customer automatic URL", d.Customer.AutomaticUrl); err != nil {
+		return err
+	}
+	nt := Transaction{
+		customer:     c,
+		amount:       d.Amount,
+		installments: d.Installments,
+		createAlias:  d.CreateAlias,
+		alias:        d.Alias,
+		captureMode:  d.CaptureMode,
+		captureDay:   d.CaptureDay,
+		orderChannel: d.OrderChannel,
+		means:        d.Means,
+		wallet:       d.Wallet,
+		display:      d.Display,
+		challenge:    d.Challenge,
+		exemption:    d.Exemption,
+		orderId:      d.OrderId,
+		session:      d.Session,
+		requestId:    d.RequestId,
+		currencyCode: d.CurrencyCode,
+		language:     d.Language,
+		returnCtx:    d.ReturnCtx,
+		transId:      d.TransId,
+		returnQuery:  d.ReturnQuery,
+	}
+	if nt.cancelUrl, err = parseOptionalUrl("cancel URL", d.CancelUrl); err != nil {
+		return err
+	}
+	if nt.returnUrl, err = parseOptionalUrl("return URL", d.ReturnUrl); err != nil {
+		return err
+	}
+	if nt.autoUrl, err = parseOptionalUrl("auto response URL", d.AutoUrl); err != nil {
+		return err
+	}
+	*t = nt
+	return nil
+}
+
+// GobEncode serializes the transaction for encoding/gob, like
+// MarshalJSON().
+func (t *Transaction) GobEncode() ([]byte, error) {
+	return t.MarshalJSON()
+}
+
+// GobDecode restores a transaction serialized with GobEncode().
+func (t *Transaction) GobDecode(b []byte) error {
+	return t.UnmarshalJSON(b)
+}
+
+// ResumeCheckout writes the payment form of a transaction serialized with
+// MarshalJSON(), i.e. on another node than the one which started the
+// checkout. The transaction is returned.
+func (s *Sogen) ResumeCheckout(w io.Writer, data []byte) (*Transaction, error) {
+	t := new(Transaction)
+	if err := t.UnmarshalJSON(data); err != nil {
+		return nil, errors.New("resume checkout: " + err.Error())
+	}
+	if err := s.Checkout(t, w); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
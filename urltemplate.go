@@ -0,0 +1,118 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Placeholders of the cancel, return and auto response URLs, expanded at
+// checkout with the values of the transaction, i.e.
+// return_url=https://shop.example.com/orders/{order_id}/paid
+const (
+	PlaceholderTransactionId = "{transaction_id}"
+	PlaceholderCustomerId    = "{customer_id}"
+	PlaceholderOrderId       = "{order_id}"
+)
+
+// SetTransactionId sets the ID of the transaction, 6 digits unique for the
+// merchant over the day. The payment server sets one if empty.
+func (t *Transaction) SetTransactionId(id string) error {
+	if len(id) != 6 || strings.Trim(id, "0123456789") != "" {
+		return errors.New("transaction ID must be 6 digits")
+	}
+	t.transId = id
+	return nil
+}
+
+// SetCancelUrl overrides the cancel URL of the customer and of the config
+// for this transaction. It may hold placeholders.
+func (t *Transaction) SetCancelUrl(u *url.URL) {
+	t.cancelUrl = u
+}
+
+// SetReturnUrl overrides the return URL of the customer and of the config
+// for this transaction. It may hold placeholders.
+func (t *Transaction) SetReturnUrl(u *url.URL) {
+	t.returnUrl = u
+}
+
+// SetAutoResponseUrl overrides the auto response URL of the customer and
+// of the config for this transaction. It may hold placeholders.
+func (t *Transaction) SetAutoResponseUrl(u *url.URL) {
+	t.autoUrl = u
+}
+
+// transactionUrl returns the URL of a transaction sent as a request
+// parameter: the one of the transaction, else the one of the customer,
+// else the one of the config if it holds placeholders (the parcom file has
+// it otherwise). Placeholders are expanded. The return query of the
+// transaction is merged if withQuery is true.
+func (t *Transaction) transactionUrl(tu, cu, conf *url.URL, withQuery bool) string {
+	u := tu
+	if u == nil {
+		u = cu
+	}
+	values := map[string]string{
+		PlaceholderTransactionId: t.transId,
+		PlaceholderCustomerId:    t.customer.Id,
+		PlaceholderOrderId:       t.orderId,
+	}
+	if u == nil {
+		if conf == nil {
+			return ""
+		}
+		u = conf
+	}
+	if withQuery && len(t.returnQuery) > 0 {
+		u = MergeQuery(u, t.returnQuery)
+	}
+	s := u.String()
+	expanded := s
+	for k, v := range values {
+		v = url.QueryEscape(v)
+		expanded = strings.Replace(expanded, k, v, -1)
+		// Braces are escaped in paths
+		expanded = strings.Replace(expanded, url.PathEscape(k), v, -1)
+	}
+	if u == conf && expanded == s {
+		// Unchanged config URL
+		return ""
+	}
+	return expanded
+}
+
+// MergeQuery returns a copy of u with the parameters of v added to its
+// query string, replacing the ones of the same name. The other parameters
+// are kept in order, with their original encoding.
+func MergeQuery(u *url.URL, v url.Values) *url.URL {
+	m := *u
+	if len(v) == 0 {
+		return &m
+	}
+	kept := make([]string, 0)
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, err := url.QueryUnescape(strings.SplitN(pair, "=", 2)[0])
+		if _, ok := v[name]; ok && err == nil {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	kept = append(kept, v.Encode())
+	m.RawQuery = strings.Join(kept, "&")
+	return &m
+}
+
+// SetReturnQuery sets parameters added to the query string of the cancel
+// and return URLs of the transaction (see MergeQuery()), which the browser
+// of the buyer sends back.
+func (t *Transaction) SetReturnQuery(v url.Values) {
+	t.returnQuery = v
+}
@@ -0,0 +1,145 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter counts events per key over sliding windows.
+type Counter interface {
+	// Hit records an event for key and returns the number of events of
+	// key over the last window, including this one.
+	Hit(key string, window time.Duration) (int, error)
+}
+
+// Idle keys of the in-memory counters and rate limiters are dropped at
+// most this often.
+const sweepInterval = time.Minute
+
+// Default window of velocity checks.
+const defaultVelocityWindow = time.Hour
+
+// MemoryCounter is an in-memory Counter. Keys without events over their
+// window are dropped periodically.
+type MemoryCounter struct {
+	// Clock is the source of the current time, i.e. Config.Clock.
+	// Defaults to the system time.
+	Clock  Clock
+	mu     sync.Mutex
+	events map[string]*counterEvents
+	swept  time.Time
+}
+
+type counterEvents struct {
+	times  []time.Time
+	window time.Duration
+}
+
+// NewMemoryCounter returns an empty in-memory counter.
+func NewMemoryCounter() *MemoryCounter {
+	return &MemoryCounter{events: make(map[string]*counterEvents)}
+}
+
+func (m *MemoryCounter) Hit(key string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if m.Clock != nil {
+		now = m.Clock.Now()
+	}
+	if now.Sub(m.swept) >= sweepInterval {
+		m.sweep(now)
+	}
+	ev, ok := m.events[key]
+	if !ok {
+		ev = &counterEvents{}
+		m.events[key] = ev
+	}
+	times := make([]time.Time, 0, len(ev.times)+1)
+	for _, t := range ev.times {
+		if now.Sub(t) < window {
+			times = append(times, t)
+		}
+	}
+	ev.times = append(times, now)
+	ev.window = window
+	return len(ev.times), nil
+}
+
+// sweep drops the keys whose last event is out of their window.
+func (m *MemoryCounter) sweep(now time.Time) {
+	for k, ev := range m.events {
+		if now.Sub(ev.times[len(ev.times)-1]) >= ev.window {
+			delete(m.events, k)
+		}
+	}
+	m.swept = now
+}
+
+// Velocity limits the number of checkout attempts per customer and per IP
+// address over a sliding window, against card testing. Zero limits are
+// disabled.
+type Velocity struct {
+	Counter        Counter // Defaults to an in-memory counter
+	MaxPerCustomer int
+	MaxPerIP       int
+	Window         time.Duration // Defaults to an hour
+	once           sync.Once
+	customerDenied int64
+	ipDenied       int64
+}
+
+// VelocityStats holds the number of attempts rejected by velocity checks.
+type VelocityStats struct {
+	Customer int64 // Rejected per customer ID
+	IP       int64 // Rejected per IP address
+}
+
+// Check records a checkout attempt and denies it beyond the limits.
+func (v *Velocity) Check(t *Transaction) (*Verdict, error) {
+	v.once.Do(func() {
+		if v.Counter == nil {
+			v.Counter = NewMemoryCounter()
+		}
+	})
+	window := v.Window
+	if window <= 0 {
+		window = defaultVelocityWindow
+	}
+	if v.MaxPerCustomer > 0 && t.customer.Id != "" {
+		n, err := v.Counter.Hit("customer:"+t.customer.Id, window)
+		if err != nil {
+			return nil, err
+		}
+		if n > v.MaxPerCustomer {
+			atomic.AddInt64(&v.customerDenied, 1)
+			return &Verdict{Deny, fmt.Sprintf("%d attempts for customer %s", n, t.customer.Id)}, nil
+		}
+	}
+	if ip := net.ParseIP(t.customer.IpAddress); v.MaxPerIP > 0 && ip != nil {
+		n, err := v.Counter.Hit("ip:"+ip.String(), window)
+		if err != nil {
+			return nil, err
+		}
+		if n > v.MaxPerIP {
+			atomic.AddInt64(&v.ipDenied, 1)
+			return &Verdict{Deny, fmt.Sprintf("%d attempts from %s", n, ip)}, nil
+		}
+	}
+	return &Verdict{Decision: Allow}, nil
+}
+
+// Stats returns the number of attempts rejected so far.
+func (v *Velocity) Stats() VelocityStats {
+	return VelocityStats{
+		Customer: atomic.LoadInt64(&v.customerDenied),
+		IP:       atomic.LoadInt64(&v.ipDenied),
+	}
+}
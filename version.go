@@ -0,0 +1,105 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+const modulePath = "github.com/gotsunami/sogenactif"
+
+// Build metadata overriding the one recorded by the go tool, if set at
+// link time:
+//
+//	go build -ldflags "-X github.com/gotsunami/sogenactif.buildCommit=$(git rev-parse HEAD)"
+var (
+	buildVersion string
+	buildCommit  string
+	buildDate    string
+)
+
+// VersionInfo describes the build of the package.
+type VersionInfo struct {
+	Version   string // Module version, (devel) if built from a checkout
+	Commit    string // VCS revision, if known
+	Date      string // Build or commit date, if known
+	GoVersion string
+}
+
+func (v *VersionInfo) String() string {
+	s := fmt.Sprintf("sogenactif %s", v.Version)
+	if v.Commit != "" {
+		s += " (" + v.Commit
+		if v.Date != "" {
+			s += ", " + v.Date
+		}
+		s += ")"
+	}
+	return s + " " + v.GoVersion
+}
+
+// Version returns the version of the package, from the build info of the
+// binary.
+func Version() *VersionInfo {
+	v := &VersionInfo{Version: "(devel)", GoVersion: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == modulePath {
+			v.Version = info.Main.Version
+			// VCS settings are only recorded for the main module
+			for _, s := range info.Settings {
+				switch s.Key {
+				case "vcs.revision":
+					v.Commit = s.Value
+				case "vcs.time":
+					v.Date = s.Value
+				}
+			}
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				v.Version = dep.Version
+			}
+		}
+	}
+	if buildVersion != "" {
+		v.Version = buildVersion
+	}
+	if buildCommit != "" {
+		v.Commit = buildCommit
+	}
+	if buildDate != "" {
+		v.Date = buildDate
+	}
+	return v
+}
+
+// LibraryInfo describes the binaries of the SDK in use.
+type LibraryInfo struct {
+	Platform         string // Platform directory, i.e. linux_amd64
+	RequestFile      string
+	ResponseFile     string
+	RequestChecksum  string // SHA-256 checksum
+	ResponseChecksum string
+	// Known is set if the checksums match the ones of BinaryChecksums,
+	// i.e. the binaries are the ones of the supported SDK release.
+	Known bool
+}
+
+// Library returns information about the binaries of the SDK in use.
+func (s *Sogen) Library() (*LibraryInfo, error) {
+	l := &LibraryInfo{Platform: s.platform, RequestFile: s.requestFile, ResponseFile: s.responseFile}
+	var err error
+	if l.RequestChecksum, err = fileChecksum(s.requestFile); err != nil {
+		return nil, err
+	}
+	if l.ResponseChecksum, err = fileChecksum(s.responseFile); err != nil {
+		return nil, err
+	}
+	l.Known = BinaryChecksums[s.platform+"/request"] == l.RequestChecksum &&
+		BinaryChecksums[s.platform+"/response"] == l.ResponseChecksum
+	return l, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2013 Mathias Monnerville. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sogenactif
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DATA directives of the wallet (one-click payment) feature. The feature must
+// be enabled on the merchant's contract. The card alias is bound to the
+// customer_id of the transaction.
+const (
+	walletCreateDirective = "WALLET=CREATE"
+	walletUseDirective    = "WALLET=USE;ALIAS=%s"
+	walletAliasKey        = "ALIAS"
+)
+
+// Alias is a card token stored in a customer's wallet. It can be used in
+// subsequent checkouts so that returning customers don't have to enter
+// their card details again.
+type Alias struct {
+	Id           string // Alias returned by the payment server
+	CustomerId   string
+	CardNumber   string // Masked card number
+	PaymentMeans string
+	Created      time.Time
+}
+
+// AliasStore persists card aliases.
+type AliasStore interface {
+	// Save adds an alias to the customer's wallet.
+	Save(a *Alias) error
+	// List returns all aliases of a customer.
+	List(customerId string) ([]*Alias, error)
+	// Delete removes an alias from the customer's wallet.
+	Delete(customerId, aliasId string) error
+}
+
+// MemoryAliasStore is an in-memory AliasStore.
+type MemoryAliasStore struct {
+	mu      sync.Mutex
+	wallets map[string][]*Alias
+}
+
+// NewMemoryAliasStore returns an empty in-memory alias store.
+func NewMemoryAliasStore() *MemoryAliasStore {
+	return &MemoryAliasStore{wallets: make(map[string][]*Alias)}
+}
+
+func (m *MemoryAliasStore) Save(a *Alias) error {
+	if a == nil {
+		return errors.New("nil alias")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[a.CustomerId]
+	for k, cur := range w {
+		if cur.Id == a.Id {
+			w[k] = a
+			return nil
+		}
+	}
+	m.wallets[a.CustomerId] = append(w, a)
+	return nil
+}
+
+func (m *MemoryAliasStore) List(customerId string) ([]*Alias, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*Alias, len(m.wallets[customerId]))
+	copy(list, m.wallets[customerId])
+	return list, nil
+}
+
+func (m *MemoryAliasStore) Delete(customerId, aliasId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[customerId]
+	for k, cur := range w {
+		if cur.Id == aliasId {
+			m.wallets[customerId] = append(w[:k], w[k+1:]...)
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("no alias %s for customer %s", aliasId, customerId))
+}
+
+// CreateAlias asks the payment server to store the card used for this
+// transaction in the customer's wallet. The customer must have an Id.
+func (t *Transaction) CreateAlias() error {
+	if t.customer.Id == "" {
+		return errors.New("wallet: an alias requires a customer ID")
+	}
+	t.alias = ""
+	t.createAlias = true
+	return nil
+}
+
+// UseAlias pays the transaction with a card previously stored in the
+// customer's wallet, so that the customer skips card entry. The alias
+// can't hold the ;, = and ! separators of the request.
+func (t *Transaction) UseAlias(aliasId string) error {
+	if t.customer.Id == "" {
+		return errors.New("wallet: an alias requires a customer ID")
+	}
+	if aliasId == "" {
+		return errors.New("wallet: empty alias")
+	}
+	if strings.ContainsAny(aliasId, ";=!") {
+		return errors.New(fmt.Sprintf("wallet: invalid alias %q", aliasId))
+	}
+	t.createAlias = false
+	t.alias = aliasId
+	return nil
+}
+
+// walletDirectives returns the DATA directives related to the wallet, if any.
+func (t *Transaction) walletDirectives() string {
+	if t.createAlias {
+		return walletCreateDirective
+	}
+	if t.alias != "" {
+		return fmt.Sprintf(walletUseDirective, t.alias)
+	}
+	return ""
+}
+
+// SetAliasStore sets the store used to persist the card aliases returned
+// by the payment server. Aliases are not recorded without a store.
+func (s *Sogen) SetAliasStore(st AliasStore) {
+	s.aliases = st
+}
+
+// Aliases lists the aliases in the wallet of a customer.
+func (s *Sogen) Aliases(customerId string) ([]*Alias, error) {
+	if s.aliases == nil {
+		return nil, errors.New("wallet: no alias store")
+	}
+	return s.aliases.List(customerId)
+}
+
+// DeleteAlias removes an alias from the wallet of a customer.
+func (s *Sogen) DeleteAlias(customerId, aliasId string) error {
+	if s.aliases == nil {
+		return errors.New("wallet: no alias store")
+	}
+	if err := s.aliases.Delete(customerId, aliasId); err != nil {
+		return err
+	}
+	s.Audit(AuditDeleteAlias, map[string]string{"alias": aliasId})
+	return nil
+}
+
+// saveAlias records the alias returned with an accepted payment, if any.
+func (s *Sogen) saveAlias(p *Payment) error {
+	if s.aliases == nil || p.ResponseCode != "00" || p.CardAlias == "" || p.CustomerId == "" {
+		return nil
+	}
+	return s.aliases.Save(&Alias{
+		Id:           p.CardAlias,
+		CustomerId:   p.CustomerId,
+		CardNumber:   p.CardNumber,
+		PaymentMeans: p.PaymentMeans,
+		Created:      p.PaymentDate,
+	})
+}